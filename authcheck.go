@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var authCheckTimeout time.Duration
+
+var authCheckCmd = &cobra.Command{
+	Use:   "authcheck",
+	Short: "Check whether the MCP server enforces authentication",
+	Long: `Check whether the MCP server enforces authentication.
+
+The server is initialized and asked for its tools once per credential: no
+credentials, an invalid bearer token, an expired-looking JWT and, if given,
+the token supplied with --token. For every attempt the HTTP status codes and
+WWW-Authenticate challenges returned by the server are reported.
+
+Examples:
+  mcpmap --sse=http://localhost:3000/sse authcheck
+  mcpmap --http=https://mcp.example.com/mcp --token=$TOKEN authcheck --json`,
+	Args: cobra.NoArgs,
+	RunE: runAuthCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(authCheckCmd)
+	authCheckCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results in raw JSON format")
+	authCheckCmd.Flags().
+		DurationVar(&authCheckTimeout, "timeout", 10*time.Second, "Timeout for each authentication attempt")
+}
+
+// authCredential is a single credential presented to the server during an auth check
+type authCredential struct {
+	Label string
+	Token string
+}
+
+// authCheckResult records how the server reacted to one credential
+type authCheckResult struct {
+	Credential      string   `json:"credential"`
+	Initialized     bool     `json:"initialized"`
+	ToolsListed     bool     `json:"tools_listed"`
+	ToolCount       int      `json:"tool_count"`
+	StatusCodes     []int    `json:"status_codes"`
+	WWWAuthenticate []string `json:"www_authenticate,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// authProbeTransport sets (or strips) the Authorization header on every request
+// and records the status codes and challenges that come back
+type authProbeTransport struct {
+	base  http.RoundTripper
+	token string
+
+	mu         sync.Mutex
+	statuses   []int
+	challenges []string
+}
+
+func (t *authProbeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqClone := req.Clone(req.Context())
+	if t.token == "" {
+		reqClone.Header.Del("Authorization")
+	} else {
+		reqClone.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	resp, err := t.base.RoundTrip(reqClone)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.statuses = append(t.statuses, resp.StatusCode)
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		if !contains(t.challenges, challenge) {
+			t.challenges = append(t.challenges, challenge)
+		}
+	}
+	t.mu.Unlock()
+
+	return resp, nil
+}
+
+// credentialHeaderHints mark header names that may carry credentials, e.g.
+// X-API-Key or X-Auth-Token
+var credentialHeaderHints = []string{"auth", "token", "key", "secret", "session", "cookie", "password", "credential"}
+
+// withoutCredentialHeaders returns the headers whose names do not look like
+// they carry credentials
+func withoutCredentialHeaders(headers map[string]string) map[string]string {
+	kept := make(map[string]string)
+	for name, value := range headers {
+		if !isCredentialHeader(name) {
+			kept[name] = value
+		}
+	}
+	return kept
+}
+
+// isCredentialHeader reports whether a header may carry credentials
+func isCredentialHeader(name string) bool {
+	lower := strings.ToLower(name)
+	for _, hint := range credentialHeaderHints {
+		if strings.Contains(lower, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// authCheckCredentials returns the credentials to try, in order
+func authCheckCredentials(token string) []authCredential {
+	creds := []authCredential{
+		{Label: "none", Token: ""},
+		{Label: "invalid-token", Token: "mcpmap-invalid-token"},
+		{Label: "expired-jwt", Token: expiredJWT()},
+	}
+	if token != "" {
+		creds = append(creds, authCredential{Label: "supplied-token", Token: token})
+	}
+	return creds
+}
+
+// expiredJWT builds a syntactically valid HS256 JWT whose exp lies in the past
+// and whose signature is random, so no server should ever accept it
+func expiredJWT() string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := enc.EncodeToString([]byte(`{"sub":"mcpmap","iat":1577836800,"exp":1577840400}`))
+
+	sig := make([]byte, 32)
+	rand.Read(sig)
+
+	return header + "." + payload + "." + enc.EncodeToString(sig)
+}
+
+// probeAuth initializes a session presenting cred and tries to list tools
+func probeAuth(ctx context.Context, cred authCredential) authCheckResult {
+	result := authCheckResult{Credential: cred.Label, StatusCodes: []int{}}
+
+	// Proxy and TLS settings still apply. Profile headers that may carry
+	// credentials do not, so each probe presents only its own credential.
+	var base http.RoundTripper
+	base, err := newBaseTransport(proxyURL)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if headers := withoutCredentialHeaders(requestHeaders); len(headers) > 0 {
+		base = &headerTransport{base: base, headers: headers}
+	}
+	probe := &authProbeTransport{base: base, token: cred.Token}
+
+	transport, err := newClientTransport(transportType, serverURL, &http.Client{Transport: probe})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authCheckTimeout)
+	defer cancel()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: clientName, Version: "v1.0.0"}, nil)
+	session, err := client.Connect(ctx, transport)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Initialized = true
+		if tools, err := getTools(ctx, session); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.ToolsListed = true
+			result.ToolCount = len(tools)
+		}
+		session.Close()
+	}
+
+	probe.mu.Lock()
+	result.StatusCodes = append(result.StatusCodes, probe.statuses...)
+	result.WWWAuthenticate = append(result.WWWAuthenticate, probe.challenges...)
+	probe.mu.Unlock()
+
+	return result
+}
+
+// checkAuthentication runs probeAuth for every credential
+func checkAuthentication(ctx context.Context, creds []authCredential) []authCheckResult {
+	results := make([]authCheckResult, 0, len(creds))
+	for _, cred := range creds {
+		results = append(results, probeAuth(ctx, cred))
+	}
+	return results
+}
+
+// authCheckFindings turns results into human-readable warnings
+func authCheckFindings(results []authCheckResult) []string {
+	var findings []string
+	for _, r := range results {
+		switch r.Credential {
+		case "none":
+			if r.ToolsListed {
+				findings = append(findings, fmt.Sprintf(
+					"server lists %d tools without any credentials (tools/list only, no tool was called)", r.ToolCount))
+			} else if r.Initialized {
+				findings = append(findings, "server accepts unauthenticated initialize requests")
+			}
+		case "invalid-token", "expired-jwt":
+			if r.Initialized {
+				findings = append(findings, fmt.Sprintf("server accepts a bogus credential (%s)", r.Credential))
+			}
+		}
+	}
+	return findings
+}
+
+func runAuthCheck(cmd *cobra.Command, args []string) error {
+	token, err := currentToken()
+	if err != nil {
+		return err
+	}
+	results := checkAuthentication(context.Background(), authCheckCredentials(token))
+	findings := authCheckFindings(results)
+
+	if jsonOutput {
+		out := struct {
+			Server   string            `json:"server"`
+			Results  []authCheckResult `json:"results"`
+			Findings []string          `json:"findings"`
+		}{serverURL, results, findings}
+
+		js, err := json.Marshal(out)
+		if err != nil {
+			return fmt.Errorf("json marshal results: %w", err)
+		}
+		fmt.Fprintln(os.Stdout, string(js))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CREDENTIAL\tINITIALIZE\tTOOLS/LIST\tSTATUS\tWWW-AUTHENTICATE")
+	for _, r := range results {
+		tools := "denied"
+		if r.ToolsListed {
+			tools = fmt.Sprintf("ok (%d tools)", r.ToolCount)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			r.Credential, okOrDenied(r.Initialized), tools,
+			formatStatusCodes(r.StatusCodes), strings.Join(r.WWWAuthenticate, "; "))
+	}
+	w.Flush()
+
+	fmt.Println()
+	if len(findings) == 0 {
+		fmt.Println("Authentication appears to be enforced")
+	}
+	for _, finding := range findings {
+		fmt.Printf("WARNING: %s\n", finding)
+	}
+
+	return nil
+}
+
+func okOrDenied(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "denied"
+}
+
+// formatStatusCodes renders the distinct status codes in the order they were seen
+func formatStatusCodes(codes []int) string {
+	var seen []string
+	for _, code := range codes {
+		s := fmt.Sprintf("%d", code)
+		if !contains(seen, s) {
+			seen = append(seen, s)
+		}
+	}
+	if len(seen) == 0 {
+		return "-"
+	}
+	return strings.Join(seen, ",")
+}
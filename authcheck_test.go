@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requireBearer rejects requests that do not carry the given bearer token
+func requireBearer(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="test"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestAuthCheckCredentials(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		labels []string
+	}{
+		{"without token", "", []string{"none", "invalid-token", "expired-jwt"}},
+		{"with token", "secret", []string{"none", "invalid-token", "expired-jwt", "supplied-token"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := authCheckCredentials(tt.token)
+			if len(creds) != len(tt.labels) {
+				t.Fatalf("expected %d credentials, got %d", len(tt.labels), len(creds))
+			}
+			for i, cred := range creds {
+				if cred.Label != tt.labels[i] {
+					t.Errorf("credential %d: expected label %q, got %q", i, tt.labels[i], cred.Label)
+				}
+			}
+		})
+	}
+}
+
+func TestExpiredJWT(t *testing.T) {
+	parts := strings.Split(expiredJWT(), ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 JWT segments, got %d", len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("unmarshal claims: %v", err)
+	}
+	if time.Unix(claims.Exp, 0).After(time.Now()) {
+		t.Errorf("expected exp in the past, got %v", time.Unix(claims.Exp, 0))
+	}
+}
+
+func TestCheckAuthentication(t *testing.T) {
+	oldTimeout := authCheckTimeout
+	authCheckTimeout = 5 * time.Second
+	defer func() { authCheckTimeout = oldTimeout }()
+
+	t.Run("enforced", func(t *testing.T) {
+		ts := newTestMCPServer(t, requireBearer("good-token"))
+		useTestServer(t, ts.URL)
+
+		results := checkAuthentication(context.Background(), authCheckCredentials("good-token"))
+		if len(results) != 4 {
+			t.Fatalf("expected 4 results, got %d", len(results))
+		}
+
+		for _, r := range results[:3] {
+			if r.Initialized || r.ToolsListed {
+				t.Errorf("%s: expected to be denied, got %+v", r.Credential, r)
+			}
+			if !slices.Contains(r.StatusCodes, http.StatusUnauthorized) {
+				t.Errorf("%s: expected 401 in status codes, got %v", r.Credential, r.StatusCodes)
+			}
+			if len(r.WWWAuthenticate) == 0 || !strings.Contains(r.WWWAuthenticate[0], "Bearer") {
+				t.Errorf("%s: expected Bearer challenge, got %v", r.Credential, r.WWWAuthenticate)
+			}
+		}
+
+		supplied := results[3]
+		if !supplied.Initialized || !supplied.ToolsListed || supplied.ToolCount != 1 {
+			t.Errorf("supplied-token: expected success with 1 tool, got %+v", supplied)
+		}
+
+		if findings := authCheckFindings(results); len(findings) != 0 {
+			t.Errorf("expected no findings, got %v", findings)
+		}
+	})
+
+	t.Run("profile credentials are not sent", func(t *testing.T) {
+		ts := newTestMCPServer(t, requireBearer("good-token"))
+		useTestServer(t, ts.URL)
+		requestHeaders = map[string]string{"Authorization": "Bearer good-token", "X-Tenant": "acme"}
+		defer func() { requestHeaders = nil }()
+
+		results := checkAuthentication(context.Background(), authCheckCredentials(""))
+		for _, r := range results {
+			if r.Initialized {
+				t.Errorf("%s: profile Authorization header was sent with the probe", r.Credential)
+			}
+		}
+	})
+
+	t.Run("not enforced", func(t *testing.T) {
+		ts := newTestMCPServer(t, nil)
+		useTestServer(t, ts.URL)
+
+		results := checkAuthentication(context.Background(), authCheckCredentials(""))
+		for _, r := range results {
+			if !r.ToolsListed {
+				t.Errorf("%s: expected tools to be listed, got %+v", r.Credential, r)
+			}
+		}
+
+		findings := authCheckFindings(results)
+		if len(findings) != 3 {
+			t.Fatalf("expected 3 findings, got %v", findings)
+		}
+		if !strings.Contains(findings[0], "without any credentials") {
+			t.Errorf("unexpected first finding: %q", findings[0])
+		}
+	})
+}
+
+func TestWithoutCredentialHeaders(t *testing.T) {
+	headers := map[string]string{
+		"Authorization":   "Bearer secret",
+		"X-API-Key":       "secret",
+		"X-Auth-Token":    "secret",
+		"Cookie":          "session=secret",
+		"X-Tenant":        "acme",
+		"Accept-Language": "en",
+	}
+	got := withoutCredentialHeaders(headers)
+	if len(got) != 2 || got["X-Tenant"] != "acme" || got["Accept-Language"] != "en" {
+		t.Errorf("withoutCredentialHeaders() = %v, want only X-Tenant and Accept-Language", got)
+	}
+}
@@ -0,0 +1,289 @@
+// batch.go - Execute many tool calls from a file over one session
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var batchParallel int
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <calls.jsonl|calls.yaml>",
+	Short: "Execute many tool calls from a file over a single session",
+	Long: `Execute many tool calls from a file over a single session.
+
+Each line of a JSONL file (or each item of a YAML list) is a call:
+
+  {"id": "q1", "tool": "search", "arguments": {"query": "login", "limit": "10"}}
+
+The id is optional and defaults to the call's position. String arguments are
+converted to the types in the tool's schema like exec --param values. Every
+call is validated before the first one is sent: unknown tools, arguments that
+do not convert and missing required arguments abort the batch.
+
+Results are written to stdout as JSON lines in input order, each with the
+call's id, tool, duration and either the result or the error. The command
+fails if any call fails or returns a tool error.
+
+Examples:
+  mcpmap --http=https://mcp.example.com/mcp batch calls.jsonl
+  mcpmap -s prod batch calls.yaml --parallel 8 > results.jsonl`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBatch,
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+	addRecordFlag(batchCmd)
+	batchCmd.Flags().IntVarP(&batchParallel, "parallel", "p", 1, "Number of calls to run concurrently")
+}
+
+// batchCall is one call read from the batch file
+type batchCall struct {
+	ID        any            `json:"id,omitempty"`
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+
+	// where locates the call in the file for error messages
+	where string
+}
+
+// batchResult is the output line for one call
+type batchResult struct {
+	ID         any                 `json:"id"`
+	Tool       string              `json:"tool"`
+	DurationMs int64               `json:"duration_ms"`
+	Result     *mcp.CallToolResult `json:"result,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+func (r *batchResult) failed() bool {
+	return r.Error != "" || (r.Result != nil && r.Result.IsError)
+}
+
+// loadBatchCalls reads a JSONL file, or a YAML list for .yaml and .yml files
+func loadBatchCalls(path string) ([]*batchCall, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var calls []*batchCall
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var items []any
+		if err := yaml.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		for i, item := range items {
+			raw, err := json.Marshal(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: item %d: %w", path, i+1, err)
+			}
+			call, err := decodeBatchCall(raw, i+1, fmt.Sprintf("item %d", i+1))
+			if err != nil {
+				return nil, fmt.Errorf("%s: item %d: %w", path, i+1, err)
+			}
+			calls = append(calls, call)
+		}
+	default:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+			call, err := decodeBatchCall(text, len(calls)+1, fmt.Sprintf("line %d", line))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			calls = append(calls, call)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("%s contains no calls", path)
+	}
+	return calls, nil
+}
+
+// decodeBatchCall decodes one call. Calls without an id get their position
+// among the calls as id.
+func decodeBatchCall(data []byte, position int, where string) (*batchCall, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	call := &batchCall{where: where}
+	if err := dec.Decode(call); err != nil {
+		return nil, err
+	}
+	if call.Tool == "" {
+		return nil, fmt.Errorf("missing tool")
+	}
+	if call.ID == nil {
+		call.ID = position
+	}
+	return call, nil
+}
+
+// convertArguments converts string arguments to the types in schema, like
+// parseParamsWithSchema does for --param values, and checks required ones.
+// Arguments that already have a JSON type are passed through.
+func convertArguments(args map[string]any, schema *ToolSchema) (map[string]any, error) {
+	result := make(map[string]any, len(args))
+	for name, value := range args {
+		paramSchema, exists := schema.Parameters[name]
+		s, isString := value.(string)
+		if !exists || !isString {
+			result[name] = value
+			continue
+		}
+
+		converted, err := convertValue(s, paramSchema)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = converted
+	}
+
+	if err := validateRequired(result, schema); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// validateBatch converts the arguments of every call and checks that each
+// tool exists and may be called. All problems are reported at once.
+func validateBatch(tools []*mcp.Tool, calls []*batchCall) error {
+	byName := make(map[string]*mcp.Tool, len(tools))
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+
+	var problems []string
+	for _, call := range calls {
+		tool, ok := byName[call.Tool]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("call %v (%s): tool %q not found", call.ID, call.where, call.Tool))
+			continue
+		}
+
+		schema, err := toolSchema(tool)
+		if err == nil {
+			call.Arguments, err = convertArguments(call.Arguments, schema)
+		}
+		if err == nil {
+			err = defaultToolGate.Check(tool, call.Tool)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("call %v (%s): %v", call.ID, call.where, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", p)
+		}
+		return fmt.Errorf("%d of %d calls are invalid, nothing was executed", len(problems), len(calls))
+	}
+	return nil
+}
+
+// executeBatch runs calls with up to parallel in flight and writes the
+// results to w in input order as soon as they are available
+func executeBatch(ctx context.Context, session *mcp.ClientSession, calls []*batchCall, parallel int, w io.Writer) (failed int) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]*batchResult, len(calls))
+	done := make([]chan struct{}, len(calls))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < parallel; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i] = executeBatchCall(ctx, session, calls[i])
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range calls {
+			work <- i
+		}
+		close(work)
+	}()
+
+	for i := range calls {
+		<-done[i]
+		if results[i].failed() {
+			failed++
+		}
+		js, err := json.Marshal(results[i])
+		if err != nil {
+			js, _ = json.Marshal(&batchResult{ID: calls[i].ID, Tool: calls[i].Tool, Error: err.Error()})
+		}
+		fmt.Fprintln(w, string(outputRedactor.JSON(js)))
+	}
+	wg.Wait()
+	return failed
+}
+
+func executeBatchCall(ctx context.Context, session *mcp.ClientSession, call *batchCall) *batchResult {
+	start := time.Now()
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: call.Tool, Arguments: call.Arguments})
+
+	result := &batchResult{ID: call.ID, Tool: call.Tool, DurationMs: time.Since(start).Milliseconds(), Result: res}
+	if err != nil {
+		result.Result = nil
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	calls, err := loadBatchCalls(args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	return withSession(ctx, func(session *mcp.ClientSession) error {
+		tools, err := getTools(ctx, session)
+		if err != nil {
+			return fmt.Errorf("list tools: %w", err)
+		}
+		if err := validateBatch(tools, calls); err != nil {
+			return err
+		}
+
+		if failed := executeBatch(ctx, session, calls, batchParallel, os.Stdout); failed > 0 {
+			return fmt.Errorf("%d of %d calls failed", failed, len(calls))
+		}
+		return nil
+	})
+}
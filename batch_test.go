@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+// newBatchTestSession connects to an in-memory server with a slow add tool
+// and a tool that always reports an error
+func newBatchTestSession(t *testing.T) (*mcp.ClientSession, []*mcp.Tool) {
+	t.Helper()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "batch-test"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "add"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[addParams]) (*mcp.CallToolResultFor[any], error) {
+			// Later calls finish first, so ordering is up to executeBatch
+			time.Sleep(time.Duration(10-params.Arguments.A) * time.Millisecond)
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprint(params.Arguments.A + params.Arguments.B)}},
+			}, nil
+		})
+	mcp.AddTool(server, &mcp.Tool{Name: "fail"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[struct{}]) (*mcp.CallToolResultFor[any], error) {
+			return nil, fmt.Errorf("always fails")
+		})
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(context.Background(), serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "batch-test-client"}, nil)
+	session, err := client.Connect(context.Background(), clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+
+	tools, err := getTools(context.Background(), session)
+	if err != nil {
+		t.Fatalf("getTools: %v", err)
+	}
+	return session, tools
+}
+
+func TestLoadBatchCalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantIDs []any
+		wantErr string
+	}{
+		{"jsonl", "calls.jsonl", "{\"id\": \"x\", \"tool\": \"add\"}\n\n{\"tool\": \"add\", \"arguments\": {\"a\": 1}}\n", []any{"x", 2}, ""},
+		{"yaml", "calls.yaml", "- tool: add\n  arguments: {a: 1}\n- id: last\n  tool: fail\n", []any{1, "last"}, ""},
+		{"missing tool", "calls.jsonl", `{"arguments": {}}`, nil, "calls.jsonl:1: missing tool"},
+		{"unknown field", "calls.jsonl", "{\"tool\": \"add\"}\n{\"tool\": \"add\", \"args\": {}}", nil, "calls.jsonl:2"},
+		{"empty", "calls.jsonl", "\n", nil, "contains no calls"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, err := loadBatchCalls(writeTestFile(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var ids []any
+			for _, c := range calls {
+				ids = append(ids, c.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("got ids %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestConvertArguments(t *testing.T) {
+	schema := &ToolSchema{
+		Parameters: map[string]*ParameterSchema{
+			"limit": {Name: "limit", Type: "integer", Required: true},
+			"tags":  {Name: "tags", Type: "array", Items: &ParameterSchema{Type: "string"}},
+			"query": {Name: "query", Type: "string"},
+		},
+		Required: []string{"limit"},
+	}
+
+	tests := []struct {
+		name    string
+		args    map[string]any
+		want    map[string]any
+		wantErr string
+	}{
+		{"string converted", map[string]any{"limit": "10"}, map[string]any{"limit": 10}, ""},
+		{"typed passed through", map[string]any{"limit": 5.0, "tags": []any{"a"}}, map[string]any{"limit": 5.0, "tags": []any{"a"}}, ""},
+		{"array from string", map[string]any{"limit": 1.0, "tags": "a,b"}, map[string]any{"limit": 1.0, "tags": []any{"a", "b"}}, ""},
+		{"unknown kept", map[string]any{"limit": 1.0, "extra": "x"}, map[string]any{"limit": 1.0, "extra": "x"}, ""},
+		{"bad integer", map[string]any{"limit": "ten"}, nil, "limit"},
+		{"missing required", map[string]any{"query": "x"}, nil, "missing required parameters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertArguments(tt.args, schema)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	_, tools := newBatchTestSession(t)
+
+	calls := []*batchCall{
+		{ID: 1, Tool: "add", Arguments: map[string]any{"a": "1", "b": 2.0}, where: "line 1"},
+		{ID: 2, Tool: "missing", where: "line 2"},
+		{ID: 3, Tool: "add", Arguments: map[string]any{"a": "one"}, where: "line 3"},
+	}
+	err := validateBatch(tools, calls)
+	if err == nil || !strings.Contains(err.Error(), "2 of 3 calls are invalid") {
+		t.Fatalf("expected two invalid calls, got %v", err)
+	}
+	if fmt.Sprint(calls[0].Arguments["a"]) != "1" {
+		t.Errorf("expected valid call to be converted, got %#v", calls[0].Arguments)
+	}
+}
+
+func TestExecuteBatch(t *testing.T) {
+	session, tools := newBatchTestSession(t)
+
+	var calls []*batchCall
+	for i := 0; i < 6; i++ {
+		calls = append(calls, &batchCall{ID: i, Tool: "add", Arguments: map[string]any{"a": i, "b": 100}})
+	}
+	calls = append(calls, &batchCall{ID: "bad", Tool: "fail", Arguments: map[string]any{}})
+	if err := validateBatch(tools, calls); err != nil {
+		t.Fatalf("validateBatch: %v", err)
+	}
+
+	var out bytes.Buffer
+	failed := executeBatch(context.Background(), session, calls, 3, &out)
+	if failed != 1 {
+		t.Errorf("expected 1 failed call, got %d", failed)
+	}
+
+	var results []batchResult
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var r struct {
+			batchResult
+			Result struct {
+				Content []struct{ Text string } `json:"content"`
+				IsError bool                    `json:"isError"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid output line %q: %v", scanner.Text(), err)
+		}
+		results = append(results, r.batchResult)
+
+		switch {
+		case r.ID == "bad":
+			if !r.Result.IsError && r.Error == "" {
+				t.Errorf("expected failing call to report an error: %s", scanner.Text())
+			}
+		case r.Result.Content[0].Text != fmt.Sprint(r.ID.(float64)+100):
+			t.Errorf("unexpected result for call %v: %s", r.ID, scanner.Text())
+		}
+	}
+
+	if len(results) != len(calls) {
+		t.Fatalf("expected %d results, got %d", len(calls), len(results))
+	}
+	for i, r := range results[:6] {
+		if r.ID != float64(i) {
+			t.Errorf("result %d has id %v, output must follow input order", i, r.ID)
+		}
+	}
+}
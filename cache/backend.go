@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Names of the backends accepted by OpenBackend
+const (
+	BackendFiles      = "files"
+	BackendMemory     = "memory"
+	BackendSingleFile = "single-file"
+	BackendSnapshot   = "snapshot"
+)
+
+// Backends lists the names of every backend
+var Backends = []string{BackendFiles, BackendMemory, BackendSingleFile, BackendSnapshot}
+
+// ErrReadOnly is returned when writing to a read-only backend
+var ErrReadOnly = errors.New("cache backend is read-only")
+
+// Backend stores the cache entries of all servers. The package functions
+// such as New, GetCacheInfo and Import work on the active backend, see Use.
+type Backend interface {
+	// Open returns the entry of the server with the given connection
+	// parameters, going stale after ttl
+	Open(serverURL, transportType, authToken, clientName string, ttl time.Duration) Cache
+
+	// Info describes the backend and every entry in it
+	Info() (*CacheInfo, error)
+
+	// Read returns the data of the entry with the given key
+	Read(key string) (*CacheData, error)
+
+	// Remove removes the entry with the given key
+	Remove(key string) error
+
+	// Clear removes every entry
+	Clear() error
+
+	// export returns the encoded cache file of an entry for a bundle
+	export(key string) ([]byte, error)
+
+	// importEntry stores an entry of a bundle unless the backend holds one
+	// cached later, and reports whether it did
+	importEntry(key string, file bundleFile) (bool, error)
+}
+
+// active is the backend used by the package functions
+var active = Files()
+
+// Use makes b the backend of the package functions
+func Use(b Backend) {
+	active = b
+}
+
+// OpenBackend returns the backend with the given name. path is the store
+// file of single-file, defaulting to cache.db in the cache directory, and
+// the bundle served by snapshot. The other backends take no path. secret
+// encrypts the entries, which only the files backend supports, see OpenFiles.
+func OpenBackend(name, path string, secret []byte) (Backend, error) {
+	if len(secret) > 0 && name != "" && name != BackendFiles {
+		return nil, fmt.Errorf("the %s backend does not support encryption, use the %s backend", name, BackendFiles)
+	}
+	switch name {
+	case "", BackendFiles:
+		if path != "" {
+			return nil, fmt.Errorf("the %s backend does not take a path", BackendFiles)
+		}
+		return OpenFiles(secret)
+	case BackendMemory:
+		if path != "" {
+			return nil, fmt.Errorf("the %s backend does not take a path", BackendMemory)
+		}
+		return NewMemory(), nil
+	case BackendSingleFile:
+		if path == "" {
+			path = filepath.Join(getCacheDir(), "cache.db")
+		}
+		return OpenSingleFile(path), nil
+	case BackendSnapshot:
+		if path == "" {
+			return nil, fmt.Errorf("the %s backend needs the path of a bundle written by cache export", BackendSnapshot)
+		}
+		return OpenSnapshot(path)
+	}
+	return nil, fmt.Errorf("unknown cache backend %q (supported: %s, %s, %s, %s)",
+		name, BackendFiles, BackendMemory, BackendSingleFile, BackendSnapshot)
+}
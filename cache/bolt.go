@@ -0,0 +1,448 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+// storeVersion is the version of the single-file store format
+const storeVersion = "1"
+
+// storeLockTimeout bounds the wait for another process holding the store,
+// such as a background refresh, so tab completion is not blocked by it
+var storeLockTimeout = time.Second
+
+// Buckets of the single-file store
+var (
+	bucketMeta = []byte("meta")
+	// bucketEntries holds the encoded cache file of each entry by key
+	bucketEntries = []byte("entries")
+	// bucketInfo holds the FileInfo of each entry by key, so listing the
+	// store does not decode every entry
+	bucketInfo = []byte("info")
+	// bucketIndex holds the server URL and server name of each entry, see
+	// indexKey
+	bucketIndex = []byte("index")
+	// bucketClaims holds when a refresh of an entry was claimed, see
+	// ClaimRefresh
+	bucketClaims = []byte("claims")
+
+	storeBuckets = [][]byte{bucketEntries, bucketInfo, bucketIndex, bucketClaims}
+	versionKey   = []byte("version")
+)
+
+// boltBackend keeps every entry in one bbolt database file. Entries are read
+// and written one key at a time, and entries are looked up by server URL or
+// name through an index.
+type boltBackend struct {
+	path string
+}
+
+// OpenSingleFile returns a backend keeping every entry in the store file at
+// path. The store is only opened for the duration of each operation: readers
+// share it, writers wait for each other and for readers, so it suits many
+// entries that are written rarely, e.g. after scanning many servers.
+func OpenSingleFile(path string) Backend {
+	return &boltBackend{path: path}
+}
+
+// open opens the store for one operation
+func (b *boltBackend) open(readOnly bool) (*bolt.DB, error) {
+	if !readOnly {
+		if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+			return nil, fmt.Errorf("create cache dir: %w", err)
+		}
+	}
+	db, err := bolt.Open(b.path, 0600, &bolt.Options{ReadOnly: readOnly, Timeout: storeLockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open cache store %s: %w", b.path, err)
+	}
+	return db, nil
+}
+
+// view calls fn in a read transaction. A store that was never written to is
+// empty, and fn is not called. Neither is it while another process holds the
+// store longer than storeLockTimeout: reads then miss the cache.
+func (b *boltBackend) view(fn func(tx *bolt.Tx) error) error {
+	if _, err := os.Stat(b.path); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := b.open(true)
+	if errors.Is(err, berrors.ErrTimeout) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		if meta == nil {
+			return nil
+		}
+		if err := b.checkVersion(meta); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// update calls fn in a write transaction, creating the buckets first
+func (b *boltBackend) update(fn func(tx *bolt.Tx) error) error {
+	db, err := b.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return fmt.Errorf("create cache store: %w", err)
+		}
+		if meta.Get(versionKey) == nil {
+			if err := meta.Put(versionKey, []byte(storeVersion)); err != nil {
+				return fmt.Errorf("create cache store: %w", err)
+			}
+		}
+		if err := b.checkVersion(meta); err != nil {
+			return err
+		}
+		for _, name := range storeBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("create cache store: %w", err)
+			}
+		}
+		return fn(tx)
+	})
+}
+
+func (b *boltBackend) checkVersion(meta *bolt.Bucket) error {
+	if v := string(meta.Get(versionKey)); v != storeVersion {
+		return fmt.Errorf("cache store %s has version %s, this mcpmap supports version %s", b.path, v, storeVersion)
+	}
+	return nil
+}
+
+// indexKey returns the index key of an entry with the given key: the kind
+// of the indexed value, the value and the entry key, separated by NUL bytes
+func indexKey(kind, value, key string) []byte {
+	return []byte(kind + "\x00" + value + "\x00" + key)
+}
+
+// indexKeys returns the index keys of fi: its server URL without a trailing
+// slash and its server name in lower case, as compared by Matches
+func indexKeys(fi FileInfo) [][]byte {
+	var keys [][]byte
+	if fi.ServerURL != "" {
+		keys = append(keys, indexKey("url", strings.TrimSuffix(fi.ServerURL, "/"), fi.Key))
+	}
+	if fi.ServerName != "" {
+		keys = append(keys, indexKey("name", strings.ToLower(fi.ServerName), fi.Key))
+	}
+	return keys
+}
+
+// readInfo returns the FileInfo stored for key, with its age as of now
+func readInfo(tx *bolt.Tx, key []byte) (FileInfo, bool) {
+	var fi FileInfo
+	data := tx.Bucket(bucketInfo).Get(key)
+	if data == nil || json.Unmarshal(data, &fi) != nil {
+		return fi, false
+	}
+	fi.AgeSeconds = int64(time.Since(fi.CachedAt) / time.Second)
+	if fi.TTLSeconds > 0 {
+		fetched := (&cacheFile{Timestamp: fi.CachedAt, Sections: fi.Sections}).fetchedAt()
+		fi.Stale = time.Since(fetched) >= time.Duration(fi.TTLSeconds)*time.Second
+	}
+	return fi, true
+}
+
+// readFile returns the parsed entry with the given key, nil if there is none
+// or it cannot be read
+func readFile(tx *bolt.Tx, key string) *cacheFile {
+	data := tx.Bucket(bucketEntries).Get([]byte(key))
+	if data == nil {
+		return nil
+	}
+	cf, err := parseCacheFile(data)
+	if err != nil || cf.Version != cacheVersion {
+		return nil
+	}
+	return cf
+}
+
+// putEntry stores the encoded cache file of an entry with its info and index
+// keys. cf is the parsed file, nil if it cannot be read.
+func putEntry(tx *bolt.Tx, key string, data []byte, cf *cacheFile) error {
+	if err := deleteEntry(tx, key); err != nil {
+		return err
+	}
+
+	var modTime time.Time
+	if cf != nil {
+		modTime = cf.Timestamp
+	}
+	fi := newFileInfo(key+".json", int64(len(data)), modTime, cf)
+	info, err := json.Marshal(fi)
+	if err != nil {
+		return fmt.Errorf("marshal cache entry info: %w", err)
+	}
+
+	if err := tx.Bucket(bucketEntries).Put([]byte(key), data); err != nil {
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := tx.Bucket(bucketInfo).Put([]byte(key), info); err != nil {
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	for _, k := range indexKeys(fi) {
+		if err := tx.Bucket(bucketIndex).Put(k, nil); err != nil {
+			return fmt.Errorf("write cache index: %w", err)
+		}
+	}
+	return nil
+}
+
+// deleteEntry removes an entry with its info, index keys and refresh claim
+func deleteEntry(tx *bolt.Tx, key string) error {
+	if fi, ok := readInfo(tx, []byte(key)); ok {
+		for _, k := range indexKeys(fi) {
+			if err := tx.Bucket(bucketIndex).Delete(k); err != nil {
+				return fmt.Errorf("delete cache index: %w", err)
+			}
+		}
+	}
+	for _, name := range [][]byte{bucketEntries, bucketInfo, bucketClaims} {
+		if err := tx.Bucket(name).Delete([]byte(key)); err != nil {
+			return fmt.Errorf("delete cache entry: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *boltBackend) Open(serverURL, transportType, authToken, clientName string, ttl time.Duration) Cache {
+	return &boltCache{
+		backend:       b,
+		key:           generateCacheKey(serverURL, transportType, authToken, clientName),
+		ttl:           ttl,
+		serverURL:     withoutPassword(serverURL),
+		transportType: transportType,
+		clientName:    clientName,
+	}
+}
+
+func (b *boltBackend) Info() (*CacheInfo, error) {
+	info := &CacheInfo{
+		Backend:  BackendSingleFile,
+		CacheDir: b.path,
+		Files:    []FileInfo{},
+	}
+	err := b.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketInfo).ForEach(func(key, _ []byte) error {
+			fi, ok := readInfo(tx, key)
+			if !ok {
+				fi = newFileInfo(string(key)+".json", 0, time.Time{}, nil)
+			}
+			info.Files = append(info.Files, fi)
+			info.TotalFiles++
+			info.TotalSize += fi.Size
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// find looks up the entries matching query through the index instead of
+// reading the info of every entry
+func (b *boltBackend) find(query string) ([]FileInfo, error) {
+	var matches []FileInfo
+	err := b.view(func(tx *bolt.Tx) error {
+		keys := make(map[string]bool)
+		scan := func(bucket *bolt.Bucket, prefix []byte, keyOf func(k []byte) string) {
+			c := bucket.Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				keys[keyOf(k)] = true
+			}
+		}
+		afterPrefix := func(prefix []byte) func(k []byte) string {
+			return func(k []byte) string { return string(k[len(prefix):]) }
+		}
+
+		key := strings.TrimSuffix(query, ".json")
+		if len(key) >= 4 {
+			scan(tx.Bucket(bucketInfo), []byte(key), func(k []byte) string { return string(k) })
+		} else if tx.Bucket(bucketInfo).Get([]byte(key)) != nil {
+			keys[key] = true
+		}
+		urlPrefix := indexKey("url", strings.TrimSuffix(query, "/"), "")
+		scan(tx.Bucket(bucketIndex), urlPrefix, afterPrefix(urlPrefix))
+		namePrefix := indexKey("name", strings.ToLower(query), "")
+		scan(tx.Bucket(bucketIndex), namePrefix, afterPrefix(namePrefix))
+
+		for _, key := range slices.Sorted(maps.Keys(keys)) {
+			if fi, ok := readInfo(tx, []byte(key)); ok && fi.Matches(query) {
+				matches = append(matches, fi)
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
+
+func (b *boltBackend) Read(key string) (*CacheData, error) {
+	var cf *cacheFile
+	if err := b.view(func(tx *bolt.Tx) error {
+		cf = readFile(tx, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if cf == nil {
+		return nil, fmt.Errorf("cache entry %s is missing or unreadable", key)
+	}
+	return cf.data(), nil
+}
+
+func (b *boltBackend) Remove(key string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		return deleteEntry(tx, key)
+	})
+}
+
+func (b *boltBackend) Clear() error {
+	return b.update(func(tx *bolt.Tx) error {
+		for _, name := range storeBuckets {
+			if err := tx.DeleteBucket(name); err != nil {
+				return fmt.Errorf("clear cache store: %w", err)
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return fmt.Errorf("clear cache store: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltBackend) export(key string) ([]byte, error) {
+	var data []byte
+	if err := b.view(func(tx *bolt.Tx) error {
+		data = slices.Clone(tx.Bucket(bucketEntries).Get([]byte(key)))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("cache entry %s is missing", key)
+	}
+	return data, nil
+}
+
+func (b *boltBackend) importEntry(key string, file bundleFile) (bool, error) {
+	imported := false
+	err := b.update(func(tx *bolt.Tx) error {
+		if local := readFile(tx, key); local != nil && local.Timestamp.After(file.cf.Timestamp) {
+			return nil
+		}
+		imported = true
+		return putEntry(tx, key, file.data, file.cf)
+	})
+	return imported, err
+}
+
+// boltCache is the Cache of one entry of a boltBackend
+type boltCache struct {
+	backend *boltBackend
+	key     string
+	ttl     time.Duration
+
+	serverURL     string
+	transportType string
+	clientName    string
+}
+
+func (c *boltCache) Load() (*CacheData, bool, error) {
+	var cf *cacheFile
+	if err := c.backend.view(func(tx *bolt.Tx) error {
+		cf = readFile(tx, c.key)
+		return nil
+	}); err != nil || cf == nil {
+		return nil, false, err
+	}
+	return cf.data(), isFresh(c.ttl, cf.fetchedAt()), nil
+}
+
+func (c *boltCache) Save(data *CacheData) error {
+	return c.write(data, Sections)
+}
+
+func (c *boltCache) Update(data *CacheData, sections ...Section) error {
+	return c.write(data, sections)
+}
+
+// write merges sections of data into the entry, as fileCache.write does
+func (c *boltCache) write(data *CacheData, sections []Section) error {
+	return c.backend.update(func(tx *bolt.Tx) error {
+		var old *cacheFile
+		if len(sections) < len(Sections) {
+			old = readFile(tx, c.key)
+		}
+		cf := merge(old, data, sections, time.Now())
+		cf.ServerURL = c.serverURL
+		cf.Transport = c.transportType
+		cf.ClientName = c.clientName
+		cf.TTLSeconds = int64(c.ttl / time.Second)
+
+		encoded, err := json.Marshal(cf)
+		if err != nil {
+			return fmt.Errorf("marshal cache data: %w", err)
+		}
+		claimed := tx.Bucket(bucketClaims).Get([]byte(c.key))
+		claimed = slices.Clone(claimed)
+		if err := putEntry(tx, c.key, encoded, cf); err != nil {
+			return err
+		}
+		// putEntry dropped the claim; a refresh that is still due keeps it
+		if claimed != nil && !isFresh(c.ttl, cf.fetchedAt()) {
+			return tx.Bucket(bucketClaims).Put([]byte(c.key), claimed)
+		}
+		return nil
+	})
+}
+
+func (c *boltCache) Delete() error {
+	return c.backend.Remove(c.key)
+}
+
+// ClaimRefresh records the claim in the store
+func (c *boltCache) ClaimRefresh() bool {
+	claimed := false
+	c.backend.update(func(tx *bolt.Tx) error {
+		claims := tx.Bucket(bucketClaims)
+		var at time.Time
+		if data := claims.Get([]byte(c.key)); data != nil && at.UnmarshalBinary(data) == nil && time.Since(at) < refreshClaimTimeout {
+			return nil
+		}
+		now, err := time.Now().MarshalBinary()
+		if err != nil {
+			return err
+		}
+		claimed = true
+		return claims.Put([]byte(c.key), now)
+	})
+	return claimed
+}
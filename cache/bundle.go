@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// bundleVersion is the version of the export bundle layout
+const bundleVersion = 1
+
+// bundleManifestName is the first file of every bundle
+const bundleManifestName = "manifest.json"
+
+// maxBundleFileSize bounds each file read from a bundle
+const maxBundleFileSize = 64 << 20
+
+// ErrPlaintextExport is returned when exporting an encrypted cache without
+// allowing a plain text bundle
+var ErrPlaintextExport = errors.New("cache is encrypted and the bundle would hold its entries in plain text")
+
+// entryFilePattern matches the file names of cache entries
+var entryFilePattern = regexp.MustCompile(`^[0-9a-f]{16}\.json$`)
+
+// bundleManifest describes an export bundle. CacheVersion is the cacheFile
+// version of the entries, which must match the importing mcpmap.
+type bundleManifest struct {
+	BundleVersion int       `json:"bundle_version"`
+	CacheVersion  int       `json:"cache_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Entries       []string  `json:"entries"`
+}
+
+// ImportResult lists the entries of an imported bundle
+type ImportResult struct {
+	Imported []FileInfo
+	// Skipped entries were kept because the local entry was cached later
+	Skipped []FileInfo
+}
+
+// Export writes the entries with the given keys, or all entries if keys is
+// empty, to w as a gzipped tar bundle and returns them. The entry files are
+// copied unchanged, so their metadata and timestamps are kept. Bundles are not
+// encrypted: the entries of an encrypted cache are only exported, decrypted,
+// with plaintext set.
+func Export(w io.Writer, keys []string, plaintext bool) ([]FileInfo, error) {
+	info, err := GetCacheInfo()
+	if err != nil {
+		return nil, err
+	}
+	if info.Encrypted && !plaintext {
+		return nil, ErrPlaintextExport
+	}
+
+	type exportFile struct {
+		info FileInfo
+		data []byte
+	}
+	var files []exportFile
+	manifest := bundleManifest{
+		BundleVersion: bundleVersion,
+		CacheVersion:  cacheVersion,
+		ExportedAt:    time.Now(),
+		Entries:       []string{},
+	}
+	for _, file := range info.Files {
+		if len(keys) > 0 && !slices.Contains(keys, file.Key) {
+			continue
+		}
+		data, err := active.export(file.Key)
+		if err != nil {
+			return nil, fmt.Errorf("read cache entry %s: %w", file.Key, err)
+		}
+		// Entries of older versions would be refused on import
+		if cf, err := parseCacheFile(data); err != nil || cf.Version != cacheVersion {
+			continue
+		}
+		files = append(files, exportFile{file, data})
+		manifest.Entries = append(manifest.Entries, file.Key+".json")
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal bundle manifest: %w", err)
+	}
+	if err := writeTarFile(tw, bundleManifestName, manifestData, manifest.ExportedAt); err != nil {
+		return nil, err
+	}
+
+	exported := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		if err := writeTarFile(tw, file.info.Key+".json", file.data, file.info.ModTime); err != nil {
+			return nil, err
+		}
+		exported = append(exported, file.info)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("write bundle: %w", err)
+	}
+	return exported, nil
+}
+
+// writeTarFile adds one file to a bundle
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	return nil
+}
+
+// bundleFile is one cache entry read from a bundle
+type bundleFile struct {
+	cf      *cacheFile
+	data    []byte
+	modTime time.Time
+}
+
+// Import reads a bundle written by Export into the active backend. The
+// whole bundle is checked before any entry is written. Local entries cached
+// later than the imported ones are kept.
+func Import(r io.Reader) (*ImportResult, error) {
+	names, files, err := readBundle(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	for _, name := range names {
+		file := files[name]
+		entry := newFileInfo(name, int64(len(file.data)), file.modTime, file.cf)
+		imported, err := active.importEntry(entry.Key, file)
+		if err != nil {
+			return result, err
+		}
+		if imported {
+			result.Imported = append(result.Imported, entry)
+		} else {
+			result.Skipped = append(result.Skipped, entry)
+		}
+	}
+	return result, nil
+}
+
+// readBundle reads and checks every entry of a bundle. It returns the entry
+// file names in bundle order and the entries by name.
+func readBundle(r io.Reader) ([]string, map[string]bundleFile, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	var manifest *bundleManifest
+	files := make(map[string]bundleFile)
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxBundleFileSize+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if len(data) > maxBundleFileSize {
+			return nil, nil, fmt.Errorf("bundle file %s is too large", header.Name)
+		}
+
+		if manifest == nil {
+			if manifest, err = parseBundleManifest(header.Name, data); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if !entryFilePattern.MatchString(header.Name) || !slices.Contains(manifest.Entries, header.Name) {
+			return nil, nil, fmt.Errorf("unexpected file %q in bundle", header.Name)
+		}
+		cf, err := parseCacheFile(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bundle entry %s: %w", header.Name, err)
+		}
+		if cf.Version != cacheVersion {
+			return nil, nil, fmt.Errorf("bundle entry %s has cache version %d, this mcpmap supports version %d",
+				header.Name, cf.Version, cacheVersion)
+		}
+		files[header.Name] = bundleFile{cf, data, header.ModTime}
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("bundle is empty")
+	}
+	for _, name := range manifest.Entries {
+		if _, ok := files[name]; !ok {
+			return nil, nil, fmt.Errorf("bundle entry %s is missing", name)
+		}
+	}
+	return manifest.Entries, files, nil
+}
+
+// export returns the cache file of an entry, decrypted if it is encrypted
+func (b filesBackend) export(key string) ([]byte, error) {
+	cacheDir := getCacheDir()
+	data, err := os.ReadFile(filepath.Join(cacheDir, key+".json"))
+	if err != nil {
+		return nil, err
+	}
+	return b.sealer.open(cacheDir, key, data)
+}
+
+// importEntry writes an imported entry unless the local one was cached
+// later, and reports whether it did
+func (b filesBackend) importEntry(key string, file bundleFile) (bool, error) {
+	cacheDir := getCacheDir()
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return false, fmt.Errorf("create cache dir: %w", err)
+	}
+	unlock, err := lockEntry(cacheDir, key)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if err := b.sealer.checkWritable(cacheDir); err != nil {
+		return false, err
+	}
+
+	filePath := filepath.Join(cacheDir, key+".json")
+	if local := readCacheFile(filePath, b.sealer); local != nil && local.Timestamp.After(file.cf.Timestamp) {
+		return false, nil
+	}
+	if err := WriteFileAtomic(filePath, b.sealer.seal(key, file.data)); err != nil {
+		return false, err
+	}
+	os.Chtimes(filePath, file.modTime, file.modTime)
+	return true, nil
+}
+
+// parseBundleManifest parses the first file of a bundle and checks that its
+// entries can be read by this version
+func parseBundleManifest(name string, data []byte) (*bundleManifest, error) {
+	if name != bundleManifestName {
+		return nil, fmt.Errorf("not an mcpmap cache bundle: %s comes before %s", name, bundleManifestName)
+	}
+	var manifest bundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parse bundle manifest: %w", err)
+	}
+	if manifest.BundleVersion != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d, this mcpmap supports version %d",
+			manifest.BundleVersion, bundleVersion)
+	}
+	if manifest.CacheVersion != cacheVersion {
+		return nil, fmt.Errorf("bundle has cache version %d, this mcpmap supports version %d",
+			manifest.CacheVersion, cacheVersion)
+	}
+	return &manifest, nil
+}
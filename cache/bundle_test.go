@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestBundle builds a bundle from name/content pairs in order
+func writeTestBundle(t *testing.T, files ...string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for i := 0; i < len(files); i += 2 {
+		if err := writeTarFile(tw, files[i], []byte(files[i+1]), time.Now()); err != nil {
+			t.Fatalf("writeTarFile: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+func TestExportImport(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	exported := New("https://one.example.com/mcp", "http", "token", "client")
+	other := New("https://two.example.com/mcp", "sse", "", "client")
+	for _, c := range []Cache{exported, other} {
+		if err := c.Save(createTestData()); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	backdate(t, exported, 48*time.Hour)
+	key := exported.(*fileCache).cacheKey
+
+	var bundle bytes.Buffer
+	files, err := Export(&bundle, []string{key}, false)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Key != key {
+		t.Fatalf("expected only %s to be exported, got %+v", key, files)
+	}
+
+	// Import on another machine
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	result, err := Import(bytes.NewReader(bundle.Bytes()))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(result.Imported) != 1 || len(result.Skipped) != 0 {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+
+	info, err := GetCacheInfo()
+	if err != nil {
+		t.Fatalf("GetCacheInfo failed: %v", err)
+	}
+	if info.TotalFiles != 1 {
+		t.Fatalf("expected 1 imported file, got %d", info.TotalFiles)
+	}
+	file := info.Files[0]
+	if file.Key != key || file.ServerURL != "https://one.example.com/mcp" || file.ToolsCount != 2 {
+		t.Errorf("unexpected imported entry %+v", file)
+	}
+	if file.Age() < 48*time.Hour {
+		t.Errorf("imported entry should keep its timestamp, age is %s", file.Age())
+	}
+
+	// The entry loads under the original key
+	data, fresh, err := New("https://one.example.com/mcp", "http", "token", "client").Load()
+	if err != nil || data == nil || fresh {
+		t.Errorf("expected stale data under the original key, got data=%v fresh=%v err=%v", data != nil, fresh, err)
+	}
+
+	// A local entry cached later is kept
+	if err := New("https://one.example.com/mcp", "http", "token", "client").Save(&CacheData{}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	result, err = Import(bytes.NewReader(bundle.Bytes()))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(result.Imported) != 0 || len(result.Skipped) != 1 {
+		t.Errorf("expected the newer local entry to be kept, got %+v", result)
+	}
+}
+
+func TestImportRejectsInvalidBundles(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	manifest := func(bundleVersion, cacheVersion int, entries ...string) string {
+		data, _ := json.Marshal(bundleManifest{BundleVersion: bundleVersion, CacheVersion: cacheVersion, Entries: entries})
+		return string(data)
+	}
+	const entry = "0123456789abcdef.json"
+	valid := `{"version":1,"timestamp":"2025-01-01T00:00:00Z","data":{"tools":[]}}`
+
+	tests := []struct {
+		name    string
+		bundle  *bytes.Buffer
+		wantErr string
+	}{
+		{"not gzip", bytes.NewBufferString("plain"), "read bundle"},
+		{"empty", writeTestBundle(t), "bundle is empty"},
+		{"no manifest", writeTestBundle(t, entry, valid), "not an mcpmap cache bundle"},
+		{"bundle version", writeTestBundle(t, bundleManifestName, manifest(2, 1)), "unsupported bundle version 2"},
+		{"cache version", writeTestBundle(t, bundleManifestName, manifest(1, 2)), "bundle has cache version 2"},
+		{"entry version", writeTestBundle(t, bundleManifestName, manifest(1, 1, entry), entry,
+			`{"version":2,"data":{}}`), "has cache version 2"},
+		{"path traversal", writeTestBundle(t, bundleManifestName, manifest(1, 1, "../x.json"), "../x.json", valid), "unexpected file"},
+		{"unlisted entry", writeTestBundle(t, bundleManifestName, manifest(1, 1), entry, valid), "unexpected file"},
+		{"missing entry", writeTestBundle(t, bundleManifestName, manifest(1, 1, entry)), "is missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(tt.bundle)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	info, err := GetCacheInfo()
+	if err != nil {
+		t.Fatalf("GetCacheInfo failed: %v", err)
+	}
+	if info.TotalFiles != 0 {
+		t.Errorf("rejected bundles must not write entries, got %+v", info.Files)
+	}
+}
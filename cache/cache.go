@@ -1,12 +1,16 @@
 package cache
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -17,45 +21,112 @@ type // Cache provides file-based caching for MCP server metadata (tools, resour
 // Implementations should be safe for concurrent reads and tolerate concurrent writes.
 Cache interface {
 	// Load retrieves cached data, returns (data, isFresh, error)
-	// isFresh is always true since we don't use TTL
+	// isFresh is false when the data is older than the TTL; stale data is
+	// still returned so callers can use it while refreshing
 	Load() (*CacheData, bool, error)
 
-	// Save stores data to cache
+	// Save stores data to cache, replacing every section
 	Save(data *CacheData) error
 
+	// Update stores the given sections of data and keeps the other sections
+	// cached along with when they were fetched
+	Update(data *CacheData, sections ...Section) error
+
 	// Delete removes this cache entry
 	Delete() error
+
+	// ClaimRefresh reports whether the caller should refresh a stale entry.
+	// It returns false while another refresh of the entry is in progress.
+	ClaimRefresh() bool
 }
 
+// cacheVersion is the version of the cache file format written by Save.
+// Files of other versions are discarded on Load and refused on Import.
+const cacheVersion = 1
+
+// DefaultTTL is how long cached data counts as fresh unless configured
+const DefaultTTL = 24 * time.Hour
+
+// refreshClaimTimeout is how long a refresh claim blocks other refreshes, so
+// a refresh that died without saving does not block them forever
+const refreshClaimTimeout = time.Minute
+
 // CacheData represents the cached MCP server information
 type // CacheData holds the MCP server metadata cached for faster subsequent access.
 CacheData struct {
 	Tools     []*mcp.Tool     `json:"tools"`
 	Resources []*mcp.Resource `json:"resources"`
 	Prompts   []*mcp.Prompt   `json:"prompts"`
+
+	ResourceTemplates []*mcp.ResourceTemplate `json:"resource_templates,omitempty"`
+
+	// ServerInfo is the implementation the server reported when the data
+	// was fetched. It is stored next to the data, not in it.
+	ServerInfo *mcp.Implementation `json:"-"`
 }
 
 // cacheFile represents the structure of the cache file on disk
 type cacheFile struct {
 	Version   int       `json:"version"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// The connection the entry belongs to, so it can be told apart from the
+	// others. The auth token only goes into the key.
+	ServerURL  string `json:"server_url,omitempty"`
+	Transport  string `json:"transport,omitempty"`
+	ClientName string `json:"client_name,omitempty"`
+
 	ServerInfo struct {
 		Name    string `json:"name"`
 		Version string `json:"version"`
 	} `json:"server_info"`
 	Data *CacheData `json:"data"`
+
+	// TTLSeconds is the TTL in effect when the file was written, 0 if the
+	// entry never goes stale
+	TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+
+	// Sections holds when each section was fetched, see sectionTime
+	Sections map[Section]time.Time `json:"sections,omitempty"`
 }
 
 // fileCache implements Cache using filesystem storage
 type fileCache struct {
-	cacheKey string
-	cacheDir string
-	filePath string
+	cacheKey      string
+	cacheDir      string
+	filePath      string
+	ttl           time.Duration
+	serverURL     string
+	transportType string
+	clientName    string
+	sealer        *sealer
 }
 
 // New creates a cache instance for the given server configuration
-// New returns a filesystem-backed Cache keyed by the supplied server connection parameters.
+// New returns a Cache of the active backend keyed by the supplied server connection parameters.
 func New(serverURL, transportType, authToken, clientName string) Cache {
+	return NewWithTTL(serverURL, transportType, authToken, clientName, DefaultTTL)
+}
+
+// NewWithTTL is like New with entries going stale after ttl. A ttl of zero
+// or less keeps entries fresh forever.
+func NewWithTTL(serverURL, transportType, authToken, clientName string, ttl time.Duration) Cache {
+	return active.Open(serverURL, transportType, authToken, clientName, ttl)
+}
+
+// filesBackend stores each entry in its own file in the cache directory
+type filesBackend struct {
+	// sealer encrypts the entries, nil if they are plain, see OpenFiles
+	sealer *sealer
+}
+
+// Files returns the default backend, one JSON file per entry in the user
+// cache directory
+func Files() Backend {
+	return filesBackend{}
+}
+
+func (b filesBackend) Open(serverURL, transportType, authToken, clientName string, ttl time.Duration) Cache {
 	cacheKey := generateCacheKey(serverURL, transportType, authToken, clientName)
 	cacheDir := getCacheDir()
 	filePath := filepath.Join(cacheDir, cacheKey+".json")
@@ -64,9 +135,42 @@ func New(serverURL, transportType, authToken, clientName string) Cache {
 		cacheKey: cacheKey,
 		cacheDir: cacheDir,
 		filePath: filePath,
+		ttl:      ttl,
+
+		serverURL:     withoutPassword(serverURL),
+		sealer:        b.sealer,
+		transportType: transportType,
+		clientName:    clientName,
 	}
 }
 
+// withoutPassword drops the password of URLs with user info, so credentials
+// in the server URL are not written to the cache
+func withoutPassword(serverURL string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.User == nil {
+		return serverURL
+	}
+	if _, ok := u.User.Password(); !ok {
+		return serverURL
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+// Disabled returns a Cache that never has data and discards saves, for --no-cache
+func Disabled() Cache {
+	return disabledCache{}
+}
+
+type disabledCache struct{}
+
+func (disabledCache) Load() (*CacheData, bool, error)     { return nil, false, nil }
+func (disabledCache) Save(*CacheData) error               { return nil }
+func (disabledCache) Update(*CacheData, ...Section) error { return nil }
+func (disabledCache) Delete() error                       { return nil }
+func (disabledCache) ClaimRefresh() bool                  { return false }
+
 // ensureDir creates the cache directory if it doesn't exist
 func (fc *fileCache) ensureDir() error {
 	if err := os.MkdirAll(fc.cacheDir, 0700); err != nil {
@@ -85,9 +189,8 @@ func generateCacheKey(serverURL, transportType, authToken, clientName string) st
 	return hex.EncodeToString(h.Sum(nil))[:16] // First 16 chars
 }
 
-// Load retrieves cached data from disk
-// Load retrieves cached data from disk. The isFresh return value is always true
-// on a successful hit because the current implementation has no TTL or staleness checks.
+// Load retrieves cached data from disk. The data is fresh if it was written
+// less than the TTL ago.
 func (fc *fileCache) Load() (*CacheData, bool, error) {
 	// Ensure cache directory exists
 	if err := fc.ensureDir(); err != nil {
@@ -103,37 +206,102 @@ func (fc *fileCache) Load() (*CacheData, bool, error) {
 		return nil, false, fmt.Errorf("read cache file: %w", err)
 	}
 
+	// A locked or wrongly keyed entry is not corrupt, so it is kept
+	plaintext, err := fc.sealer.open(fc.cacheDir, fc.cacheKey, data)
+	if err != nil {
+		return nil, false, fmt.Errorf("cache entry %s: %w", fc.cacheKey, err)
+	}
+
 	// Parse JSON
 	var cf cacheFile
-	if err := json.Unmarshal(data, &cf); err != nil {
+	if err := json.Unmarshal(plaintext, &cf); err != nil {
 		// Corrupted cache, delete and return miss
-		os.Remove(fc.filePath)
+		fc.discard(data)
 		return nil, false, nil
 	}
 
 	// Version check
-	if cf.Version != 1 {
+	if cf.Version != cacheVersion {
 		// Old version, delete and return miss
-		os.Remove(fc.filePath)
+		fc.discard(data)
 		return nil, false, nil
 	}
 
-	// isFresh is always true since we don't implement TTL
-	return cf.Data, true, nil
+	return cf.data(), fc.isFresh(cf.fetchedAt()), nil
+}
+
+// data returns the cached data with the server info stored next to it
+func (cf *cacheFile) data() *CacheData {
+	if cf.ServerInfo.Name != "" && cf.Data != nil {
+		cf.Data.ServerInfo = &mcp.Implementation{Name: cf.ServerInfo.Name, Version: cf.ServerInfo.Version}
+	}
+	return cf.Data
+}
+
+// discard deletes the entry file if it still holds data. Files are replaced
+// atomically, so Load reads without the lock, but a writer may have replaced
+// the file since.
+func (fc *fileCache) discard(data []byte) {
+	unlock, err := lockEntry(fc.cacheDir, fc.cacheKey)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	if current, err := os.ReadFile(fc.filePath); err == nil && bytes.Equal(current, data) {
+		os.Remove(fc.filePath)
+	}
+}
+
+// isFresh reports whether data written at timestamp is still within the TTL
+func (fc *fileCache) isFresh(timestamp time.Time) bool {
+	return isFresh(fc.ttl, timestamp)
+}
+
+// isFresh reports whether data fetched at timestamp is younger than ttl. A
+// ttl of zero or less never expires.
+func isFresh(ttl time.Duration, timestamp time.Time) bool {
+	return ttl <= 0 || time.Since(timestamp) < ttl
 }
 
 // Save stores data to cache using atomic writes
 func (fc *fileCache) Save(data *CacheData) error {
+	return fc.write(data, Sections)
+}
+
+// Update merges the given sections of data into the cached entry
+func (fc *fileCache) Update(data *CacheData, sections ...Section) error {
+	return fc.write(data, sections)
+}
+
+// write stores sections of data, merged with the cached entry under its lock
+// so concurrent updates of different sections are all kept
+func (fc *fileCache) write(data *CacheData, sections []Section) error {
 	// Ensure cache directory exists with secure permissions
 	if err := fc.ensureDir(); err != nil {
 		return err
 	}
+	unlock, err := lockEntry(fc.cacheDir, fc.cacheKey)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Checked under the lock so setting up encryption sees the entry, see
+	// encryptEntries
+	if err := fc.sealer.checkWritable(fc.cacheDir); err != nil {
+		return err
+	}
 
-	cf := cacheFile{
-		Version:   1,
-		Timestamp: time.Now(),
-		Data:      data,
+	var old *cacheFile
+	if len(sections) < len(Sections) {
+		old = readCacheFile(fc.filePath, fc.sealer)
 	}
+	cf := merge(old, data, sections, time.Now())
+	cf.ServerURL = fc.serverURL
+	cf.Transport = fc.transportType
+	cf.ClientName = fc.clientName
+	cf.TTLSeconds = int64(fc.ttl / time.Second)
 
 	// Marshal to JSON
 	jsonData, err := json.MarshalIndent(cf, "", "  ")
@@ -141,49 +309,168 @@ func (fc *fileCache) Save(data *CacheData) error {
 		return fmt.Errorf("marshal cache data: %w", err)
 	}
 
-	// Write atomically via temp file
-	tmpFile := fc.filePath + ".tmp"
-	if err := os.WriteFile(tmpFile, jsonData, 0600); err != nil {
-		return fmt.Errorf("write temp cache file: %w", err)
+	if err := WriteFileAtomic(fc.filePath, fc.sealer.seal(fc.cacheKey, jsonData)); err != nil {
+		return err
 	}
 
-	// Atomic rename
-	if err := os.Rename(tmpFile, fc.filePath); err != nil {
+	// Once every section is current a pending refresh claim is done
+	if fc.isFresh(cf.fetchedAt()) {
+		os.Remove(fc.claimPath())
+	}
+	return nil
+}
+
+// lockEntry takes the exclusive advisory lock of an entry, held while it is
+// written or removed. Lock files are never removed: another process may be
+// waiting on the one it opened.
+func lockEntry(cacheDir, key string) (unlock func(), err error) {
+	return lockPath(filepath.Join(cacheDir, key+".lock"))
+}
+
+// lockPath takes the exclusive advisory lock of the lock file at path
+func lockPath(path string) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open cache lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock cache entry: %w", err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// WriteFileAtomic writes data to a uniquely named temp file that only the
+// user can read, syncs it and renames it to filePath, so readers never see a
+// partially written file and a crash never leaves a truncated one. Cache
+// callers hold the entry lock.
+func WriteFileAtomic(filePath string, data []byte) error {
+	dir := filepath.Dir(filePath)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tmpFile := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
 		os.Remove(tmpFile) // Cleanup
-		return fmt.Errorf("rename cache file: %w", err)
+		return fmt.Errorf("write temp file: %w", err)
 	}
 
+	// Atomic rename
+	if err := os.Rename(tmpFile, filePath); err != nil {
+		os.Remove(tmpFile) // Cleanup
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	// Persist the rename itself
+	syncDir(dir)
 	return nil
 }
 
+// claimPath is the marker file of a refresh in progress
+func (fc *fileCache) claimPath() string {
+	return filepath.Join(fc.cacheDir, fc.cacheKey+".refresh")
+}
+
+// ClaimRefresh creates the claim marker exclusively. The claim ends when the
+// refreshed data is saved or after refreshClaimTimeout.
+func (fc *fileCache) ClaimRefresh() bool {
+	if err := fc.ensureDir(); err != nil {
+		return false
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(fc.claimPath(), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return true
+		}
+		if !os.IsExist(err) {
+			return false
+		}
+
+		// Take over claims of refreshes that never finished
+		info, err := os.Stat(fc.claimPath())
+		if err != nil || time.Since(info.ModTime()) < refreshClaimTimeout {
+			return false
+		}
+		os.Remove(fc.claimPath())
+	}
+	return false
+}
+
 // Delete removes the cache file
 func (fc *fileCache) Delete() error {
-	err := os.Remove(fc.filePath)
+	if _, err := os.Stat(fc.cacheDir); os.IsNotExist(err) {
+		return nil // Nothing to delete
+	}
+	unlock, err := lockEntry(fc.cacheDir, fc.cacheKey)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = os.Remove(fc.filePath)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("delete cache file: %w", err)
 	}
 	return nil
 }
 
-// readCacheCounts reads a cache file and returns the count of tools, resources, and prompts
-func readCacheCounts(filePath string) (tools, resources, prompts int) {
+// readCacheFile reads and parses a cache file, returning nil if it is unreadable
+func readCacheFile(filePath string, s *sealer) *cacheFile {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return 0, 0, 0
+		return nil
 	}
-	
+
+	cf, err := decodeCacheFile(filepath.Dir(filePath), entryKey(filePath), data, s)
+	if err != nil {
+		return nil
+	}
+	return cf
+}
+
+// decodeCacheFile decrypts and parses the file of the entry with the given key
+// in cacheDir
+func decodeCacheFile(cacheDir, key string, data []byte, s *sealer) (*cacheFile, error) {
+	data, err := s.open(cacheDir, key, data)
+	if err != nil {
+		return nil, err
+	}
+	return parseCacheFile(data)
+}
+
+// parseCacheFile parses the contents of a cache file
+func parseCacheFile(data []byte) (*cacheFile, error) {
 	var cf cacheFile
-	if err := json.Unmarshal(data, &cf); err != nil || cf.Data == nil {
-		return 0, 0, 0
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return nil, fmt.Errorf("parse cache file: %w", err)
 	}
-	
-	return len(cf.Data.Tools), len(cf.Data.Resources), len(cf.Data.Prompts)
+	if cf.Data == nil {
+		return nil, fmt.Errorf("cache file has no data")
+	}
+	return &cf, nil
 }
 
-// ClearAll removes all cache files from the cache directory
-// ClearAll removes all cache entry files from the user cache directory.
-// It ignores missing directories and returns an error if any file removal fails.
+// ClearAll removes all entries of the active backend
 func ClearAll() error {
+	return active.Clear()
+}
+
+// Clear removes all cache entry files from the user cache directory.
+// It ignores missing directories and returns an error if any file removal fails.
+func (filesBackend) Clear() error {
 	cacheDir := getCacheDir()
 	
 	// Check if cache directory exists
@@ -197,9 +484,10 @@ func ClearAll() error {
 		return fmt.Errorf("read cache directory: %w", err)
 	}
 	
-	// Remove all .json files (cache files)
+	// Remove all .json files (cache files), refresh claims and temp files
+	// left by interrupted writes. Lock files stay, see lockEntry.
 	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
+		if ext := filepath.Ext(entry.Name()); !entry.IsDir() && (ext == ".json" || ext == ".refresh" || ext == ".tmp") {
 			filePath := filepath.Join(cacheDir, entry.Name())
 			if err := os.Remove(filePath); err != nil {
 				return fmt.Errorf("remove cache file %s: %w", entry.Name(), err)
@@ -213,7 +501,11 @@ func ClearAll() error {
 // CacheInfo represents information about the cache
 type // CacheInfo summarizes the contents of the cache directory including total counts and per-file metadata.
 CacheInfo struct {
+	Backend    string      `json:"backend"`
 	CacheDir   string      `json:"cache_dir"`
+	Encrypted  bool        `json:"encrypted"`
+	// Locked is set when the entries are encrypted and no key was given
+	Locked     bool        `json:"locked"`
 	TotalFiles int         `json:"total_files"`
 	TotalSize  int64       `json:"total_size_bytes"`
 	Files      []FileInfo  `json:"files"`
@@ -223,23 +515,50 @@ CacheInfo struct {
 type // FileInfo describes a single cache file's size, modification time, and contained item counts.
 FileInfo struct {
 	Name         string    `json:"name"`
+	Key          string    `json:"key"`
+	ServerURL    string    `json:"server_url,omitempty"`
+	Transport    string    `json:"transport,omitempty"`
+	ClientName   string    `json:"client_name,omitempty"`
+	ServerName   string    `json:"server_name,omitempty"`
+	ServerVersion string   `json:"server_version,omitempty"`
+	Encrypted    bool      `json:"encrypted,omitempty"`
 	Size         int64     `json:"size_bytes"`
 	ModTime      time.Time `json:"modified_time"`
 	ToolsCount   int       `json:"tools_count"`
 	ResourcesCount int     `json:"resources_count"`
 	PromptsCount int       `json:"prompts_count"`
+	ResourceTemplatesCount int `json:"resource_templates_count"`
+	CachedAt     time.Time `json:"cached_at"`
+	AgeSeconds   int64     `json:"age_seconds"`
+	TTLSeconds   int64     `json:"ttl_seconds"`
+	Stale        bool      `json:"stale"`
+
+	// Sections holds when each section was fetched; missing sections never were
+	Sections map[Section]time.Time `json:"sections,omitempty"`
+}
+
+// Age returns how long ago the entry was cached
+func (fi FileInfo) Age() time.Duration {
+	return time.Duration(fi.AgeSeconds) * time.Second
 }
 
-// GetCacheInfo returns information about all cache files
-// GetCacheInfo returns information about all cache files in the cache directory.
-// Files that cannot be read or parsed are skipped silently.
+// GetCacheInfo returns information about all entries of the active backend
 func GetCacheInfo() (*CacheInfo, error) {
+	return active.Info()
+}
+
+// Info returns information about all cache files in the cache directory.
+// Files that cannot be read or parsed are listed without their contents.
+func (b filesBackend) Info() (*CacheInfo, error) {
 	cacheDir := getCacheDir()
 	
 	info := &CacheInfo{
-		CacheDir: cacheDir,
-		Files:    []FileInfo{},
+		Backend:   BackendFiles,
+		CacheDir:  cacheDir,
+		Encrypted: isEncryptedDir(cacheDir),
+		Files:     []FileInfo{},
 	}
+	info.Locked = info.Encrypted && b.sealer == nil
 	
 	// Check if cache directory exists
 	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
@@ -263,23 +582,168 @@ func GetCacheInfo() (*CacheInfo, error) {
 		if err != nil {
 			continue // Skip files we can't stat
 		}
-		
-		// Get item counts using helper
-		toolsCount, resourcesCount, promptsCount := readCacheCounts(filePath)
-		
-		cacheFileInfo := FileInfo{
-			Name:           entry.Name(),
-			Size:           fileInfo.Size(),
-			ModTime:        fileInfo.ModTime(),
-			ToolsCount:     toolsCount,
-			ResourcesCount: resourcesCount,
-			PromptsCount:   promptsCount,
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			continue
 		}
 		
+		cf, _ := decodeCacheFile(cacheDir, entryKey(filePath), data, b.sealer)
+		cacheFileInfo := newFileInfo(entry.Name(), fileInfo.Size(), fileInfo.ModTime(), cf)
+		cacheFileInfo.Encrypted = isEncrypted(data)
+		
 		info.Files = append(info.Files, cacheFileInfo)
 		info.TotalFiles++
 		info.TotalSize += fileInfo.Size()
 	}
 	
 	return info, nil
-}
\ No newline at end of file
+}
+
+// Matches reports whether query identifies the entry: its key or a prefix of
+// at least 4 characters, its file name, its server URL or the name the server
+// reported (case-insensitive)
+func (fi FileInfo) Matches(query string) bool {
+	switch {
+	case query == "":
+		return false
+	case query == fi.Key || query == fi.Name:
+		return true
+	case len(query) >= 4 && strings.HasPrefix(fi.Key, query):
+		return true
+	case fi.ServerURL != "" && strings.TrimSuffix(query, "/") == strings.TrimSuffix(fi.ServerURL, "/"):
+		return true
+	}
+	return fi.ServerName != "" && strings.EqualFold(query, fi.ServerName)
+}
+
+// entryFinder is implemented by backends that look entries up through an
+// index instead of listing every entry
+type entryFinder interface {
+	find(query string) ([]FileInfo, error)
+}
+
+// FindEntries returns the cache entries matching query, see FileInfo.Matches
+func FindEntries(query string) ([]FileInfo, error) {
+	if f, ok := active.(entryFinder); ok {
+		return f.find(query)
+	}
+	info, err := GetCacheInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []FileInfo
+	for _, file := range info.Files {
+		if file.Matches(query) {
+			matches = append(matches, file)
+		}
+	}
+	return matches, nil
+}
+
+// ReadEntry returns the data of the entry with the given key, including the
+// server info it was stored with
+func ReadEntry(key string) (*CacheData, error) {
+	return active.Read(key)
+}
+
+func (b filesBackend) Read(key string) (*CacheData, error) {
+	cacheDir := getCacheDir()
+	data, err := os.ReadFile(filepath.Join(cacheDir, key+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("cache entry %s is missing or unreadable", key)
+	}
+	cf, err := decodeCacheFile(cacheDir, key, data, b.sealer)
+	if errors.Is(err, ErrLocked) || errors.Is(err, ErrNotEncrypted) {
+		return nil, fmt.Errorf("cache entry %s: %w", key, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache entry %s is missing or unreadable", key)
+	}
+	return cf.data(), nil
+}
+
+// RemoveEntry removes the entry with the given key and its refresh claim
+func RemoveEntry(key string) error {
+	return active.Remove(key)
+}
+
+func (filesBackend) Remove(key string) error {
+	cacheDir := getCacheDir()
+	unlock, err := lockEntry(cacheDir, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, ext := range []string{".json", ".refresh"} {
+		err := os.Remove(filepath.Join(cacheDir, key+ext))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove cache entry %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Prune removes the entries cached more than olderThan ago and returns them.
+// Entries without a readable timestamp are judged by their modification time.
+func Prune(olderThan time.Duration) ([]FileInfo, error) {
+	info, err := GetCacheInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var removed []FileInfo
+	for _, file := range info.Files {
+		cachedAt := file.CachedAt
+		if cachedAt.IsZero() {
+			cachedAt = file.ModTime
+		}
+		if !cachedAt.Before(cutoff) {
+			continue
+		}
+		if err := RemoveEntry(file.Key); err != nil {
+			return removed, err
+		}
+		removed = append(removed, file)
+	}
+	return removed, nil
+}
+
+// newFileInfo describes a cache file. cf is nil if the file is unreadable.
+func newFileInfo(name string, size int64, modTime time.Time, cf *cacheFile) FileInfo {
+	fi := FileInfo{
+		Name:    name,
+		Key:     strings.TrimSuffix(name, ".json"),
+		Size:    size,
+		ModTime: modTime,
+	}
+	if cf == nil {
+		return fi
+	}
+
+	// Staleness is judged by the TTL the entry was written with and the
+	// oldest section
+	age := time.Since(cf.Timestamp)
+	fi.ToolsCount = len(cf.Data.Tools)
+	fi.ResourcesCount = len(cf.Data.Resources)
+	fi.PromptsCount = len(cf.Data.Prompts)
+	fi.ResourceTemplatesCount = len(cf.Data.ResourceTemplates)
+	fi.CachedAt = cf.Timestamp
+	fi.AgeSeconds = int64(age / time.Second)
+	fi.TTLSeconds = cf.TTLSeconds
+	fi.Stale = cf.TTLSeconds > 0 && time.Since(cf.fetchedAt()) >= time.Duration(cf.TTLSeconds)*time.Second
+	fi.Sections = make(map[Section]time.Time)
+	for _, s := range Sections {
+		if t := cf.sectionTime(s); !t.IsZero() {
+			fi.Sections[s] = t
+		}
+	}
+	fi.ServerURL = cf.ServerURL
+	fi.Transport = cf.Transport
+	fi.ClientName = cf.ClientName
+	fi.ServerName = cf.ServerInfo.Name
+	fi.ServerVersion = cf.ServerInfo.Version
+	return fi
+}
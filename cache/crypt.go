@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrLocked is returned when reading or writing encrypted entries without a key
+var ErrLocked = errors.New("cache is encrypted and no passphrase or key file was given")
+
+// ErrWrongKey is returned when the passphrase or key file does not match the
+// one the cache was encrypted with
+var ErrWrongKey = errors.New("wrong cache passphrase or key file")
+
+// ErrNotEncrypted is returned for a plain entry in an encrypted cache
+// directory, which was not written by mcpmap
+var ErrNotEncrypted = errors.New("cache entry is not encrypted but the cache is")
+
+// encryptionMetaName is the file in the cache directory that marks it as
+// encrypted and holds the key derivation parameters. It is not secret.
+const encryptionMetaName = "encryption.meta"
+
+// encryptedMagic starts every encrypted entry file, followed by the nonce
+// and the sealed cache file
+var encryptedMagic = []byte("mcpmap-encrypted-v1\n")
+
+// keyCheck is sealed into the meta file to tell a wrong key from a corrupt entry
+const keyCheck = "mcpmap cache key check"
+
+// kdfIterations is the PBKDF2 work factor for new encrypted caches. Existing
+// caches keep the one stored in their meta file.
+var kdfIterations = 600000
+
+// encryptionMeta is the contents of the meta file
+type encryptionMeta struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Check      []byte `json:"check"`
+}
+
+// sealer encrypts entry files with AES-256-GCM. The entry key is the
+// additional data, so an entry cannot be passed off as another. A nil sealer
+// reads and writes plain entries and refuses encrypted ones.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+// OpenFiles returns the files backend. With a secret, a passphrase or the
+// contents of a key file, entries are encrypted with a key derived from it;
+// the first use makes the cache directory encrypted. Without a secret the
+// entries of an encrypted cache directory cannot be read or written.
+func OpenFiles(secret []byte) (Backend, error) {
+	if len(secret) == 0 {
+		return Files(), nil
+	}
+	s, err := unlock(getCacheDir(), secret)
+	if err != nil {
+		return nil, err
+	}
+	return filesBackend{sealer: s}, nil
+}
+
+// unlock derives the entry key of the cache directory from secret, setting
+// up encryption and encrypting the existing entries if the directory is not
+// encrypted yet
+func unlock(cacheDir string, secret []byte) (*sealer, error) {
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	// Two processes setting up encryption must agree on one salt
+	unlockMeta, err := lockPath(filepath.Join(cacheDir, "encryption.lock"))
+	if err != nil {
+		return nil, err
+	}
+	defer unlockMeta()
+
+	metaPath := filepath.Join(cacheDir, encryptionMetaName)
+	data, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		s, err := createEncryptionMeta(metaPath, secret)
+		if err != nil {
+			return nil, err
+		}
+		if err := encryptEntries(cacheDir, s); err != nil {
+			return nil, fmt.Errorf("encrypt existing cache entries: %w", err)
+		}
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", encryptionMetaName, err)
+	}
+
+	var meta encryptionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", encryptionMetaName, err)
+	}
+	if meta.Version != 1 || meta.KDF != "pbkdf2-sha256" || meta.Iterations < 1 || len(meta.Salt) == 0 {
+		return nil, fmt.Errorf("unsupported cache encryption in %s", metaPath)
+	}
+	s, err := newSealer(secret, meta.Salt, meta.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if check, err := s.open(cacheDir, "check", meta.Check); err != nil || string(check) != keyCheck {
+		return nil, fmt.Errorf("%w for %s", ErrWrongKey, cacheDir)
+	}
+	return s, nil
+}
+
+// createEncryptionMeta sets up encryption with a new random salt
+func createEncryptionMeta(metaPath string, secret []byte) (*sealer, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	s, err := newSealer(secret, salt, kdfIterations)
+	if err != nil {
+		return nil, err
+	}
+	meta := encryptionMeta{
+		Version:    1,
+		KDF:        "pbkdf2-sha256",
+		Iterations: kdfIterations,
+		Salt:       salt,
+		Check:      s.seal("check", []byte(keyCheck)),
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s: %w", encryptionMetaName, err)
+	}
+	if err := WriteFileAtomic(metaPath, data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// encryptEntries encrypts the entries written before encryption was set up.
+// Writers check for the meta file under the entry lock and lock files are
+// never removed, so an entry being written in plain text right now has a lock
+// file and is encrypted once its writer is done.
+func encryptEntries(cacheDir string, s *sealer) error {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || name == "encryption.lock" || ext != ".json" && ext != ".lock" {
+			continue
+		}
+		keys[strings.TrimSuffix(name, ext)] = true
+	}
+	for key := range keys {
+		if err := encryptEntry(cacheDir, key, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptEntry encrypts the entry with the given key under its lock if it is
+// a plain one, keeping its modification time
+func encryptEntry(cacheDir, key string, s *sealer) error {
+	unlock, err := lockEntry(cacheDir, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	filePath := filepath.Join(cacheDir, key+".json")
+	fileInfo, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if isEncrypted(data) {
+		return nil
+	}
+	if err := WriteFileAtomic(filePath, s.seal(key, data)); err != nil {
+		return err
+	}
+	os.Chtimes(filePath, fileInfo.ModTime(), fileInfo.ModTime())
+	return nil
+}
+
+func newSealer(secret, salt []byte, iterations int) (*sealer, error) {
+	block, err := aes.NewCipher(pbkdf2.Key(secret, salt, iterations, 32, sha256.New))
+	if err != nil {
+		return nil, fmt.Errorf("set up cache encryption: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("set up cache encryption: %w", err)
+	}
+	return &sealer{aead}, nil
+}
+
+// seal encrypts the cache file of the entry with the given key
+func (s *sealer) seal(key string, plaintext []byte) []byte {
+	if s == nil {
+		return plaintext
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("generate nonce: %v", err))
+	}
+	out := append(slices.Clone(encryptedMagic), nonce...)
+	return s.aead.Seal(out, nonce, plaintext, []byte(key))
+}
+
+// open decrypts the file of the entry with the given key in cacheDir. Plain
+// files are only accepted while the directory is not encrypted: setting up
+// encryption encrypts the existing entries.
+func (s *sealer) open(cacheDir, key string, data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		if s != nil || isEncryptedDir(cacheDir) {
+			return nil, ErrNotEncrypted
+		}
+		return data, nil
+	}
+	if s == nil {
+		return nil, ErrLocked
+	}
+	sealed := data[len(encryptedMagic):]
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted cache file is truncated")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt cache file: %w", err)
+	}
+	return plaintext, nil
+}
+
+// isEncrypted reports whether data is an encrypted entry file
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// checkWritable returns ErrLocked when s cannot write to the encrypted cache
+// directory cacheDir, so plain entries never end up next to encrypted ones
+func (s *sealer) checkWritable(cacheDir string) error {
+	if s == nil && isEncryptedDir(cacheDir) {
+		return ErrLocked
+	}
+	return nil
+}
+
+// isEncryptedDir reports whether encryption was set up for cacheDir
+func isEncryptedDir(cacheDir string) bool {
+	_, err := os.Stat(filepath.Join(cacheDir, encryptionMetaName))
+	return err == nil
+}
+
+// entryKey returns the key of the entry stored in the file at filePath
+func entryKey(filePath string) string {
+	return strings.TrimSuffix(filepath.Base(filePath), ".json")
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fastKDF lowers the PBKDF2 work factor for the rest of the test
+func fastKDF(t *testing.T) {
+	t.Helper()
+	prev := kdfIterations
+	kdfIterations = 1000
+	t.Cleanup(func() { kdfIterations = prev })
+}
+
+func TestEncryptedCache(t *testing.T) {
+	fastKDF(t)
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+
+	// A plain entry written before encryption was set up
+	plain := New("https://plain.example.com/mcp", "http", "", "client")
+	if err := plain.Save(createTestData()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	b, err := OpenFiles([]byte("correct horse"))
+	if err != nil {
+		t.Fatalf("OpenFiles failed: %v", err)
+	}
+	useBackend(t, b)
+
+	c := New("https://secret.example.com/mcp", "http", "", "client")
+	if err := c.Save(createTestData()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	raw, err := os.ReadFile(c.(*fileCache).filePath)
+	if err != nil {
+		t.Fatalf("read entry: %v", err)
+	}
+	if !isEncrypted(raw) || bytes.Contains(raw, []byte("test-tool")) || bytes.Contains(raw, []byte("secret.example.com")) {
+		t.Fatal("entry is not encrypted")
+	}
+
+	data, fresh, err := c.Load()
+	if err != nil || data == nil || !fresh || len(data.Tools) != 2 {
+		t.Fatalf("Load failed: data=%v fresh=%v err=%v", data, fresh, err)
+	}
+	// Setting up encryption encrypted the plain entry
+	plainRaw, err := os.ReadFile(plain.(*fileCache).filePath)
+	if err != nil || !isEncrypted(plainRaw) || bytes.Contains(plainRaw, []byte("plain.example.com")) {
+		t.Errorf("entry written before encryption was set up is not encrypted: err=%v", err)
+	}
+	if data, _, err := New("https://plain.example.com/mcp", "http", "", "client").Load(); err != nil || data == nil {
+		t.Errorf("encrypted plain entry should stay readable: data=%v err=%v", data != nil, err)
+	}
+
+	// A plain entry planted in the encrypted cache is refused, with or without a key
+	planted := filepath.Join(getCacheDir(), "fedcba9876543210.json")
+	os.WriteFile(planted, []byte(`{"version": 1}`), 0600)
+	if _, err := ReadEntry("fedcba9876543210"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("ReadEntry of a plain entry = %v, want ErrNotEncrypted", err)
+	}
+	Use(Files())
+	if _, err := ReadEntry("fedcba9876543210"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("ReadEntry of a plain entry without a key = %v, want ErrNotEncrypted", err)
+	}
+	Use(b)
+	os.Remove(planted)
+
+	info, err := GetCacheInfo()
+	if err != nil {
+		t.Fatalf("GetCacheInfo failed: %v", err)
+	}
+	if !info.Encrypted || info.Locked {
+		t.Errorf("got encrypted=%v locked=%v, want an unlocked encrypted cache", info.Encrypted, info.Locked)
+	}
+	for _, file := range info.Files {
+		if file.ToolsCount != 2 {
+			t.Errorf("entry %s: got %d tools after unlocking, want 2", file.Key, file.ToolsCount)
+		}
+	}
+
+	// An entry renamed to another key does not decrypt
+	other := filepath.Join(getCacheDir(), "0123456789abcdef.json")
+	os.WriteFile(other, raw, 0600)
+	if _, err := ReadEntry("0123456789abcdef"); err == nil {
+		t.Error("expected an error for an entry moved to another key")
+	}
+	os.Remove(other)
+
+	// A wrong key is refused up front
+	if _, err := OpenFiles([]byte("wrong horse")); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("OpenFiles with a wrong key = %v, want ErrWrongKey", err)
+	}
+
+	// Without a key the entries are locked and not overwritten in plain text
+	Use(Files())
+	locked := New("https://secret.example.com/mcp", "http", "", "client")
+	if _, _, err := locked.Load(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Load without a key = %v, want ErrLocked", err)
+	}
+	if err := locked.Save(createTestData()); !errors.Is(err, ErrLocked) {
+		t.Errorf("Save without a key = %v, want ErrLocked", err)
+	}
+	if _, err := ReadEntry(c.(*fileCache).cacheKey); !errors.Is(err, ErrLocked) {
+		t.Errorf("ReadEntry without a key = %v, want ErrLocked", err)
+	}
+	if _, err := os.Stat(c.(*fileCache).filePath); err != nil {
+		t.Errorf("locked entry was removed: %v", err)
+	}
+	info, _ = GetCacheInfo()
+	if !info.Locked {
+		t.Error("cache without a key should be locked")
+	}
+	for _, file := range info.Files {
+		if file.Encrypted && file.ToolsCount != 0 {
+			t.Errorf("locked entry %s reports its contents", file.Key)
+		}
+	}
+}
+
+func TestEncryptedExportImport(t *testing.T) {
+	fastKDF(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	b, err := OpenFiles([]byte("export key"))
+	if err != nil {
+		t.Fatalf("OpenFiles failed: %v", err)
+	}
+	useBackend(t, b)
+	c := New("https://secret.example.com/mcp", "http", "", "client")
+	if err := c.Save(createTestData()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Bundles are plain text, so an encrypted cache is only exported on request
+	var bundle bytes.Buffer
+	if _, err := Export(&bundle, nil, false); !errors.Is(err, ErrPlaintextExport) {
+		t.Fatalf("Export without plaintext = %v, want ErrPlaintextExport", err)
+	}
+	if _, err := Export(&bundle, nil, true); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	// Import into another encrypted cache with its own key
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	b, err = OpenFiles([]byte("import key"))
+	if err != nil {
+		t.Fatalf("OpenFiles failed: %v", err)
+	}
+	Use(b)
+	result, err := Import(&bundle)
+	if err != nil || len(result.Imported) != 1 {
+		t.Fatalf("Import = %v, %v", result, err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(getCacheDir(), result.Imported[0].Key+".json"))
+	if !isEncrypted(raw) || strings.Contains(string(raw), "secret.example.com") {
+		t.Error("imported entry is not encrypted")
+	}
+	if data, err := ReadEntry(result.Imported[0].Key); err != nil || len(data.Tools) != 2 {
+		t.Errorf("ReadEntry = %v, %v", data, err)
+	}
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	stressWorkerEnv = "MCPMAP_CACHE_STRESS_WORKER"
+	stressWorkers   = 8
+	stressRounds    = 40
+)
+
+// stressData returns data whose sections all have n entries named after worker
+func stressData(worker string, n int) *CacheData {
+	data := &CacheData{}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("%s-%d", worker, i)
+		data.Tools = append(data.Tools, &mcp.Tool{Name: name})
+		data.Resources = append(data.Resources, &mcp.Resource{URI: "test://" + name, Name: name})
+		data.Prompts = append(data.Prompts, &mcp.Prompt{Name: name})
+	}
+	return data
+}
+
+// checkStressData fails unless data was written entirely by one Save
+func checkStressData(t *testing.T, data *CacheData) {
+	t.Helper()
+	if len(data.Tools) == 0 || len(data.Tools) != len(data.Resources) || len(data.Tools) != len(data.Prompts) {
+		t.Fatalf("torn entry: %d tools, %d resources, %d prompts", len(data.Tools), len(data.Resources), len(data.Prompts))
+	}
+	worker, _, _ := strings.Cut(data.Tools[0].Name, "-")
+	for i, tool := range data.Tools {
+		if want := fmt.Sprintf("%s-%d", worker, i); tool.Name != want || data.Prompts[i].Name != want {
+			t.Fatalf("mixed entry: tool %d is %s, want %s", i, tool.Name, want)
+		}
+	}
+}
+
+// TestCacheStressWorker is run in the child processes of TestCacheStress
+func TestCacheStressWorker(t *testing.T) {
+	worker := os.Getenv(stressWorkerEnv)
+	if worker == "" {
+		t.Skip("only run by TestCacheStress")
+	}
+
+	c := New("stress-url", "http", "", "client")
+	for i := 1; i <= stressRounds; i++ {
+		if err := c.Save(stressData(worker, i)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		data, _, err := c.Load()
+		if err != nil || data == nil {
+			t.Fatalf("Load failed: data=%v err=%v", data != nil, err)
+		}
+		checkStressData(t, data)
+		c.ClaimRefresh()
+	}
+}
+
+func TestCacheStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping multi-process stress test in short mode")
+	}
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, stressWorkers)
+	for w := 0; w < stressWorkers; w++ {
+		wg.Add(1)
+		go func(worker string) {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestCacheStressWorker$", "-test.count=1")
+			cmd.Env = append(os.Environ(), stressWorkerEnv+"="+worker)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("worker %s: %v\n%s", worker, err, out)
+			}
+		}(fmt.Sprintf("w%d", w))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	data, _, err := New("stress-url", "http", "", "client").Load()
+	if err != nil || data == nil {
+		t.Fatalf("Load failed: data=%v err=%v", data != nil, err)
+	}
+	checkStressData(t, data)
+
+	leftovers, _ := filepath.Glob(filepath.Join(cacheHome, "mcpmap", "*.tmp"))
+	if len(leftovers) > 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import "time"
+
+// Section names one list of CacheData. Sections are fetched and cached
+// independently, each with its own timestamp.
+type Section string
+
+const (
+	SectionTools             Section = "tools"
+	SectionResources         Section = "resources"
+	SectionResourceTemplates Section = "resource_templates"
+	SectionPrompts           Section = "prompts"
+)
+
+// Sections lists every section of CacheData
+var Sections = []Section{SectionTools, SectionResources, SectionResourceTemplates, SectionPrompts}
+
+// copySection sets section s of d to the one of from
+func (d *CacheData) copySection(s Section, from *CacheData) {
+	switch s {
+	case SectionTools:
+		d.Tools = from.Tools
+	case SectionResources:
+		d.Resources = from.Resources
+	case SectionResourceTemplates:
+		d.ResourceTemplates = from.ResourceTemplates
+	case SectionPrompts:
+		d.Prompts = from.Prompts
+	}
+}
+
+// sectionTime returns when section s was fetched, or the zero time if it
+// never was. Files written before sections had their own timestamps hold
+// sections fetched together at Timestamp.
+func (cf *cacheFile) sectionTime(s Section) time.Time {
+	if cf.Sections == nil {
+		return cf.Timestamp
+	}
+	return cf.Sections[s]
+}
+
+// fetchedAt returns when the oldest section was fetched, the zero time if
+// any section is missing. An entry is only as fresh as its oldest section.
+func (cf *cacheFile) fetchedAt() time.Time {
+	var oldest time.Time
+	for i, s := range Sections {
+		t := cf.sectionTime(s)
+		if t.IsZero() {
+			return time.Time{}
+		}
+		if i == 0 || t.Before(oldest) {
+			oldest = t
+		}
+	}
+	return oldest
+}
+
+// merge returns a file holding the sections of data listed in sections,
+// fetched at now, and the other sections of old. old may be nil.
+func merge(old *cacheFile, data *CacheData, sections []Section, now time.Time) *cacheFile {
+	cf := &cacheFile{
+		Version:   cacheVersion,
+		Timestamp: now,
+		Data:      &CacheData{},
+		Sections:  make(map[Section]time.Time),
+	}
+	if old != nil && old.Version == cacheVersion {
+		cf.ServerInfo = old.ServerInfo
+		for _, s := range Sections {
+			if t := old.sectionTime(s); !t.IsZero() {
+				cf.Data.copySection(s, old.Data)
+				cf.Sections[s] = t
+			}
+		}
+	}
+
+	if data == nil {
+		data = &CacheData{}
+	}
+	for _, s := range sections {
+		cf.Data.copySection(s, data)
+		cf.Sections[s] = now
+	}
+	if data.ServerInfo != nil {
+		cf.ServerInfo.Name = data.ServerInfo.Name
+		cf.ServerInfo.Version = data.ServerInfo.Version
+	}
+	return cf
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestCacheUpdateMerges(t *testing.T) {
+	newTools := &CacheData{Tools: []*mcp.Tool{{Name: "new-tool"}}}
+
+	tests := []struct {
+		name          string
+		setup         func(t *testing.T, c Cache)
+		update        func(c Cache) error
+		wantTools     int
+		wantResources int
+		wantPrompts   int
+		wantFresh     bool
+	}{
+		{
+			name:  "tools only keeps the other sections",
+			setup: func(t *testing.T, c Cache) { c.Save(createTestData()) },
+			update: func(c Cache) error {
+				return c.Update(newTools, SectionTools)
+			},
+			wantTools: 1, wantResources: 2, wantPrompts: 2, wantFresh: true,
+		},
+		{
+			name: "old sections keep their timestamps",
+			setup: func(t *testing.T, c Cache) {
+				c.Save(createTestData())
+				backdate(t, c, 3*time.Hour)
+			},
+			update: func(c Cache) error {
+				return c.Update(newTools, SectionTools)
+			},
+			wantTools: 1, wantResources: 2, wantPrompts: 2, wantFresh: false,
+		},
+		{
+			name:  "new entry misses the other sections",
+			setup: func(t *testing.T, c Cache) {},
+			update: func(c Cache) error {
+				return c.Update(newTools, SectionTools)
+			},
+			wantTools: 1, wantFresh: false,
+		},
+		{
+			name:  "empty section replaces cached one",
+			setup: func(t *testing.T, c Cache) { c.Save(createTestData()) },
+			update: func(c Cache) error {
+				return c.Update(&CacheData{Prompts: []*mcp.Prompt{}}, SectionPrompts)
+			},
+			wantTools: 2, wantResources: 2, wantPrompts: 0, wantFresh: true,
+		},
+		{
+			name:  "save replaces every section",
+			setup: func(t *testing.T, c Cache) { c.Save(createTestData()) },
+			update: func(c Cache) error {
+				return c.Save(newTools)
+			},
+			wantTools: 1, wantFresh: true,
+		},
+		{
+			name: "files without section timestamps",
+			setup: func(t *testing.T, c Cache) {
+				c.Save(createTestData())
+				fc := c.(*fileCache)
+				cf := readCacheFile(fc.filePath, nil)
+				cf.Sections = nil
+				data, _ := json.Marshal(cf)
+				os.WriteFile(fc.filePath, data, 0600)
+			},
+			update: func(c Cache) error {
+				return c.Update(newTools, SectionTools)
+			},
+			wantTools: 1, wantResources: 2, wantPrompts: 2, wantFresh: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			c := NewWithTTL(fmt.Sprintf("merge-url-%d", i), "http", "", "client", time.Hour)
+			tt.setup(t, c)
+			if err := tt.update(c); err != nil {
+				t.Fatalf("update failed: %v", err)
+			}
+
+			data, fresh, err := c.Load()
+			if err != nil || data == nil {
+				t.Fatalf("Load failed: data=%v err=%v", data != nil, err)
+			}
+			if len(data.Tools) != tt.wantTools || len(data.Resources) != tt.wantResources || len(data.Prompts) != tt.wantPrompts {
+				t.Errorf("got %d tools, %d resources, %d prompts, want %d, %d, %d",
+					len(data.Tools), len(data.Resources), len(data.Prompts), tt.wantTools, tt.wantResources, tt.wantPrompts)
+			}
+			if fresh != tt.wantFresh {
+				t.Errorf("fresh = %v, want %v", fresh, tt.wantFresh)
+			}
+		})
+	}
+}
+
+func TestCacheInfoSections(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c := New("sections-url", "http", "", "client")
+	if err := c.Update(&CacheData{Tools: []*mcp.Tool{{Name: "a"}}}, SectionTools); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	info, err := GetCacheInfo()
+	if err != nil {
+		t.Fatalf("GetCacheInfo failed: %v", err)
+	}
+	file := info.Files[0]
+	if _, ok := file.Sections[SectionTools]; !ok || len(file.Sections) != 1 {
+		t.Errorf("expected only the tools section, got %v", file.Sections)
+	}
+	if !file.Stale {
+		t.Error("an entry missing sections should be stale")
+	}
+}
+
+func TestConcurrentSectionUpdates(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	data := createTestData()
+	data.ResourceTemplates = []*mcp.ResourceTemplate{{URITemplate: "test://{id}", Name: "template"}}
+
+	// Each section is updated by its own cache instance, as separate
+	// processes would
+	var wg sync.WaitGroup
+	for _, section := range Sections {
+		wg.Add(1)
+		go func(section Section) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := New("concurrent-url", "http", "", "client").Update(data, section); err != nil {
+					t.Errorf("Update %s failed: %v", section, err)
+				}
+			}
+		}(section)
+	}
+	wg.Wait()
+
+	loaded, fresh, err := New("concurrent-url", "http", "", "client").Load()
+	if err != nil || loaded == nil {
+		t.Fatalf("Load failed: data=%v err=%v", loaded != nil, err)
+	}
+	if len(loaded.Tools) != 2 || len(loaded.Resources) != 2 || len(loaded.ResourceTemplates) != 1 || len(loaded.Prompts) != 2 {
+		t.Errorf("an update was lost: %d tools, %d resources, %d templates, %d prompts",
+			len(loaded.Tools), len(loaded.Resources), len(loaded.ResourceTemplates), len(loaded.Prompts))
+	}
+	if !fresh {
+		t.Error("entry with every section updated should be fresh")
+	}
+}
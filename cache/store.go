@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// storeData holds the entries of a map backend
+type storeData struct {
+	// Entries holds the encoded cache file of each entry by key
+	Entries map[string]json.RawMessage `json:"entries"`
+	// Claims holds when a refresh of an entry was claimed, see ClaimRefresh
+	Claims map[string]time.Time `json:"claims,omitempty"`
+}
+
+func newStoreData() *storeData {
+	return &storeData{
+		Entries: make(map[string]json.RawMessage),
+		Claims:  make(map[string]time.Time),
+	}
+}
+
+// file returns the parsed entry with the given key, nil if there is none or
+// it cannot be read
+func (d *storeData) file(key string) *cacheFile {
+	data, ok := d.Entries[key]
+	if !ok {
+		return nil
+	}
+	cf, err := parseCacheFile(data)
+	if err != nil || cf.Version != cacheVersion {
+		return nil
+	}
+	return cf
+}
+
+// mapBackend keeps every entry in one map of encoded cache files: in memory
+// or read from a snapshot bundle
+type mapBackend struct {
+	name     string
+	location string
+	readOnly bool
+
+	mu   sync.Mutex
+	data *storeData
+}
+
+// NewMemory returns a backend keeping entries in memory for the lifetime of
+// the process, for tests and library use
+func NewMemory() Backend {
+	return &mapBackend{name: BackendMemory, location: "memory", data: newStoreData()}
+}
+
+// OpenSnapshot returns a read-only backend serving the entries of a bundle
+// written by Export. Entries never go stale for refresh purposes, as nothing
+// can be refreshed.
+func OpenSnapshot(path string) (Backend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	names, files, err := readBundle(f)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	b := &mapBackend{name: BackendSnapshot, location: path, readOnly: true, data: newStoreData()}
+	for _, name := range names {
+		b.data.Entries[strings.TrimSuffix(name, ".json")] = files[name].data
+	}
+	return b, nil
+}
+
+// view calls fn with the current entries
+func (b *mapBackend) view(fn func(d *storeData) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return fn(b.data)
+}
+
+// update calls fn to modify the entries
+func (b *mapBackend) update(fn func(d *storeData) error) error {
+	if b.readOnly {
+		return ErrReadOnly
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return fn(b.data)
+}
+
+func (b *mapBackend) Open(serverURL, transportType, authToken, clientName string, ttl time.Duration) Cache {
+	return &mapCache{
+		backend:       b,
+		key:           generateCacheKey(serverURL, transportType, authToken, clientName),
+		ttl:           ttl,
+		serverURL:     withoutPassword(serverURL),
+		transportType: transportType,
+		clientName:    clientName,
+	}
+}
+
+func (b *mapBackend) Info() (*CacheInfo, error) {
+	info := &CacheInfo{
+		Backend:  b.name,
+		CacheDir: b.location,
+		Files:    []FileInfo{},
+	}
+	err := b.view(func(d *storeData) error {
+		for _, key := range slices.Sorted(maps.Keys(d.Entries)) {
+			cf := d.file(key)
+			var modTime time.Time
+			if cf != nil {
+				modTime = cf.Timestamp
+			}
+			size := int64(len(d.Entries[key]))
+			info.Files = append(info.Files, newFileInfo(key+".json", size, modTime, cf))
+			info.TotalFiles++
+			info.TotalSize += size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (b *mapBackend) Read(key string) (*CacheData, error) {
+	var cf *cacheFile
+	if err := b.view(func(d *storeData) error {
+		cf = d.file(key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if cf == nil {
+		return nil, fmt.Errorf("cache entry %s is missing or unreadable", key)
+	}
+	return cf.data(), nil
+}
+
+func (b *mapBackend) Remove(key string) error {
+	return b.update(func(d *storeData) error {
+		delete(d.Entries, key)
+		delete(d.Claims, key)
+		return nil
+	})
+}
+
+func (b *mapBackend) Clear() error {
+	return b.update(func(d *storeData) error {
+		clear(d.Entries)
+		clear(d.Claims)
+		return nil
+	})
+}
+
+func (b *mapBackend) export(key string) ([]byte, error) {
+	var data []byte
+	err := b.view(func(d *storeData) error {
+		entry, ok := d.Entries[key]
+		if !ok {
+			return fmt.Errorf("cache entry %s is missing", key)
+		}
+		data = slices.Clone(entry)
+		return nil
+	})
+	return data, err
+}
+
+func (b *mapBackend) importEntry(key string, file bundleFile) (bool, error) {
+	imported := false
+	err := b.update(func(d *storeData) error {
+		if local := d.file(key); local != nil && local.Timestamp.After(file.cf.Timestamp) {
+			return nil
+		}
+		d.Entries[key] = file.data
+		imported = true
+		return nil
+	})
+	return imported, err
+}
+
+// mapCache is the Cache of one entry of a mapBackend
+type mapCache struct {
+	backend *mapBackend
+	key     string
+	ttl     time.Duration
+
+	serverURL     string
+	transportType string
+	clientName    string
+}
+
+func (c *mapCache) Load() (*CacheData, bool, error) {
+	var cf *cacheFile
+	if err := c.backend.view(func(d *storeData) error {
+		cf = d.file(c.key)
+		return nil
+	}); err != nil || cf == nil {
+		return nil, false, err
+	}
+	return cf.data(), isFresh(c.ttl, cf.fetchedAt()), nil
+}
+
+func (c *mapCache) Save(data *CacheData) error {
+	return c.write(data, Sections)
+}
+
+func (c *mapCache) Update(data *CacheData, sections ...Section) error {
+	return c.write(data, sections)
+}
+
+// write merges sections of data into the entry, as fileCache.write does
+func (c *mapCache) write(data *CacheData, sections []Section) error {
+	return c.backend.update(func(d *storeData) error {
+		var old *cacheFile
+		if len(sections) < len(Sections) {
+			old = d.file(c.key)
+		}
+		cf := merge(old, data, sections, time.Now())
+		cf.ServerURL = c.serverURL
+		cf.Transport = c.transportType
+		cf.ClientName = c.clientName
+		cf.TTLSeconds = int64(c.ttl / time.Second)
+
+		encoded, err := json.Marshal(cf)
+		if err != nil {
+			return fmt.Errorf("marshal cache data: %w", err)
+		}
+		d.Entries[c.key] = encoded
+		if isFresh(c.ttl, cf.fetchedAt()) {
+			delete(d.Claims, c.key)
+		}
+		return nil
+	})
+}
+
+func (c *mapCache) Delete() error {
+	return c.backend.Remove(c.key)
+}
+
+// ClaimRefresh records the claim in the backend. A read-only backend never
+// refreshes.
+func (c *mapCache) ClaimRefresh() bool {
+	claimed := false
+	c.backend.update(func(d *storeData) error {
+		if at, ok := d.Claims[c.key]; ok && time.Since(at) < refreshClaimTimeout {
+			return nil
+		}
+		d.Claims[c.key] = time.Now()
+		claimed = true
+		return nil
+	})
+	return claimed
+}
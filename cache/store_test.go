@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	bolt "go.etcd.io/bbolt"
+)
+
+// useBackend makes b the active backend for the rest of the test
+func useBackend(t *testing.T, b Backend) {
+	t.Helper()
+	prev := active
+	Use(b)
+	t.Cleanup(func() { Use(prev) })
+}
+
+func TestBackends(t *testing.T) {
+	tests := []struct {
+		name string
+		open func(t *testing.T) Backend
+	}{
+		{"files", func(t *testing.T) Backend { return Files() }},
+		{"memory", func(t *testing.T) Backend { return NewMemory() }},
+		{"single-file", func(t *testing.T) Backend {
+			return OpenSingleFile(filepath.Join(t.TempDir(), "cache.db"))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			b := tt.open(t)
+			useBackend(t, b)
+
+			c := New("https://one.example.com/mcp", "http", "token", "client")
+			if data, _, err := c.Load(); err != nil || data != nil {
+				t.Fatalf("expected a miss, got data=%v err=%v", data != nil, err)
+			}
+			if err := c.Save(createTestData()); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			if err := c.Update(&CacheData{Tools: []*mcp.Tool{{Name: "new-tool"}}}, SectionTools); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			New("https://two.example.com/mcp", "sse", "", "client").Save(createTestData())
+
+			data, fresh, err := c.Load()
+			if err != nil || data == nil || !fresh {
+				t.Fatalf("Load failed: data=%v fresh=%v err=%v", data != nil, fresh, err)
+			}
+			if len(data.Tools) != 1 || len(data.Prompts) != 2 {
+				t.Errorf("got %d tools and %d prompts, want 1 and 2", len(data.Tools), len(data.Prompts))
+			}
+			if !c.ClaimRefresh() || c.ClaimRefresh() {
+				t.Error("expected exactly one refresh claim")
+			}
+
+			entries, err := FindEntries("https://one.example.com/mcp")
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("FindEntries = %v, %v", entries, err)
+			}
+			if entries[0].Transport != "http" || entries[0].ToolsCount != 1 {
+				t.Errorf("unexpected entry %+v", entries[0])
+			}
+			if read, err := ReadEntry(entries[0].Key); err != nil || len(read.Tools) != 1 {
+				t.Errorf("ReadEntry = %v, %v", read, err)
+			}
+
+			if err := RemoveEntry(entries[0].Key); err != nil {
+				t.Fatalf("RemoveEntry failed: %v", err)
+			}
+			info, err := GetCacheInfo()
+			if err != nil {
+				t.Fatalf("GetCacheInfo failed: %v", err)
+			}
+			if info.Backend != tt.name || info.TotalFiles != 1 {
+				t.Errorf("got backend %s with %d entries, want %s with 1", info.Backend, info.TotalFiles, tt.name)
+			}
+
+			if err := ClearAll(); err != nil {
+				t.Fatalf("ClearAll failed: %v", err)
+			}
+			if info, _ := GetCacheInfo(); info.TotalFiles != 0 {
+				t.Errorf("%d entries left after ClearAll", info.TotalFiles)
+			}
+		})
+	}
+}
+
+func TestSingleFileSharedBetweenInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	writer := OpenSingleFile(path).Open("https://one.example.com/mcp", "http", "", "client", time.Hour)
+	if err := writer.Save(createTestData()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reader := OpenSingleFile(path).Open("https://one.example.com/mcp", "http", "", "client", time.Hour)
+	data, fresh, err := reader.Load()
+	if err != nil || data == nil || !fresh {
+		t.Fatalf("Load failed: data=%v fresh=%v err=%v", data != nil, fresh, err)
+	}
+
+	// A file that is not a store is not read as empty
+	os.WriteFile(path, []byte(`{"version":1,"entries":{}}`), 0600)
+	if _, _, err := reader.Load(); err == nil {
+		t.Error("expected an error for a store of another format")
+	}
+}
+
+func TestSingleFileBusy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	c := OpenSingleFile(path).Open("https://one.example.com/mcp", "http", "", "client", time.Hour)
+	if err := c.Save(createTestData()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	prev := storeLockTimeout
+	storeLockTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { storeLockTimeout = prev })
+
+	// Another writer, such as a background refresh, holds the store
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Now()
+	data, _, err := c.Load()
+	if err != nil || data != nil {
+		t.Errorf("Load of a busy store = data=%v err=%v, want a miss", data != nil, err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("Load waited %v for the busy store", waited)
+	}
+	if err := c.Save(createTestData()); err == nil {
+		t.Error("expected Save to a busy store to fail")
+	}
+}
+
+func TestSingleFileIndex(t *testing.T) {
+	useBackend(t, OpenSingleFile(filepath.Join(t.TempDir(), "cache.db")))
+
+	// Before anything is written the store is empty
+	if entries, err := FindEntries("https://one.example.com/mcp"); err != nil || len(entries) != 0 {
+		t.Fatalf("FindEntries on a new store = %v, %v", entries, err)
+	}
+
+	data := createTestData()
+	data.ServerInfo = &mcp.Implementation{Name: "Test-Server", Version: "1.0"}
+	one := New("https://one.example.com/mcp", "http", "", "client")
+	if err := one.Save(data); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	New("https://one.example.com/mcp", "http", "other-token", "client").Save(createTestData())
+	New("https://two.example.com/mcp", "http", "", "client").Save(createTestData())
+	key := one.(*boltCache).key
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"https://one.example.com/mcp/", 2},
+		{"test-server", 1},
+		{key, 1},
+		{key[:6], 1},
+		{key + ".json", 1},
+		{key[:3], 0},
+		{"https://one.example.com", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		entries, err := FindEntries(tt.query)
+		if err != nil || len(entries) != tt.want {
+			t.Errorf("FindEntries(%q) = %d entries, %v, want %d", tt.query, len(entries), err, tt.want)
+		}
+	}
+
+	// Rewriting an entry replaces its index keys
+	data.ServerInfo = &mcp.Implementation{Name: "renamed"}
+	if err := one.Save(data); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if entries, _ := FindEntries("test-server"); len(entries) != 0 {
+		t.Errorf("old server name still indexed: %v", entries)
+	}
+	if entries, _ := FindEntries("renamed"); len(entries) != 1 || entries[0].ToolsCount != 2 {
+		t.Errorf("FindEntries(renamed) = %v", entries)
+	}
+
+	if err := RemoveEntry(key); err != nil {
+		t.Fatalf("RemoveEntry failed: %v", err)
+	}
+	if entries, _ := FindEntries("renamed"); len(entries) != 0 {
+		t.Errorf("removed entry still indexed: %v", entries)
+	}
+}
+
+func TestSnapshotBackend(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c := New("https://one.example.com/mcp", "http", "token", "client")
+	if err := c.Save(createTestData()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	var bundle bytes.Buffer
+	if _, err := Export(&bundle, nil, false); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	os.WriteFile(path, bundle.Bytes(), 0600)
+
+	b, err := OpenBackend(BackendSnapshot, path, nil)
+	if err != nil {
+		t.Fatalf("OpenBackend failed: %v", err)
+	}
+	useBackend(t, b)
+
+	snap := New("https://one.example.com/mcp", "http", "token", "client")
+	data, _, err := snap.Load()
+	if err != nil || data == nil || len(data.Tools) != 2 {
+		t.Fatalf("Load failed: data=%v err=%v", data, err)
+	}
+	if err := snap.Save(createTestData()); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Save = %v, want ErrReadOnly", err)
+	}
+	if err := ClearAll(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("ClearAll = %v, want ErrReadOnly", err)
+	}
+	if snap.ClaimRefresh() {
+		t.Error("a snapshot entry should never be claimed for refresh")
+	}
+
+	if _, err := OpenBackend(BackendSnapshot, "", nil); err == nil {
+		t.Error("expected an error for a snapshot without a bundle")
+	}
+	if _, err := OpenBackend("sqlite", "", nil); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
@@ -1,12 +1,37 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
 
+// envCachePassphrase encrypts the cache entries with a key derived from it
+const envCachePassphrase = "MCPMAP_CACHE_PASSPHRASE"
+
+var (
+	cacheTTL     time.Duration
+	noCache      bool
+	refreshCache bool
+	cacheBackend string
+	cachePath    string
+	cacheKeyFile string
+
+	pruneOlderThan  string
+	exportOut       string
+	exportPlaintext bool
+)
+
 var cacheCmd = &cobra.Command{
 	Use:   "cache",
 	Short: "Manage mcpmap cache",
@@ -27,9 +52,123 @@ var cacheInfoCmd = &cobra.Command{
 	RunE:  runCacheInfo,
 }
 
+var cacheRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Fetch the server's metadata and update its cache entry",
+	Long: `Fetch the tools, resources and prompts of the server and update its cache entry.
+
+Tab completion runs this in the background when it answers from a cache entry
+older than --cache-ttl.
+
+Examples:
+  mcpmap --http=https://mcp.example.com/mcp cache refresh
+  mcpmap -s prod cache refresh`,
+	Args: cobra.NoArgs,
+	RunE: runCacheRefresh,
+}
+
+var cacheShowCmd = &cobra.Command{
+	Use:   "show <server>",
+	Short: "Show the cached metadata of a server",
+	Long: `Show the tools, resources and prompts cached for a server.
+
+The server is given by its URL, a profile name, the name it reports, or the
+hash of its cache entry (or a prefix of at least 4 characters). All entries
+of the server are shown, e.g. one per token or client name.
+
+Examples:
+  mcpmap cache show https://mcp.example.com/mcp
+  mcpmap cache show prod
+  mcpmap cache show 3f2a9c`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: cacheEntryCompletion,
+	Annotations:       map[string]string{annotationTransportOptional: "true"},
+	RunE:              runCacheShow,
+}
+
+var cacheRmCmd = &cobra.Command{
+	Use:   "rm <server|hash>...",
+	Short: "Remove the cache entries of servers",
+	Long: `Remove the cache entries of servers, given as for "cache show".
+
+Examples:
+  mcpmap cache rm https://mcp.example.com/mcp
+  mcpmap cache rm 3f2a9c 81d0`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: cacheEntryCompletion,
+	Annotations:       map[string]string{annotationTransportOptional: "true"},
+	RunE:              runCacheRm,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune --older-than <duration>",
+	Short: "Remove cache entries older than a duration",
+	Long: `Remove the cache entries that were cached longer ago than --older-than.
+Durations take the units of Go durations plus d for days.
+
+Examples:
+  mcpmap cache prune --older-than 7d
+  mcpmap cache prune --older-than 12h`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{annotationTransportOptional: "true"},
+	RunE:        runCachePrune,
+}
+
+var cacheExportCmd = &cobra.Command{
+	Use:   "export --out <bundle.tar.gz> [server|hash...]",
+	Short: "Export cache entries to a bundle",
+	Long: `Write cache entries with their server metadata and timestamps to a gzipped
+tar bundle, to analyze them on another machine after "cache import". Without
+arguments every entry is exported, otherwise the entries of the given servers
+as for "cache show".
+
+Bundles are not encrypted. Exporting an encrypted cache needs the cache key
+and --plaintext, and writes the entries decrypted: keep such a bundle as safe
+as the key.
+
+Examples:
+  mcpmap cache export --out bundle.tar.gz
+  MCPMAP_CACHE_PASSPHRASE=... mcpmap cache export --plaintext --out bundle.tar.gz
+  mcpmap cache export --out prod.tar.gz https://mcp.example.com/mcp prod`,
+	ValidArgsFunction: cacheEntryCompletion,
+	Annotations:       map[string]string{annotationTransportOptional: "true"},
+	RunE:              runCacheExport,
+}
+
+var cacheImportCmd = &cobra.Command{
+	Use:   "import <bundle.tar.gz>",
+	Short: "Import cache entries from a bundle",
+	Long: `Import the cache entries of a bundle written by "cache export". Local
+entries cached later than the imported ones are kept.
+
+Imported entries are served by list when the server is unreachable and by
+tab completion, also when they were cached with a token that is not
+available here.
+
+Examples:
+  mcpmap cache import bundle.tar.gz
+  mcpmap --http=https://mcp.example.com/mcp list tools`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{annotationTransportOptional: "true"},
+	RunE:        runCacheImport,
+}
+
 func init() {
+	cacheExportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "Bundle file to write (- for stdout)")
+	cacheExportCmd.MarkFlagRequired("out")
+	cacheExportCmd.Flags().BoolVar(&exportPlaintext, "plaintext", false, "Export the entries of an encrypted cache decrypted")
+	cacheShowCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output the cache entries as JSON")
+	cachePruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Remove entries cached longer ago than this (e.g. 7d, 12h)")
+	cachePruneCmd.MarkFlagRequired("older-than")
+
 	cacheCmd.AddCommand(cacheClearCmd)
 	cacheCmd.AddCommand(cacheInfoCmd)
+	cacheCmd.AddCommand(cacheRefreshCmd)
+	cacheCmd.AddCommand(cacheShowCmd)
+	cacheCmd.AddCommand(cacheRmCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cacheCmd.AddCommand(cacheExportCmd)
+	cacheCmd.AddCommand(cacheImportCmd)
 	rootCmd.AddCommand(cacheCmd)
 }
 
@@ -51,11 +190,11 @@ func runCacheInfo(cmd *cobra.Command, args []string) error {
 	
 	if info.TotalFiles == 0 {
 		fmt.Println("Cache is empty")
-		fmt.Printf("Cache directory: %s\n", info.CacheDir)
+		printCacheLocation(info)
 		return nil
 	}
 	
-	fmt.Printf("Cache directory: %s\n", info.CacheDir)
+	printCacheLocation(info)
 	fmt.Printf("Total files: %d\n", info.TotalFiles)
 	fmt.Printf("Total size: %d bytes (%.2f KB)\n", info.TotalSize, float64(info.TotalSize)/1024)
 	fmt.Println()
@@ -64,13 +203,478 @@ func runCacheInfo(cmd *cobra.Command, args []string) error {
 		fmt.Println("Cache entries:")
 		for _, file := range info.Files {
 			fmt.Printf("  %s:\n", file.Name)
+			fmt.Printf("    Server: %s\n", describeServer(file))
+			if file.ClientName != "" {
+				fmt.Printf("    Client: %s\n", file.ClientName)
+			}
+			if file.ServerName != "" {
+				fmt.Printf("    Server info: %s\n", strings.TrimSpace(file.ServerName+" "+file.ServerVersion))
+			}
 			fmt.Printf("    Size: %d bytes\n", file.Size)
 			fmt.Printf("    Modified: %s\n", file.ModTime.Format("2006-01-02 15:04:05"))
-			fmt.Printf("    Tools: %d, Resources: %d, Prompts: %d\n", 
-				file.ToolsCount, file.ResourcesCount, file.PromptsCount)
+			fmt.Printf("    Age: %s\n", describeAge(file))
+			if file.Encrypted && info.Locked {
+				fmt.Println("    Contents: encrypted")
+			} else {
+				fmt.Printf("    Tools: %d, Resources: %d, Prompts: %d\n", 
+					file.ToolsCount, file.ResourcesCount, file.PromptsCount)
+			}
 			fmt.Println()
 		}
 	}
 	
 	return nil
 }
+
+// printCacheLocation prints the backend of the cache, where it keeps its
+// entries and whether they are encrypted
+func printCacheLocation(info *cache.CacheInfo) {
+	if info.Backend == cache.BackendFiles {
+		fmt.Printf("Cache directory: %s\n", info.CacheDir)
+	} else {
+		fmt.Printf("Cache backend: %s\n", info.Backend)
+		fmt.Printf("Cache location: %s\n", info.CacheDir)
+	}
+	switch {
+	case info.Locked:
+		fmt.Printf("Encryption: locked (set $%s or --cache-key-file to read entries)\n", envCachePassphrase)
+	case info.Encrypted:
+		fmt.Println("Encryption: AES-256-GCM, unlocked")
+	}
+}
+
+// configureCache makes the backend chosen with --cache-backend, or in the
+// cache section of the config file, the one used by every cache operation
+func configureCache(cmd *cobra.Command) error {
+	// A broken config file is reported by the commands that use it
+	var cfg cacheConfig
+	if app, err := loadAppConfig(); err == nil && app.Cache != nil {
+		cfg = *app.Cache
+	}
+	if f := cmd.Flag("cache-backend"); f != nil && f.Changed {
+		// The configured path belongs to the configured backend
+		if cacheBackend != cfg.Backend {
+			cfg.Path = ""
+		}
+		cfg.Backend = cacheBackend
+	}
+	if f := cmd.Flag("cache-path"); f != nil && f.Changed {
+		cfg.Path = cachePath
+	}
+	if f := cmd.Flag("cache-key-file"); f != nil && f.Changed {
+		cfg.KeyFile = cacheKeyFile
+	}
+
+	if cfg.Backend == "" {
+		cfg.Backend = cache.BackendFiles
+	}
+	secret, err := cacheSecret(cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+	backend, err := cache.OpenBackend(cfg.Backend, cfg.Path, secret)
+	if err != nil {
+		return err
+	}
+	cacheBackend = cfg.Backend
+	cache.Use(backend)
+	return nil
+}
+
+// cacheSecret returns the contents of keyFile or the passphrase in
+// $MCPMAP_CACHE_PASSPHRASE, nil if the cache is not encrypted
+func cacheSecret(keyFile string) ([]byte, error) {
+	passphrase := os.Getenv(envCachePassphrase)
+	if keyFile != "" && passphrase != "" {
+		return nil, fmt.Errorf("give either a cache key file or $%s, not both", envCachePassphrase)
+	}
+	if passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if keyFile == "" {
+		return nil, nil
+	}
+	secret, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read cache key file: %w", err)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("cache key file %s is empty", keyFile)
+	}
+	return secret, nil
+}
+
+// openCache returns the metadata cache of a server, or a disabled cache with --no-cache
+func openCache(serverURL, transportType string) cache.Cache {
+	return openCacheWithToken(serverURL, transportType, authToken)
+}
+
+// openCacheWithToken is openCache for a server with its own token, such as a
+// gateway upstream
+func openCacheWithToken(serverURL, transportType, token string) cache.Cache {
+	if noCache {
+		return cache.Disabled()
+	}
+	return cache.NewWithTTL(serverURL, transportType, token, clientName, cacheTTL)
+}
+
+// loadCached returns the cached data of c and whether it is fresh. With
+// --refresh the cache is treated as empty so callers fetch and save anew.
+func loadCached(c cache.Cache) (*cache.CacheData, bool) {
+	if refreshCache {
+		return nil, false
+	}
+	data, fresh, err := c.Load()
+	if err != nil {
+		return nil, false
+	}
+	return data, fresh
+}
+
+// refreshFlags are passed on to the background refresh so it connects to the
+// same server with the same cache key
+var refreshFlags = []string{"sse", "http", "profile", "proxy", "token", "token-file", "token-cmd", "name", "cache-ttl",
+	"cache-backend", "cache-path", "cache-key-file"}
+
+// startBackgroundRefresh starts mcpmap with args without waiting for it
+var startBackgroundRefresh = func(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// Standard streams are /dev/null, so the completion output is not held open
+	child := exec.Command(exe, args...)
+	if err := child.Start(); err != nil {
+		return err
+	}
+	return child.Process.Release()
+}
+
+// refreshCacheInBackground runs "cache refresh" with the connection flags of
+// cmd in a separate process, so completion can answer from stale data at once
+// and the refresh survives its exit. Only one refresh per entry runs at a time.
+func refreshCacheInBackground(cmd *cobra.Command, c cache.Cache) {
+	// Another process cannot update the entries of this one
+	if cacheBackend == cache.BackendMemory {
+		return
+	}
+	if !c.ClaimRefresh() {
+		return
+	}
+
+	args := []string{"cache", "refresh"}
+	for _, name := range refreshFlags {
+		f := cmd.Flag(name)
+		if f == nil || !f.Changed {
+			continue
+		}
+		args = append(args, "--"+name+"="+f.Value.String())
+	}
+	_ = startBackgroundRefresh(args)
+}
+
+func runCacheRefresh(cmd *cobra.Command, args []string) error {
+	if noCache {
+		return fmt.Errorf("cannot refresh the cache with --no-cache")
+	}
+
+	ctx := context.Background()
+	return withSession(ctx, func(session *mcp.ClientSession) error {
+		data, err := fetchAllServerData(ctx, session)
+		if err != nil {
+			return err
+		}
+		if err := openCache(serverURL, transportType).Save(data); err != nil {
+			return fmt.Errorf("save cache: %w", err)
+		}
+		fmt.Printf("Cached %d tools, %d resources and %d prompts of %s\n",
+			len(data.Tools), len(data.Resources), len(data.Prompts), serverURL)
+		return nil
+	})
+}
+
+// describeAge renders the age and staleness of a cache entry
+func describeAge(file cache.FileInfo) string {
+	if file.CachedAt.IsZero() {
+		return "unknown"
+	}
+	state := "fresh"
+	if file.Stale {
+		state = "stale"
+	}
+	if file.TTLSeconds == 0 {
+		return fmt.Sprintf("%s (%s, no TTL)", file.Age(), state)
+	}
+	return fmt.Sprintf("%s (%s, TTL %s)", file.Age(), state, time.Duration(file.TTLSeconds)*time.Second)
+}
+
+// describeServer renders the server and transport of a cache entry
+func describeServer(file cache.FileInfo) string {
+	if file.ServerURL == "" {
+		return "unknown (cached by an older mcpmap)"
+	}
+	return fmt.Sprintf("%s (%s)", file.ServerURL, file.Transport)
+}
+
+// describeSection renders when a section of a cache entry was fetched
+func describeSection(file cache.FileInfo, section cache.Section) string {
+	fetched, ok := file.Sections[section]
+	if !ok {
+		return "never fetched"
+	}
+	return fmt.Sprintf("fetched %s ago", time.Since(fetched).Round(time.Second))
+}
+
+// findCacheEntries returns the cache entries matching query. A query naming
+// a profile matches the entries of the profile's server.
+func findCacheEntries(query string) ([]cache.FileInfo, error) {
+	entries, err := cache.FindEntries(query)
+	if err != nil || len(entries) > 0 {
+		return entries, err
+	}
+	if cfg, err := loadAppConfig(); err == nil {
+		if p, ok := cfg.Profiles[query]; ok {
+			entries, err = cache.FindEntries(p.target())
+		}
+	}
+	if err == nil && len(entries) == 0 {
+		err = fmt.Errorf("no cache entry matches %q", query)
+	}
+	return entries, err
+}
+
+// cachedEntry is the JSON output of cache show
+type cachedEntry struct {
+	cache.FileInfo
+	Tools             []*mcp.Tool             `json:"tools"`
+	Resources         []*mcp.Resource         `json:"resources"`
+	ResourceTemplates []*mcp.ResourceTemplate `json:"resource_templates"`
+	Prompts           []*mcp.Prompt           `json:"prompts"`
+}
+
+func runCacheShow(cmd *cobra.Command, args []string) error {
+	files, err := findCacheEntries(args[0])
+	if err != nil {
+		return err
+	}
+
+	entries := make([]cachedEntry, 0, len(files))
+	for _, file := range files {
+		data, err := cache.ReadEntry(file.Key)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, cachedEntry{file, data.Tools, data.Resources, data.ResourceTemplates, data.Prompts})
+	}
+
+	if jsonOutput {
+		js, err := json.Marshal(entries)
+		if err != nil {
+			return fmt.Errorf("json marshal cache entries: %w", err)
+		}
+		fmt.Fprintln(os.Stdout, string(js))
+		return nil
+	}
+
+	for i, entry := range entries {
+		if i > 0 {
+			fmt.Println()
+		}
+		printCachedEntry(entry)
+	}
+	return nil
+}
+
+// printCachedEntry prints one cache entry and the names of its contents
+func printCachedEntry(entry cachedEntry) {
+	fmt.Printf("%s\n", entry.Key)
+	fmt.Printf("  Server: %s\n", describeServer(entry.FileInfo))
+	if entry.ClientName != "" {
+		fmt.Printf("  Client: %s\n", entry.ClientName)
+	}
+	if entry.ServerName != "" {
+		fmt.Printf("  Server info: %s\n", strings.TrimSpace(entry.ServerName+" "+entry.ServerVersion))
+	}
+	fmt.Printf("  Age: %s\n", describeAge(entry.FileInfo))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  Tools (%d, %s):\n", len(entry.Tools), describeSection(entry.FileInfo, cache.SectionTools))
+	for _, tool := range entry.Tools {
+		fmt.Fprintf(w, "    %s\t%s\n", tool.Name, firstLine(tool.Description))
+	}
+	fmt.Fprintf(w, "  Resources (%d, %s):\n", len(entry.Resources), describeSection(entry.FileInfo, cache.SectionResources))
+	for _, resource := range entry.Resources {
+		fmt.Fprintf(w, "    %s\t%s\n", resource.URI, resource.Name)
+	}
+	fmt.Fprintf(w, "  Resource templates (%d, %s):\n", len(entry.ResourceTemplates), describeSection(entry.FileInfo, cache.SectionResourceTemplates))
+	for _, template := range entry.ResourceTemplates {
+		fmt.Fprintf(w, "    %s\t%s\n", template.URITemplate, template.Name)
+	}
+	fmt.Fprintf(w, "  Prompts (%d, %s):\n", len(entry.Prompts), describeSection(entry.FileInfo, cache.SectionPrompts))
+	for _, prompt := range entry.Prompts {
+		fmt.Fprintf(w, "    %s\t%s\n", prompt.Name, firstLine(prompt.Description))
+	}
+	w.Flush()
+}
+
+func runCacheRm(cmd *cobra.Command, args []string) error {
+	for _, query := range args {
+		files, err := findCacheEntries(query)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := cache.RemoveEntry(file.Key); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s: %s\n", file.Key, describeServer(file))
+		}
+	}
+	return nil
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) error {
+	olderThan, err := parseRetention(pruneOlderThan)
+	if err != nil {
+		return err
+	}
+
+	removed, err := cache.Prune(olderThan)
+	for _, file := range removed {
+		fmt.Printf("Removed %s: %s\n", file.Key, describeServer(file))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune cache: %w", err)
+	}
+	fmt.Printf("Pruned %d cache entries older than %s\n", len(removed), pruneOlderThan)
+	return nil
+}
+
+// parseRetention parses a Go duration that may also be given in days, e.g. 7d
+func parseRetention(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func runCacheExport(cmd *cobra.Command, args []string) error {
+	var keys []string
+	for _, query := range args {
+		files, err := findCacheEntries(query)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			keys = append(keys, file.Key)
+		}
+	}
+
+	// Refuse before an existing bundle file is truncated
+	if info, err := cache.GetCacheInfo(); err == nil && info.Encrypted && !exportPlaintext {
+		return fmt.Errorf("%w; pass --plaintext to export the entries decrypted", cache.ErrPlaintextExport)
+	}
+
+	out := os.Stdout
+	if exportOut != "-" {
+		f, err := os.OpenFile(exportOut, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("create bundle: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	exported, err := cache.Export(out, keys, exportPlaintext)
+	if err != nil {
+		return fmt.Errorf("failed to export cache: %w", err)
+	}
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("write bundle: %w", err)
+		}
+	}
+	// Progress goes to stderr so the bundle can be written to stdout
+	for _, file := range exported {
+		fmt.Fprintf(os.Stderr, "Exported %s: %s\n", file.Key, describeServer(file))
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d cache entries\n", len(exported))
+	return nil
+}
+
+func runCacheImport(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("open bundle: %w", err)
+	}
+	defer f.Close()
+
+	result, err := cache.Import(f)
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", args[0], err)
+	}
+	for _, file := range result.Imported {
+		fmt.Printf("Imported %s: %s\n", file.Key, describeServer(file))
+	}
+	for _, file := range result.Skipped {
+		fmt.Printf("Skipped %s: %s (the local entry is newer)\n", file.Key, describeServer(file))
+	}
+	fmt.Printf("Imported %d cache entries, skipped %d\n", len(result.Imported), len(result.Skipped))
+	return nil
+}
+
+// loadServerCache returns the most recently cached data of a server under
+// any token or client name, such as an imported entry. It is the fallback
+// when the server cannot be reached and its own entry is missing.
+func loadServerCache(serverURL, transportType string) *cache.CacheData {
+	if noCache {
+		return nil
+	}
+	files, err := cache.FindEntries(serverURL)
+	if err != nil {
+		return nil
+	}
+
+	var newest *cache.FileInfo
+	for i, file := range files {
+		if file.Transport == transportType && (newest == nil || file.CachedAt.After(newest.CachedAt)) {
+			newest = &files[i]
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+	data, err := cache.ReadEntry(newest.Key)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// cacheEntryCompletion completes the server URLs and hashes of cache entries
+func cacheEntryCompletion(
+	cmd *cobra.Command,
+	args []string,
+	toComplete string,
+) ([]string, cobra.ShellCompDirective) {
+	info, err := cache.GetCacheInfo()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, file := range info.Files {
+		completions = append(completions, file.Key)
+		if file.ServerURL != "" {
+			completions = append(completions, file.ServerURL)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestCompletionServesStaleCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv(envToken, "")
+	const url = "http://127.0.0.1:1/mcp"
+	writeTestConfig(t, &appConfig{Profiles: map[string]*serverProfile{
+		"prod": {Transport: "http", URL: url, Token: "profile-token", CacheTTL: "1ns"},
+	}})
+	defer func() {
+		authToken, tokenCommand, cacheTTL = "", nil, cache.DefaultTTL
+		requestHeaders, clientTLS, stdioCommand, stdioEnv = nil, nil, nil, nil
+	}()
+
+	// The entry is keyed by the profile's token, which completion must apply
+	c := cache.New(url, "http", "profile-token", clientName)
+	if err := c.Save(&cache.CacheData{Tools: []*mcp.Tool{{Name: "search"}, {Name: "fetch"}}}); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+
+	var started [][]string
+	defer func(orig func([]string) error) { startBackgroundRefresh = orig }(startBackgroundRefresh)
+	startBackgroundRefresh = func(args []string) error {
+		started = append(started, args)
+		return nil
+	}
+
+	cmd := newProfileCmd()
+	cmd.Flags().Set("profile", "prod")
+
+	for i := 0; i < 2; i++ {
+		got, _ := toolNameCompletion(cmd, nil, "")
+		if fmt.Sprint(got) != "[search fetch]" {
+			t.Fatalf("completion %d: got %v, want the stale cached tools", i, got)
+		}
+	}
+
+	if len(started) != 1 {
+		t.Fatalf("expected one background refresh while the first is pending, got %v", started)
+	}
+	if fmt.Sprint(started[0]) != "[cache refresh --profile=prod]" {
+		t.Errorf("unexpected refresh command %v", started[0])
+	}
+}
+
+func TestLoadCached(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	defer func() { noCache, refreshCache = false, false }()
+
+	if err := openCache("http://cached", "http").Save(&cache.CacheData{}); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		noCache  bool
+		refresh  bool
+		wantData bool
+	}{
+		{"cached", false, false, true},
+		{"refresh", false, true, false},
+		{"no cache", true, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noCache, refreshCache = tt.noCache, tt.refresh
+			data, fresh := loadCached(openCache("http://cached", "http"))
+			if (data != nil) != tt.wantData || fresh != tt.wantData {
+				t.Errorf("got data=%v fresh=%v, want data=%v", data != nil, fresh, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestFindCacheEntries(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	writeTestConfig(t, &appConfig{Profiles: map[string]*serverProfile{
+		"prod": {Transport: "http", URL: "https://prod.example.com/mcp"},
+	}})
+
+	// The server info is recorded while the session is initialized
+	server := mcp.NewServer(&mcp.Implementation{Name: "Prod Server", Version: "2.0"}, nil)
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(context.Background(), serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "cache-test"}, nil)
+	client.AddSendingMiddleware(recordServerInfo)
+	session, err := client.Connect(context.Background(), clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer session.Close()
+
+	data, err := fetchAllServerData(context.Background(), session)
+	if err != nil {
+		t.Fatalf("fetchAllServerData: %v", err)
+	}
+	if err := openCache("https://prod.example.com/mcp", "http").Save(data); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+
+	tests := []struct {
+		query   string
+		wantErr string
+	}{
+		{"prod", ""},
+		{"https://prod.example.com/mcp", ""},
+		{"prod server", ""},
+		{"staging", `no cache entry matches "staging"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			entries, err := findCacheEntries(tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != 1 || entries[0].ServerName != "Prod Server" || entries[0].ServerVersion != "2.0" {
+				t.Errorf("unexpected entries %+v", entries)
+			}
+		})
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"week", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRetention(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error=%v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadServerCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	defer func() { noCache = false }()
+
+	const url = "https://imported.example.com/mcp"
+	older := cache.New(url, "http", "their-token", "scanner")
+	if err := older.Save(&cache.CacheData{Tools: []*mcp.Tool{{Name: "old"}}}); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	newer := cache.New(url, "http", "other-token", "scanner")
+	if err := newer.Save(&cache.CacheData{Tools: []*mcp.Tool{{Name: "new"}}}); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+
+	if data := loadServerCache(url, "http"); data == nil || data.Tools[0].Name != "new" {
+		t.Errorf("expected the newest entry of the server, got %+v", data)
+	}
+	if data := loadServerCache(url, "sse"); data != nil {
+		t.Errorf("entries of another transport must not match, got %+v", data)
+	}
+	noCache = true
+	if data := loadServerCache(url, "http"); data != nil {
+		t.Errorf("--no-cache must not read entries, got %+v", data)
+	}
+}
+
+func TestConfigureCache(t *testing.T) {
+	dir := t.TempDir()
+	configured := dir + "/configured.db"
+	flagged := dir + "/flagged.db"
+
+	tests := []struct {
+		name        string
+		config      *cacheConfig
+		flags       map[string]string
+		wantBackend string
+		wantPath    string
+		wantErr     bool
+	}{
+		{name: "default", wantBackend: cache.BackendFiles},
+		{
+			name:        "config",
+			config:      &cacheConfig{Backend: cache.BackendSingleFile, Path: configured},
+			wantBackend: cache.BackendSingleFile, wantPath: configured,
+		},
+		{
+			name:        "flag overrides config and its path",
+			config:      &cacheConfig{Backend: cache.BackendSingleFile, Path: configured},
+			flags:       map[string]string{"cache-backend": cache.BackendMemory},
+			wantBackend: cache.BackendMemory, wantPath: "memory",
+		},
+		{
+			name:        "path flag keeps configured backend",
+			config:      &cacheConfig{Backend: cache.BackendSingleFile, Path: configured},
+			flags:       map[string]string{"cache-path": flagged},
+			wantBackend: cache.BackendSingleFile, wantPath: flagged,
+		},
+		{
+			name:    "path for files backend",
+			flags:   map[string]string{"cache-path": flagged},
+			wantErr: true,
+		},
+		{
+			name:    "unknown backend",
+			flags:   map[string]string{"cache-backend": "sqlite"},
+			wantErr: true,
+		},
+	}
+
+	defer func() { cacheBackend, cachePath = cache.BackendFiles, "" }()
+	defer cache.Use(cache.Files())
+	t.Setenv(envCachePassphrase, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeTestConfig(t, &appConfig{Cache: tt.config})
+			cacheBackend, cachePath = cache.BackendFiles, ""
+			cmd := newProfileCmd()
+			cmd.Flags().StringVar(&cacheBackend, "cache-backend", cache.BackendFiles, "")
+			cmd.Flags().StringVar(&cachePath, "cache-path", "", "")
+			for name, value := range tt.flags {
+				cmd.Flags().Set(name, value)
+			}
+
+			err := configureCache(cmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("configureCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			info, err := cache.GetCacheInfo()
+			if err != nil {
+				t.Fatalf("GetCacheInfo failed: %v", err)
+			}
+			if info.Backend != tt.wantBackend {
+				t.Errorf("backend = %s, want %s", info.Backend, tt.wantBackend)
+			}
+			if tt.wantPath != "" && info.CacheDir != tt.wantPath {
+				t.Errorf("location = %s, want %s", info.CacheDir, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestCacheSecret(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := dir + "/cache.key"
+	os.WriteFile(keyFile, []byte("key file secret"), 0600)
+	emptyFile := dir + "/empty.key"
+	os.WriteFile(emptyFile, nil, 0600)
+
+	tests := []struct {
+		name       string
+		passphrase string
+		keyFile    string
+		want       string
+		wantErr    bool
+	}{
+		{name: "not encrypted"},
+		{name: "passphrase", passphrase: "correct horse", want: "correct horse"},
+		{name: "key file", keyFile: keyFile, want: "key file secret"},
+		{name: "both", passphrase: "correct horse", keyFile: keyFile, wantErr: true},
+		{name: "missing key file", keyFile: dir + "/missing.key", wantErr: true},
+		{name: "empty key file", keyFile: emptyFile, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envCachePassphrase, tt.passphrase)
+			got, err := cacheSecret(tt.keyFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cacheSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("cacheSecret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
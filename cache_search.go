@@ -0,0 +1,238 @@
+// cache_search.go - Search the cached metadata of every server
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"mcpmap/cache"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchFields []string
+	searchServer string
+)
+
+// searchFieldNames lists the fields cache search looks at
+var searchFieldNames = []string{"tool", "param", "description", "resource", "mime", "prompt"}
+
+var cacheSearchCmd = &cobra.Command{
+	Use:   "search <pattern>",
+	Short: "Search the cached tools, resources and prompts of every server",
+	Long: `Search the tools, resources, resource templates and prompts of every cache
+entry, e.g. after scanning many servers, and print each matching item with its
+server.
+
+The pattern matches case-insensitively. With * or ? it is a glob that must
+match the whole field, otherwise it may match anywhere in the field.
+
+Fields searched (--field, default all):
+  tool         tool names
+  param        tool parameter and prompt argument names
+  description  descriptions of tools, resources, templates and prompts
+  resource     resource URIs and resource template URIs
+  mime         MIME types of resources and resource templates
+  prompt       prompt names
+
+Examples:
+  mcpmap cache search '*exec*' --field tool
+  mcpmap cache search text/x-sql --field mime
+  mcpmap cache search password --field param,description --json
+  mcpmap cache search sql --server prod`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{annotationTransportOptional: "true"},
+	RunE:        runCacheSearch,
+}
+
+func init() {
+	cacheSearchCmd.Flags().StringSliceVarP(&searchFields, "field", "f", nil,
+		"Fields to search: "+strings.Join(searchFieldNames, ", ")+" (default all)")
+	cacheSearchCmd.Flags().StringVar(&searchServer, "server", "", `Only search the entries of this server, given as for "cache show"`)
+	cacheSearchCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output the matches as JSON")
+	cacheSearchCmd.RegisterFlagCompletionFunc("field", cobra.FixedCompletions(searchFieldNames, cobra.ShellCompDirectiveNoFileComp))
+	cacheSearchCmd.RegisterFlagCompletionFunc("server", cacheEntryCompletion)
+
+	cacheCmd.AddCommand(cacheSearchCmd)
+}
+
+// searchMatch is one cached item with a field matching the search pattern
+type searchMatch struct {
+	Key        string `json:"key"`
+	ServerURL  string `json:"server_url"`
+	Transport  string `json:"transport"`
+	ServerName string `json:"server_name,omitempty"`
+	// Kind is tool, resource, resource_template or prompt
+	Kind string `json:"kind"`
+	// Item is the name of the item, or the URI of a resource
+	Item  string `json:"item"`
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// compileSearchPattern turns a search pattern into a case-insensitive
+// regular expression, see the help of cache search
+func compileSearchPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty search pattern")
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		return regexp.MustCompile("(?is)" + regexp.QuoteMeta(pattern)), nil
+	}
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// parseSearchFields returns the set of fields to search, all if none are given
+func parseSearchFields(fields []string) (map[string]bool, error) {
+	if len(fields) == 0 {
+		fields = searchFieldNames
+	}
+	set := make(map[string]bool)
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !slices.Contains(searchFieldNames, field) {
+			return nil, fmt.Errorf("unknown search field %q (supported: %s)", field, strings.Join(searchFieldNames, ", "))
+		}
+		set[field] = true
+	}
+	return set, nil
+}
+
+// searchEntry returns the items of one cache entry with a field in fields
+// matching re
+func searchEntry(file cache.FileInfo, data *cache.CacheData, re *regexp.Regexp, fields map[string]bool) []searchMatch {
+	var matches []searchMatch
+	check := func(kind, item, field, value string) {
+		if value == "" || !fields[field] || !re.MatchString(value) {
+			return
+		}
+		matches = append(matches, searchMatch{
+			Key:        file.Key,
+			ServerURL:  file.ServerURL,
+			Transport:  file.Transport,
+			ServerName: file.ServerName,
+			Kind:       kind,
+			Item:       item,
+			Field:      field,
+			Value:      value,
+		})
+	}
+
+	for _, tool := range data.Tools {
+		check("tool", tool.Name, "tool", tool.Name)
+		var params []string
+		for _, param := range extractParametersFromSchema(tool.InputSchema) {
+			params = append(params, param.Name)
+		}
+		sort.Strings(params)
+		for _, param := range params {
+			check("tool", tool.Name, "param", param)
+		}
+		check("tool", tool.Name, "description", tool.Description)
+	}
+	for _, resource := range data.Resources {
+		check("resource", resource.URI, "resource", resource.URI)
+		check("resource", resource.URI, "mime", resource.MIMEType)
+		check("resource", resource.URI, "description", resource.Description)
+	}
+	for _, template := range data.ResourceTemplates {
+		check("resource_template", template.URITemplate, "resource", template.URITemplate)
+		check("resource_template", template.URITemplate, "mime", template.MIMEType)
+		check("resource_template", template.URITemplate, "description", template.Description)
+	}
+	for _, prompt := range data.Prompts {
+		check("prompt", prompt.Name, "prompt", prompt.Name)
+		for _, arg := range prompt.Arguments {
+			check("prompt", prompt.Name, "param", arg.Name)
+		}
+		check("prompt", prompt.Name, "description", prompt.Description)
+	}
+	return matches
+}
+
+// searchCache searches the entries of the server given by server, or every
+// entry if it is empty
+func searchCache(pattern string, fields []string, server string) ([]searchMatch, error) {
+	re, err := compileSearchPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	fieldSet, err := parseSearchFields(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []cache.FileInfo
+	if server != "" {
+		files, err = findCacheEntries(server)
+	} else {
+		var info *cache.CacheInfo
+		if info, err = cache.GetCacheInfo(); err == nil {
+			files = info.Files
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []searchMatch{}
+	for _, file := range files {
+		data, err := cache.ReadEntry(file.Key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping cache entry %s: %v\n", file.Key, err)
+			continue
+		}
+		matches = append(matches, searchEntry(file, data, re, fieldSet)...)
+	}
+	return matches, nil
+}
+
+func runCacheSearch(cmd *cobra.Command, args []string) error {
+	matches, err := searchCache(args[0], searchFields, searchServer)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		js, err := json.Marshal(matches)
+		if err != nil {
+			return fmt.Errorf("json marshal search matches: %w", err)
+		}
+		fmt.Fprintln(os.Stdout, string(js))
+		return nil
+	}
+
+	if len(matches) == 0 {
+		fmt.Printf("No cached items match %q\n", args[0])
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tKIND\tITEM\tFIELD\tMATCH")
+	for _, m := range matches {
+		server := m.ServerURL
+		if server == "" {
+			server = m.Key
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", server, m.Kind, m.Item, m.Field, firstLine(m.Value))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestSearchCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	writeTestConfig(t, &appConfig{Profiles: map[string]*serverProfile{
+		"db": {Transport: "http", URL: "https://db.example.com/mcp"},
+	}})
+
+	shell := cache.New("https://shell.example.com/mcp", "http", "", "mcpmap")
+	if err := shell.Save(&cache.CacheData{
+		Tools: []*mcp.Tool{
+			{Name: "exec_command", Description: "Run a shell command", InputSchema: &jsonschema.Schema{
+				Type:       "object",
+				Properties: map[string]*jsonschema.Schema{"command": {Type: "string"}},
+			}},
+			{Name: "read_file", Description: "Read a file\nNever executes anything"},
+		},
+	}); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+	db := cache.New("https://db.example.com/mcp", "http", "", "mcpmap")
+	if err := db.Save(&cache.CacheData{
+		Tools: []*mcp.Tool{{Name: "query"}},
+		Resources: []*mcp.Resource{
+			{URI: "file:///db/schema.sql", Name: "schema", MIMEType: "text/x-sql"},
+			{URI: "file:///db/README", Name: "readme", MIMEType: "text/plain"},
+		},
+		ResourceTemplates: []*mcp.ResourceTemplate{{URITemplate: "db://tables/{name}", Name: "table", MIMEType: "text/x-sql"}},
+		Prompts: []*mcp.Prompt{{Name: "explain_query", Arguments: []*mcp.PromptArgument{{Name: "command"}}}},
+	}); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		fields  []string
+		server  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "glob over tool names",
+			pattern: "*EXEC*",
+			fields:  []string{"tool"},
+			want:    []string{"shell tool exec_command tool"},
+		},
+		{
+			name:    "substring in every field",
+			pattern: "exec",
+			want: []string{
+				"shell tool exec_command tool",
+				"shell tool read_file description",
+			},
+		},
+		{
+			name:    "glob must match the whole field",
+			pattern: "command*",
+			fields:  []string{"tool"},
+			want:    nil,
+		},
+		{
+			name:    "mime types",
+			pattern: "text/x-sql",
+			fields:  []string{"mime"},
+			want: []string{
+				"db resource file:///db/schema.sql mime",
+				"db resource_template db://tables/{name} mime",
+			},
+		},
+		{
+			name:    "globs cross slashes in URIs",
+			pattern: "*.sql",
+			fields:  []string{"resource"},
+			want:    []string{"db resource file:///db/schema.sql resource"},
+		},
+		{
+			name:    "parameter and argument names",
+			pattern: "command",
+			fields:  []string{"param"},
+			want: []string{
+				"shell tool exec_command param",
+				"db prompt explain_query param",
+			},
+		},
+		{
+			name:    "prompt names",
+			pattern: "*query",
+			fields:  []string{"prompt"},
+			want:    []string{"db prompt explain_query prompt"},
+		},
+		{
+			name:    "one server by profile",
+			pattern: "command",
+			fields:  []string{"param"},
+			server:  "db",
+			want:    []string{"db prompt explain_query param"},
+		},
+		{
+			name:    "no matches",
+			pattern: "nothing",
+			want:    nil,
+		},
+		{
+			name:    "unknown field",
+			pattern: "exec",
+			fields:  []string{"annotation"},
+			wantErr: true,
+		},
+		{
+			name:    "empty pattern",
+			pattern: "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := searchCache(tt.pattern, tt.fields, tt.server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("searchCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, m := range matches {
+				server := "db"
+				if m.ServerURL == "https://shell.example.com/mcp" {
+					server = "shell"
+				}
+				got = append(got, fmt.Sprintf("%s %s %s %s", server, m.Kind, m.Item, m.Field))
+			}
+			if !sameMatches(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// sameMatches compares matches regardless of the order of the cache entries
+func sameMatches(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	count := make(map[string]int)
+	for _, m := range got {
+		count[m]++
+	}
+	for _, m := range want {
+		count[m]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
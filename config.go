@@ -0,0 +1,594 @@
+// config.go - Named server profiles stored in the user's config file
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"mcpmap/cache"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// envProfile selects a profile when --profile is not given
+const envProfile = "MCPMAP_PROFILE"
+
+var (
+	profileName string
+
+	// requestHeaders and clientTLS come from the selected profile and apply
+	// to every HTTP request sent to the server
+	requestHeaders map[string]string
+	clientTLS      *profileTLS
+
+	// stdioCommand and stdioEnv start the server of a stdio profile
+	stdioCommand []string
+	stdioEnv     map[string]string
+)
+
+// appConfig is the contents of config.yaml
+type appConfig struct {
+	Profiles map[string]*serverProfile `yaml:"profiles" json:"profiles"`
+	Cache    *cacheConfig              `yaml:"cache,omitempty" json:"cache,omitempty"`
+}
+
+// cacheConfig selects the cache backend, see --cache-backend and --cache-path
+type cacheConfig struct {
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+	Path    string `yaml:"path,omitempty" json:"path,omitempty"`
+	// KeyFile encrypts the entries of the files backend, see --cache-key-file
+	KeyFile string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+}
+
+// serverProfile holds everything needed to connect to one server
+type serverProfile struct {
+	Transport string            `yaml:"transport" json:"transport"`
+	URL       string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Command   []string          `yaml:"command,omitempty" json:"command,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Token     string            `yaml:"token,omitempty" json:"token,omitempty"`
+	TokenCmd  string            `yaml:"token_cmd,omitempty" json:"token_cmd,omitempty"`
+	Proxy     string            `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+	Name      string            `yaml:"name,omitempty" json:"name,omitempty"`
+	TLS       *profileTLS       `yaml:"tls,omitempty" json:"tls,omitempty"`
+	CacheTTL  string            `yaml:"cache_ttl,omitempty" json:"cache_ttl,omitempty"`
+}
+
+// profileTLS configures certificate verification and client certificates
+type profileTLS struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
+	CACert             string `yaml:"ca_cert,omitempty" json:"ca_cert,omitempty"`
+	ClientCert         string `yaml:"client_cert,omitempty" json:"client_cert,omitempty"`
+	ClientKey          string `yaml:"client_key,omitempty" json:"client_key,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty" json:"server_name,omitempty"`
+}
+
+func (p *serverProfile) validate() error {
+	switch p.Transport {
+	case "sse", "http":
+		if p.URL == "" {
+			return fmt.Errorf("missing url")
+		}
+	case "stdio":
+		if len(p.Command) == 0 {
+			return fmt.Errorf("missing command")
+		}
+	default:
+		return fmt.Errorf("transport must be sse, http or stdio, got %q", p.Transport)
+	}
+	if p.Token != "" && p.TokenCmd != "" {
+		return fmt.Errorf("token and token_cmd are mutually exclusive")
+	}
+	if t := p.TLS; t != nil && (t.ClientCert == "") != (t.ClientKey == "") {
+		return fmt.Errorf("tls client_cert and client_key must be set together")
+	}
+	if p.CacheTTL != "" {
+		if _, err := time.ParseDuration(p.CacheTTL); err != nil {
+			return fmt.Errorf("invalid cache_ttl: %w", err)
+		}
+	}
+	return nil
+}
+
+// target returns the URL of the server, or the command line of a stdio server
+func (p *serverProfile) target() string {
+	if p.Transport == "stdio" {
+		return strings.Join(p.Command, " ")
+	}
+	return p.URL
+}
+
+// configFilePath returns $XDG_CONFIG_HOME/mcpmap/config.yaml, falling back
+// to ~/.config when XDG_CONFIG_HOME is not set
+func configFilePath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("find config directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "mcpmap", "config.yaml"), nil
+}
+
+// loadAppConfig reads the config file. A missing file is an empty config.
+func loadAppConfig() (*appConfig, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &appConfig{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		cfg.Profiles = make(map[string]*serverProfile)
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]*serverProfile)
+	}
+	return cfg, nil
+}
+
+// save writes the config file. It may hold tokens, so it is only readable
+// by the user.
+func (c *appConfig) save() error {
+	path, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+
+	// A unique, synced temp file keeps concurrent or interrupted saves from
+	// leaving a torn config behind
+	if err := cache.WriteFileAtomic(path, data); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+func (c *appConfig) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectedProfile returns the profile chosen with --profile or MCPMAP_PROFILE,
+// or nil if none was chosen
+func selectedProfile(cmd *cobra.Command) (*serverProfile, error) {
+	name := os.Getenv(envProfile)
+	if f := cmd.Flag("profile"); f != nil && f.Changed {
+		name = f.Value.String()
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q (see 'mcpmap config list')", name)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	return p, nil
+}
+
+// scopeProfile returns the part of p that may be used for the command. When
+// --sse or --http picks another server, the profile's token, headers, TLS
+// settings and stdio command and environment belong to its own server and
+// must not be sent to the other one, so only its client settings are kept.
+func scopeProfile(cmd *cobra.Command, p *serverProfile) *serverProfile {
+	if p == nil {
+		return nil
+	}
+	for _, name := range []string{"sse", "http"} {
+		if f := cmd.Flag(name); f != nil && f.Changed {
+			fmt.Fprintf(os.Stderr, "Warning: --%s overrides the server of the selected profile; its token, headers, TLS settings and environment are not used\n", name)
+			return &serverProfile{Proxy: p.Proxy, Name: p.Name, CacheTTL: p.CacheTTL}
+		}
+	}
+	return p
+}
+
+// applyProfile sets the connection globals from p. Flags given explicitly on
+// the command line take precedence over the profile. The token is handled by
+// resolveCredentials.
+func applyProfile(cmd *cobra.Command, p *serverProfile) {
+	if f := cmd.Flag("proxy"); (f == nil || !f.Changed) && p.Proxy != "" {
+		proxyURL = p.Proxy
+	}
+	if f := cmd.Flag("name"); (f == nil || !f.Changed) && p.Name != "" {
+		clientName = p.Name
+	}
+	if f := cmd.Flag("cache-ttl"); (f == nil || !f.Changed) && p.CacheTTL != "" {
+		// validate has checked that it parses
+		cacheTTL, _ = time.ParseDuration(p.CacheTTL)
+	}
+	requestHeaders = p.Headers
+	clientTLS = p.TLS
+	stdioCommand = p.Command
+	stdioEnv = p.Env
+}
+
+// tlsClientConfig builds the TLS configuration described by t
+func tlsClientConfig(t *profileTLS) (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify, ServerName: t.ServerName}
+
+	if t.CACert != "" {
+		pem, err := os.ReadFile(t.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// headerTransport wraps an http.RoundTripper to add fixed headers
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqClone := req.Clone(req.Context())
+	for k, v := range t.headers {
+		reqClone.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(reqClone)
+}
+
+var (
+	configHeaders []string
+	configTLS     profileTLS
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage named server profiles",
+	Long: `Manage named server profiles in $XDG_CONFIG_HOME/mcpmap/config.yaml.
+
+A profile stores the transport, URL, headers, token (or a command printing
+it), proxy, client name and TLS settings of a server. Select one with
+--profile/-s or the MCPMAP_PROFILE environment variable instead of repeating
+the connection flags. Flags given on the command line override the profile.
+When --sse or --http picks another server, the profile's token, headers, TLS
+settings and environment are not used, so they are never sent to that server.
+
+Examples:
+  mcpmap config add prod --http=https://mcp.example.com/mcp --token-cmd='pass show mcp/prod'
+  mcpmap config add local --sse=http://localhost:3000/sse --header X-Tenant=dev
+  mcpmap -s prod list tools
+  MCPMAP_PROFILE=local mcpmap exec echo --param message=hi`,
+	PersistentPreRunE: skipServerSetup,
+}
+
+// skipServerSetup replaces validateFlags for commands that manage local
+// configuration and never connect to a server
+func skipServerSetup(cmd *cobra.Command, args []string) error { return nil }
+
+var configAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add or replace a profile using the connection flags",
+	Long: `Add or replace a profile using the connection flags.
+
+The token is taken from --token, --token-file or $MCPMAP_TOKEN and stored in
+the profile; --token-cmd stores the command instead, which runs whenever the
+profile is used.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConfigAdd,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigList,
+}
+
+var configRemoveCmd = &cobra.Command{
+	Use:               "remove <name>",
+	Aliases:           []string{"rm"},
+	Short:             "Remove a profile",
+	Args:              cobra.ExactArgs(1),
+	RunE:              runConfigRemove,
+	ValidArgsFunction: profileNameArgCompletion,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:               "show <name>",
+	Short:             "Show a profile with its token masked",
+	Args:              cobra.ExactArgs(1),
+	RunE:              runConfigShow,
+	ValidArgsFunction: profileNameArgCompletion,
+}
+
+func init() {
+	configAddCmd.Flags().StringArrayVar(&configHeaders, "header", []string{}, "Header to send with every request in format Name=value (can be repeated)")
+	configAddCmd.Flags().BoolVar(&configTLS.InsecureSkipVerify, "insecure", false, "Skip TLS certificate verification")
+	configAddCmd.Flags().StringVar(&configTLS.CACert, "ca-cert", "", "PEM file with CA certificates to trust")
+	configAddCmd.Flags().StringVar(&configTLS.ClientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	configAddCmd.Flags().StringVar(&configTLS.ClientKey, "client-key", "", "PEM private key of the client certificate")
+	configAddCmd.Flags().StringVar(&configTLS.ServerName, "tls-server-name", "", "Server name to verify the certificate against")
+	configListCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output profiles as JSON")
+	configShowCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output the profile as JSON")
+
+	configCmd.AddCommand(configAddCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configRemoveCmd)
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// profileFromFlags builds a profile from the connection flags of cmd
+func profileFromFlags(cmd *cobra.Command) (*serverProfile, error) {
+	sseFlag, httpFlag := cmd.Flag("sse"), cmd.Flag("http")
+	p := &serverProfile{}
+	switch {
+	case sseFlag.Changed && httpFlag.Changed:
+		return nil, fmt.Errorf("cannot specify both --sse and --http flags")
+	case sseFlag.Changed:
+		p.Transport, p.URL = "sse", sseFlag.Value.String()
+	case httpFlag.Changed:
+		p.Transport, p.URL = "http", httpFlag.Value.String()
+	default:
+		return nil, fmt.Errorf("must specify either --sse=<url> or --http=<url>")
+	}
+
+	// config add skips the usual setup, so the token sources are resolved
+	// here: --token-file is read and MCPMAP_TOKEN applies as for any command
+	if err := resolveCredentials(cmd, nil); err != nil {
+		return nil, err
+	}
+	if tokenCommand != nil {
+		p.TokenCmd = tokenCmd
+	} else {
+		p.Token = authToken
+	}
+	if p.Token != "" && !tokenFlagGiven(cmd) {
+		fmt.Fprintf(os.Stderr, "Storing the token from %s in the profile\n", envToken)
+	}
+	p.Proxy = proxyURL
+	if cmd.Flag("name").Changed {
+		p.Name = clientName
+	}
+	if cmd.Flag("cache-ttl").Changed {
+		p.CacheTTL = cacheTTL.String()
+	}
+
+	for _, h := range configHeaders {
+		name, value, ok := strings.Cut(h, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header format '%s', expected Name=value", h)
+		}
+		if p.Headers == nil {
+			p.Headers = make(map[string]string)
+		}
+		p.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	if configTLS != (profileTLS{}) {
+		t := configTLS
+		p.TLS = &t
+	}
+
+	return p, p.validate()
+}
+
+// tokenFlagGiven reports whether --token, --token-file or --token-cmd is set
+func tokenFlagGiven(cmd *cobra.Command) bool {
+	for _, name := range []string{"token", "token-file", "token-cmd"} {
+		if f := cmd.Flag(name); f != nil && f.Changed {
+			return true
+		}
+	}
+	return false
+}
+
+func runConfigAdd(cmd *cobra.Command, args []string) error {
+	p, err := profileFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+	_, replaced := cfg.Profiles[args[0]]
+	cfg.Profiles[args[0]] = p
+	if err := cfg.save(); err != nil {
+		return err
+	}
+
+	if replaced {
+		fmt.Printf("Profile %q updated\n", args[0])
+	} else {
+		fmt.Printf("Profile %q added\n", args[0])
+	}
+	return nil
+}
+
+// profileAuth describes how a profile authenticates without revealing secrets
+func profileAuth(p *serverProfile) string {
+	switch {
+	case p.TokenCmd != "":
+		return "token_cmd"
+	case p.Token != "":
+		return "token"
+	default:
+		return "-"
+	}
+}
+
+// masked returns a copy of p that is safe to print. Header and environment
+// values often hold credentials such as Authorization or API keys, so only
+// their names are kept.
+func (p *serverProfile) masked() *serverProfile {
+	c := *p
+	if c.Token != "" {
+		c.Token = "********"
+	}
+	c.Headers = maskedValues(p.Headers)
+	c.Env = maskedValues(p.Env)
+	return &c
+}
+
+// maskedValues returns a copy of m with every value masked
+func maskedValues(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k := range m {
+		out[k] = "********"
+	}
+	return out
+}
+
+func runConfigList(cmd *cobra.Command, args []string) error {
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		profiles := make(map[string]*serverProfile, len(cfg.Profiles))
+		for name, p := range cfg.Profiles {
+			profiles[name] = p.masked()
+		}
+		js, err := json.Marshal(profiles)
+		if err != nil {
+			return fmt.Errorf("json marshal profiles: %w", err)
+		}
+		fmt.Fprintln(os.Stdout, string(js))
+		return nil
+	}
+
+	if len(cfg.Profiles) == 0 {
+		fmt.Println("No profiles configured (add one with 'mcpmap config add')")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTRANSPORT\tTARGET\tAUTH")
+	for _, name := range cfg.profileNames() {
+		p := cfg.Profiles[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, p.Transport, p.target(), profileAuth(p))
+	}
+	return w.Flush()
+}
+
+func runConfigRemove(cmd *cobra.Command, args []string) error {
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.Profiles[args[0]]; !ok {
+		return fmt.Errorf("unknown profile %q", args[0])
+	}
+	delete(cfg.Profiles, args[0])
+	if err := cfg.save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Profile %q removed\n", args[0])
+	return nil
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+	p, ok := cfg.Profiles[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", args[0])
+	}
+
+	if jsonOutput {
+		js, err := json.Marshal(p.masked())
+		if err != nil {
+			return fmt.Errorf("json marshal profile: %w", err)
+		}
+		fmt.Fprintln(os.Stdout, string(js))
+		return nil
+	}
+
+	data, err := yaml.Marshal(p.masked())
+	if err != nil {
+		return fmt.Errorf("marshal profile: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// profileNameCompletion completes the value of --profile
+func profileNameCompletion(
+	cmd *cobra.Command,
+	args []string,
+	toComplete string,
+) ([]string, cobra.ShellCompDirective) {
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return cfg.profileNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// profileNameArgCompletion completes the profile name argument of config subcommands
+func profileNameArgCompletion(
+	cmd *cobra.Command,
+	args []string,
+	toComplete string,
+) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return profileNameCompletion(cmd, args, toComplete)
+}
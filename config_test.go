@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newProfileCmd creates a command with the flags profiles interact with
+func newProfileCmd() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("sse", "", "")
+	cmd.Flags().String("http", "", "")
+	cmd.Flags().String("profile", "", "")
+	cmd.Flags().String("token", "", "")
+	cmd.Flags().String("proxy", "", "")
+	cmd.Flags().String("name", "", "")
+	return cmd
+}
+
+func writeTestConfig(t *testing.T, cfg *appConfig) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(envProfile, "")
+	if err := cfg.save(); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+}
+
+func TestAppConfigSaveAndLoad(t *testing.T) {
+	writeTestConfig(t, &appConfig{Profiles: map[string]*serverProfile{
+		"prod": {
+			Transport: "http",
+			URL:       "https://mcp.example.com/mcp",
+			Headers:   map[string]string{"X-Tenant": "acme"},
+			Token:     "secret",
+			TLS:       &profileTLS{InsecureSkipVerify: true},
+		},
+	}})
+
+	path, _ := configFilePath()
+	if !strings.HasPrefix(path, os.Getenv("XDG_CONFIG_HOME")) || filepath.Base(path) != "config.yaml" {
+		t.Errorf("unexpected config path %s", path)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected config file with mode 0600, got %v, %v", info, err)
+	}
+	if leftovers, _ := filepath.Glob(path + ".*tmp"); len(leftovers) > 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		t.Fatalf("loadAppConfig: %v", err)
+	}
+	p := cfg.Profiles["prod"]
+	if p == nil || p.URL != "https://mcp.example.com/mcp" || p.Headers["X-Tenant"] != "acme" || !p.TLS.InsecureSkipVerify {
+		t.Errorf("profile did not survive a round trip: %+v", p)
+	}
+	if masked := p.masked(); masked.Token != "********" || p.Token != "secret" {
+		t.Errorf("masked() should only mask the copy, got %q and %q", masked.Token, p.Token)
+	}
+	if masked := p.masked(); masked.Headers["X-Tenant"] != "********" || p.Headers["X-Tenant"] != "acme" {
+		t.Errorf("masked() should mask header values in the copy, got %v and %v", masked.Headers, p.Headers)
+	}
+}
+
+func TestLoadAppConfigMissingFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		t.Fatalf("loadAppConfig: %v", err)
+	}
+	if len(cfg.Profiles) != 0 || cfg.Profiles == nil {
+		t.Errorf("expected empty profile map, got %v", cfg.Profiles)
+	}
+}
+
+func TestSelectedProfile(t *testing.T) {
+	writeTestConfig(t, &appConfig{Profiles: map[string]*serverProfile{
+		"a":   {Transport: "sse", URL: "http://a/sse"},
+		"b":   {Transport: "http", URL: "http://b/mcp"},
+		"bad": {Transport: "ws", URL: "ws://c"},
+	}})
+
+	tests := []struct {
+		name    string
+		env     string
+		flag    string
+		wantURL string
+		wantErr string
+	}{
+		{"none", "", "", "", ""},
+		{"from env", "a", "", "http://a/sse", ""},
+		{"flag overrides env", "a", "b", "http://b/mcp", ""},
+		{"unknown", "", "missing", "", `unknown profile "missing"`},
+		{"invalid", "", "bad", "", "transport must be sse, http or stdio"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envProfile, tt.env)
+			cmd := newProfileCmd()
+			if tt.flag != "" {
+				cmd.Flags().Set("profile", tt.flag)
+			}
+
+			p, err := selectedProfile(cmd)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if p != nil {
+				got = p.URL
+			}
+			if got != tt.wantURL {
+				t.Errorf("got profile %q, want %q", got, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestParseTransportFlagsWithProfile(t *testing.T) {
+	profile := &serverProfile{Transport: "sse", URL: "http://profile/sse"}
+
+	cmd := newProfileCmd()
+	config, err := parseTransportFlags(cmd, profile)
+	if err != nil || config.transportType != "sse" || config.serverURL != "http://profile/sse" {
+		t.Errorf("expected profile transport, got %+v, %v", config, err)
+	}
+
+	cmd.Flags().Set("http", "http://flag/mcp")
+	config, err = parseTransportFlags(cmd, profile)
+	if err != nil || config.transportType != "http" || config.serverURL != "http://flag/mcp" {
+		t.Errorf("expected flag to override profile, got %+v, %v", config, err)
+	}
+
+	optional := newProfileCmd()
+	optional.Annotations = map[string]string{annotationTransportOptional: "true"}
+	if config, err := parseTransportFlags(optional, profile); err != nil || config == nil {
+		t.Errorf("expected profile to apply to transport-optional command, got %+v, %v", config, err)
+	}
+}
+
+func TestParseTransportFlagsCacheCommands(t *testing.T) {
+	for _, cmd := range []*cobra.Command{cacheShowCmd, cacheRmCmd, cachePruneCmd, cacheExportCmd, cacheImportCmd, cacheSearchCmd} {
+		if config, err := parseTransportFlags(cmd, nil); err != nil || config != nil {
+			t.Errorf("cache %s should run without a server, got %+v, %v", cmd.Name(), config, err)
+		}
+	}
+
+	// Other commands of the same name still need a server
+	show := newProfileCmd()
+	show.Use = "show"
+	if _, err := parseTransportFlags(show, nil); !errors.Is(err, errNoTransport) {
+		t.Errorf("expected errNoTransport for another show command, got %v", err)
+	}
+}
+
+func TestScopeProfile(t *testing.T) {
+	p := &serverProfile{
+		Transport: "http", URL: "https://prod.example.com/mcp",
+		Token:   "prod-secret",
+		Headers: map[string]string{"X-Api-Key": "prod-key"},
+		TLS:     &profileTLS{InsecureSkipVerify: true},
+		Env:     map[string]string{"API_KEY": "prod-env"},
+		Proxy:   "http://profile-proxy:8080",
+		Name:    "profile-client",
+	}
+
+	cmd := newProfileCmd()
+	if got := scopeProfile(cmd, p); got != p {
+		t.Errorf("the profile picking the server should be used as is, got %+v", got)
+	}
+
+	cmd.Flags().Set("sse", "https://other.example.com/sse")
+	got := scopeProfile(cmd, p)
+	if got.Token != "" || got.Headers != nil || got.TLS != nil || got.Env != nil {
+		t.Errorf("server settings of the profile must not apply to another server, got %+v", got)
+	}
+	if got.Proxy != p.Proxy || got.Name != p.Name {
+		t.Errorf("client settings should still apply, got %+v", got)
+	}
+
+	oldToken := authToken
+	defer func() { authToken = oldToken }()
+	t.Setenv(envToken, "")
+	authToken = ""
+	if err := resolveCredentials(cmd, got); err != nil || authToken != "" {
+		t.Errorf("expected no token for the other server, got %q, %v", authToken, err)
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	oldProxy, oldName := proxyURL, clientName
+	defer func() {
+		proxyURL, clientName = oldProxy, oldName
+		requestHeaders, clientTLS = nil, nil
+	}()
+
+	cmd := newProfileCmd()
+	cmd.Flags().Set("proxy", "http://flag-proxy:8080")
+	proxyURL = "http://flag-proxy:8080"
+
+	p := &serverProfile{
+		Transport: "http", URL: "http://x",
+		Proxy:   "http://profile-proxy:8080",
+		Name:    "profile-client",
+		Headers: map[string]string{"X-Test": "1"},
+	}
+	applyProfile(cmd, p)
+
+	if proxyURL != "http://flag-proxy:8080" {
+		t.Errorf("expected --proxy to take precedence, got %q", proxyURL)
+	}
+	if clientName != "profile-client" || requestHeaders["X-Test"] != "1" {
+		t.Errorf("profile settings not applied: name=%q headers=%v", clientName, requestHeaders)
+	}
+}
+
+func TestServerProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile serverProfile
+		wantErr string
+	}{
+		{"valid", serverProfile{Transport: "http", URL: "http://x"}, ""},
+		{"bad transport", serverProfile{Transport: "ws", URL: "x"}, "transport must be"},
+		{"stdio", serverProfile{Transport: "stdio", Command: []string{"npx", "server"}}, ""},
+		{"stdio without command", serverProfile{Transport: "stdio"}, "missing command"},
+		{"missing url", serverProfile{Transport: "sse"}, "missing url"},
+		{"token and command", serverProfile{Transport: "sse", URL: "x", Token: "t", TokenCmd: "c"}, "mutually exclusive"},
+		{"bad cache ttl", serverProfile{Transport: "http", URL: "x", CacheTTL: "daily"}, "invalid cache_ttl"},
+		{"cert without key", serverProfile{Transport: "sse", URL: "x", TLS: &profileTLS{ClientCert: "c.pem"}}, "set together"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateHTTPClientSendsProfileHeaders(t *testing.T) {
+	defer func() { requestHeaders = nil }()
+	requestHeaders = map[string]string{"X-Tenant": "acme"}
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	client, err := createHTTPClient("", "tok")
+	if err != nil {
+		t.Fatalf("createHTTPClient: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+
+	if got.Get("X-Tenant") != "acme" || got.Get("Authorization") != "Bearer tok" {
+		t.Errorf("expected profile header and token, got %v", got)
+	}
+}
+
+func TestProfileFromFlagsTokenSources(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenPath, []byte("file-token\n"), 0600)
+
+	tests := []struct {
+		name         string
+		flags        map[string]string
+		env          string
+		wantToken    string
+		wantTokenCmd string
+		wantErr      bool
+	}{
+		{name: "token flag", flags: map[string]string{"token": "flag-token"}, wantToken: "flag-token"},
+		{name: "token file", flags: map[string]string{"token-file": tokenPath}, wantToken: "file-token"},
+		{name: "token command", flags: map[string]string{"token-cmd": "pass show mcp"}, wantTokenCmd: "pass show mcp"},
+		{name: "environment", env: "env-token", wantToken: "env-token"},
+		{name: "flag overrides environment", flags: map[string]string{"token": "flag-token"}, env: "env-token", wantToken: "flag-token"},
+		{name: "two sources", flags: map[string]string{"token": "a", "token-file": tokenPath}, wantErr: true},
+		{name: "missing token file", flags: map[string]string{"token-file": tokenPath + ".missing"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldToken, oldFile, oldCmd, oldCommand := authToken, tokenFile, tokenCmd, tokenCommand
+			defer func() { authToken, tokenFile, tokenCmd, tokenCommand = oldToken, oldFile, oldCmd, oldCommand }()
+			authToken, tokenFile, tokenCmd, tokenCommand = "", "", "", nil
+			t.Setenv(envToken, tt.env)
+
+			cmd := &cobra.Command{}
+			cmd.Flags().String("sse", "", "")
+			cmd.Flags().String("http", "", "")
+			cmd.Flags().String("name", "", "")
+			cmd.Flags().Duration("cache-ttl", 0, "")
+			cmd.Flags().StringVar(&authToken, "token", "", "")
+			cmd.Flags().StringVar(&tokenFile, "token-file", "", "")
+			cmd.Flags().StringVar(&tokenCmd, "token-cmd", "", "")
+			cmd.Flags().Set("http", "https://mcp.example.com/mcp")
+			for name, value := range tt.flags {
+				cmd.Flags().Set(name, value)
+			}
+
+			p, err := profileFromFlags(cmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("profileFromFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if p.Token != tt.wantToken || p.TokenCmd != tt.wantTokenCmd {
+				t.Errorf("got token %q and token_cmd %q, want %q and %q", p.Token, p.TokenCmd, tt.wantToken, tt.wantTokenCmd)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// credentials.go - Bearer token sources: flags, environment, files and helper commands
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// envToken supplies the bearer token when no token flag is given
+const envToken = "MCPMAP_TOKEN"
+
+var (
+	tokenFile string
+	tokenCmd  string
+
+	// tokenCommand supplies the token when it comes from --token-cmd or a
+	// profile's token_cmd. authToken is empty in that case.
+	tokenCommand *commandToken
+)
+
+// commandToken runs a helper command that prints a bearer token. The output
+// is cached for the rest of the run and the command is only run again when
+// the server rejects the token.
+type commandToken struct {
+	command string
+
+	mu    sync.Mutex
+	token string
+}
+
+func newCommandToken(command string) *commandToken {
+	return &commandToken{command: command}
+}
+
+// Token returns the cached token, running the command on first use
+func (c *commandToken) Token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" {
+		return c.token, nil
+	}
+
+	out, err := shellCommand(c.command).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("run token command: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("run token command: %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("token command printed nothing")
+	}
+	c.token = token
+	return token, nil
+}
+
+// shellCommand runs command with the platform's shell: cmd on Windows, sh
+// elsewhere
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// Invalidate forgets rejected so the next Token call runs the command again.
+// Concurrent requests rejected with the same token only cause one new run.
+func (c *commandToken) Invalidate(rejected string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == rejected {
+		c.token = ""
+	}
+}
+
+// readTokenFile returns the token stored in path, without surrounding whitespace
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// resolveCredentials sets authToken or tokenCommand. Flags take precedence
+// over MCPMAP_TOKEN, which takes precedence over the selected profile.
+func resolveCredentials(cmd *cobra.Command, profile *serverProfile) error {
+	changed := func(name string) bool {
+		f := cmd.Flag(name)
+		return f != nil && f.Changed
+	}
+
+	set := 0
+	for _, name := range []string{"token", "token-file", "token-cmd"} {
+		if changed(name) {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("--token, --token-file and --token-cmd are mutually exclusive")
+	}
+
+	tokenCommand = nil
+	switch {
+	case changed("token"):
+		return nil
+	case changed("token-file"):
+		token, err := readTokenFile(tokenFile)
+		if err != nil {
+			return err
+		}
+		authToken = token
+	case changed("token-cmd"):
+		authToken = ""
+		tokenCommand = newCommandToken(tokenCmd)
+	case os.Getenv(envToken) != "":
+		authToken = os.Getenv(envToken)
+	case profile != nil && profile.TokenCmd != "":
+		authToken = ""
+		tokenCommand = newCommandToken(profile.TokenCmd)
+	case profile != nil:
+		authToken = profile.Token
+	}
+	return nil
+}
+
+// currentToken returns the bearer token the server will be sent, running the
+// token command if necessary
+func currentToken() (string, error) {
+	if authToken != "" || tokenCommand == nil {
+		return authToken, nil
+	}
+	return tokenCommand.Token()
+}
+
+// retryWithNewToken reports whether a request answered with resp should be
+// sent again with a fresh token from the command
+func retryWithNewToken(req *http.Request, resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized &&
+		(req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// countingTokenCommand returns a shell command that prints tok-1, tok-2, ...
+// on successive runs
+func countingTokenCommand(t *testing.T) string {
+	t.Helper()
+	counter := filepath.Join(t.TempDir(), "count")
+	return fmt.Sprintf(`n=$(( $(cat %[1]q 2>/dev/null || echo 0) + 1 )); echo $n > %[1]q; echo tok-$n`, counter)
+}
+
+func TestResolveCredentials(t *testing.T) {
+	tokenPath := writeTestFile(t, "token", "  from-file\n")
+	emptyPath := writeTestFile(t, "empty", "\n")
+
+	tests := []struct {
+		name        string
+		flags       map[string]string
+		env         string
+		profile     *serverProfile
+		wantToken   string
+		wantCommand string
+		wantErr     string
+	}{
+		{"nothing", nil, "", nil, "", "", ""},
+		{"token flag", map[string]string{"token": "flag"}, "env", nil, "flag", "", ""},
+		{"token file", map[string]string{"token-file": tokenPath}, "env", nil, "from-file", "", ""},
+		{"empty token file", map[string]string{"token-file": emptyPath}, "", nil, "", "", "is empty"},
+		{"token command", map[string]string{"token-cmd": "echo x"}, "env", nil, "", "echo x", ""},
+		{"env", nil, "env", &serverProfile{Token: "profile"}, "env", "", ""},
+		{"profile token", nil, "", &serverProfile{Token: "profile"}, "profile", "", ""},
+		{"profile command", nil, "", &serverProfile{TokenCmd: "echo p"}, "", "echo p", ""},
+		{"flag overrides profile", map[string]string{"token-cmd": "echo f"}, "", &serverProfile{Token: "profile"}, "", "echo f", ""},
+		{"exclusive", map[string]string{"token": "a", "token-cmd": "b"}, "", nil, "", "", "mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { authToken, tokenFile, tokenCmd, tokenCommand = "", "", "", nil }()
+			t.Setenv(envToken, tt.env)
+
+			cmd := &cobra.Command{}
+			cmd.Flags().StringVar(&authToken, "token", "", "")
+			cmd.Flags().StringVar(&tokenFile, "token-file", "", "")
+			cmd.Flags().StringVar(&tokenCmd, "token-cmd", "", "")
+			for name, value := range tt.flags {
+				cmd.Flags().Set(name, value)
+			}
+
+			err := resolveCredentials(cmd, tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if authToken != tt.wantToken {
+				t.Errorf("authToken = %q, want %q", authToken, tt.wantToken)
+			}
+			gotCommand := ""
+			if tokenCommand != nil {
+				gotCommand = tokenCommand.command
+			}
+			if gotCommand != tt.wantCommand {
+				t.Errorf("token command = %q, want %q", gotCommand, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestCommandTokenCaching(t *testing.T) {
+	c := newCommandToken(countingTokenCommand(t))
+
+	for i := 0; i < 3; i++ {
+		if token, err := c.Token(); err != nil || token != "tok-1" {
+			t.Fatalf("call %d: got %q, %v; want cached tok-1", i, token, err)
+		}
+	}
+
+	c.Invalidate("some-other-token")
+	if token, _ := c.Token(); token != "tok-1" {
+		t.Errorf("invalidating a different token should keep the cache, got %q", token)
+	}
+
+	c.Invalidate("tok-1")
+	if token, _ := c.Token(); token != "tok-2" {
+		t.Errorf("expected the command to run again, got %q", token)
+	}
+
+	failing := newCommandToken("echo denied >&2; exit 3")
+	if _, err := failing.Token(); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("expected command failure with stderr, got %v", err)
+	}
+}
+
+func TestAuthTransportRefreshesOn401(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "Bearer tok-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "ok %s", body)
+	}))
+	defer server.Close()
+
+	client, err := newHTTPClient("", "", newCommandToken(countingTokenCommand(t)))
+	if err != nil {
+		t.Fatalf("newHTTPClient: %v", err)
+	}
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(body) != "ok payload" {
+		t.Errorf("expected retried request to succeed with its body, got %d %q", resp.StatusCode, body)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected exactly one retry, server saw %d requests", requests.Load())
+	}
+
+	// A static token is never retried
+	requests.Store(0)
+	static, _ := createHTTPClient("", "tok-1")
+	resp, err = static.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || requests.Load() != 1 {
+		t.Errorf("expected a single 401, got %d after %d requests", resp.StatusCode, requests.Load())
+	}
+}
+
+func TestReadTokenFile(t *testing.T) {
+	if _, err := readTokenFile(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
@@ -44,6 +44,7 @@ Examples:
 
 func init() {
 	rootCmd.AddCommand(execCmd)
+	addRecordFlag(execCmd)
 	execCmd.Flags().
 		StringArrayVar(&params, "param", []string{}, "Specify a parameter for the tool in format name=value (can be repeated)")
 
@@ -56,9 +57,13 @@ func runExec(cmd *cobra.Command, args []string) error {
 	toolName := args[0]
 
 	return withSession(ctx, func(session *mcp.ClientSession) error {
-		// Try to fetch schema (best-effort)
+		// Try to fetch the tool definition (best-effort)
 		var toolParams map[string]any
-		schema, err := getToolSchema(ctx, session, toolName)
+		tool, err := getTool(ctx, session, toolName)
+		var schema *ToolSchema
+		if err == nil {
+			schema, err = toolSchema(tool)
+		}
 		if err != nil {
 			// Schema fetch failed, warn and fall back to string parsing
 			fmt.Fprintf(os.Stderr, "Warning: Could not fetch schema for tool %q: %v\n", toolName, err)
@@ -76,6 +81,11 @@ func runExec(cmd *cobra.Command, args []string) error {
 			}
 		}
 
+		// Inspect annotations before calling anything with side effects
+		if err := defaultToolGate.Check(tool, toolName); err != nil {
+			return err
+		}
+
 		result, err := session.CallTool(ctx, &mcp.CallToolParams{
 			Name:      toolName,
 			Arguments: toolParams,
@@ -88,7 +98,7 @@ func runExec(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("json marshal result: %w", err)
 		}
-		fmt.Fprintln(os.Stdout, string(js))
+		fmt.Fprintln(os.Stdout, string(outputRedactor.JSON(js)))
 		return nil
 	})
 }
@@ -168,9 +178,24 @@ func extractServerConfig(cmd *cobra.Command) (serverURL, transportType string) {
 	if httpFlag := cmd.Flag("http"); httpFlag != nil && httpFlag.Changed {
 		return httpFlag.Value.String(), "http"
 	}
+	if profile, err := selectedProfile(cmd); err == nil && profile != nil {
+		return profile.target(), profile.Transport
+	}
 	return "", ""
 }
 
+// prepareCompletion applies the selected profile and credentials, which the
+// completion command does not get from validateFlags, so completion connects
+// the same way and uses the same cache entry as the completed command
+func prepareCompletion(cmd *cobra.Command) {
+	_ = configureCache(cmd)
+	profile, _ := selectedProfile(cmd)
+	if profile != nil {
+		applyProfile(cmd, profile)
+	}
+	_ = resolveCredentials(cmd, profile)
+}
+
 // withSession creates a session, invokes fn, and ensures the session is closed.
 // It returns any error produced during session creation or execution.
 func withSession(ctx context.Context, fn func(*mcp.ClientSession) error) error {
@@ -195,10 +220,14 @@ func toolNameCompletion(
 	if serverURL == "" {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
+	prepareCompletion(cmd)
 
-	// Try cache first
-	c := cache.New(serverURL, transportType, authToken, clientName)
-	if data, _, _ := c.Load(); data != nil && len(data.Tools) > 0 {
+	// Try cache first, answering from stale data while it is refreshed
+	c := openCache(serverURL, transportType)
+	if data, fresh := loadCached(c); data != nil && len(data.Tools) > 0 {
+		if !fresh {
+			refreshCacheInBackground(cmd, c)
+		}
 		completions := make([]string, 0, len(data.Tools))
 		for _, tool := range data.Tools {
 			completions = append(completions, tool.Name)
@@ -212,6 +241,14 @@ func toolNameCompletion(
 
 	session, err := createSession(ctx, transportType, serverURL, proxyURL, authToken, clientName)
 	if err != nil {
+		// Offline, answer from data cached with another token, e.g. imported
+		if data := loadServerCache(serverURL, transportType); data != nil {
+			completions := make([]string, 0, len(data.Tools))
+			for _, tool := range data.Tools {
+				completions = append(completions, tool.Name)
+			}
+			return completions, cobra.ShellCompDirectiveNoFileComp
+		}
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 	defer session.Close()
@@ -221,11 +258,9 @@ func toolNameCompletion(
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	// Update cache for next time
-	go func() {
-		cacheData := &cache.CacheData{Tools: tools}
-		c.Save(cacheData)
-	}()
+	// Update the cached tools for next time, before the completion process
+	// exits. The other sections are kept.
+	_ = c.Update(&cache.CacheData{Tools: tools, ServerInfo: sessionServerInfo(session)}, cache.SectionTools)
 
 	completions := make([]string, 0, len(tools))
 	for _, tool := range tools {
@@ -251,10 +286,14 @@ func paramCompletion(
 	}
 
 	toolName := args[0]
+	prepareCompletion(cmd)
 
-	// Try cache first
-	c := cache.New(serverURL, transportType, authToken, clientName)
-	if data, _, _ := c.Load(); data != nil && len(data.Tools) > 0 {
+	// Try cache first, answering from stale data while it is refreshed
+	c := openCache(serverURL, transportType)
+	if data, fresh := loadCached(c); data != nil && len(data.Tools) > 0 {
+		if !fresh {
+			refreshCacheInBackground(cmd, c)
+		}
 		// Find the tool in cached data
 		for _, tool := range data.Tools {
 			if tool.Name == toolName {
@@ -277,6 +316,18 @@ func paramCompletion(
 
 	session, err := createSession(ctx, transportType, serverURL, proxyURL, authToken, clientName)
 	if err != nil {
+		// Offline, answer from data cached with another token, e.g. imported
+		if data := loadServerCache(serverURL, transportType); data != nil {
+			for _, tool := range data.Tools {
+				if tool.Name == toolName {
+					completions := []string{}
+					for _, param := range extractParametersFromSchema(tool.InputSchema) {
+						completions = append(completions, param.Name+"=")
+					}
+					return completions, cobra.ShellCompDirectiveNoFileComp
+				}
+			}
+		}
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 	defer session.Close()
@@ -287,12 +338,9 @@ func paramCompletion(
 	}
 
 	// Update cache for next time (get all tools to cache them)
-	go func() {
-		if tools, err := getTools(ctx, session); err == nil {
-			cacheData := &cache.CacheData{Tools: tools}
-			c.Save(cacheData)
-		}
-	}()
+	if tools, err := getTools(ctx, session); err == nil {
+		_ = c.Update(&cache.CacheData{Tools: tools, ServerInfo: sessionServerInfo(session)}, cache.SectionTools)
+	}
 
 	completions := make([]string, 0, len(params))
 	for _, param := range params {
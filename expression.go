@@ -0,0 +1,449 @@
+// expression.go - Template expressions referring to earlier results in workflow files
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// templatePattern matches one {{ expression }} in a string
+var templatePattern = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
+// comparisonOperators are tried in order, so two-character operators win
+var comparisonOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// expression is a value, optionally negated with a leading ! or compared with
+// a second value: "steps.search.structured.total > 0"
+type expression struct {
+	source string
+	not    bool
+	left   operand
+	op     string
+	right  operand
+}
+
+// operand is either a path into the scope or a JSON literal
+type operand struct {
+	path    []any // string keys and int indices
+	literal any
+}
+
+// parseExpression parses the text between {{ and }}
+func parseExpression(s string) (*expression, error) {
+	e := &expression{source: s}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "!") && !strings.HasPrefix(s, "!=") {
+		e.not = true
+		s = strings.TrimSpace(s[1:])
+	}
+
+	left, op, right := splitComparison(s)
+	var err error
+	if e.left, err = parseOperand(left); err != nil {
+		return nil, fmt.Errorf("expression %q: %w", e.source, err)
+	}
+	if op != "" {
+		e.op = op
+		if e.right, err = parseOperand(right); err != nil {
+			return nil, fmt.Errorf("expression %q: %w", e.source, err)
+		}
+	}
+	return e, nil
+}
+
+// splitComparison splits s at the first comparison operator outside quotes
+// and brackets
+func splitComparison(s string) (left, op, right string) {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0:
+			for _, candidate := range comparisonOperators {
+				if strings.HasPrefix(s[i:], candidate) {
+					return strings.TrimSpace(s[:i]), candidate, strings.TrimSpace(s[i+len(candidate):])
+				}
+			}
+		}
+	}
+	return s, "", ""
+}
+
+// parseOperand parses a JSON literal (number, "string", true, false, null),
+// a 'single-quoted' string or a path such as steps.search.items[0].id
+func parseOperand(s string) (operand, error) {
+	if s == "" {
+		return operand{}, fmt.Errorf("missing value")
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return operand{literal: s[1 : len(s)-1]}, nil
+	}
+	var literal any
+	if err := json.Unmarshal([]byte(s), &literal); err == nil {
+		return operand{literal: literal}, nil
+	}
+
+	path, err := parsePath(s)
+	if err != nil {
+		return operand{}, err
+	}
+	return operand{path: path}, nil
+}
+
+// parsePath splits a path into keys and indices. A leading "$." is accepted
+// for JSONPath familiarity; keys may also be written as ["key"] or ['key'].
+func parsePath(s string) ([]any, error) {
+	rest := strings.TrimPrefix(s, "$.")
+	var path []any
+	for rest != "" {
+		switch rest[0] {
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q: missing ]", s)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				path = append(path, inner[1:len(inner)-1])
+			} else if index, err := strconv.Atoi(inner); err == nil {
+				path = append(path, index)
+			} else {
+				return nil, fmt.Errorf("path %q: invalid index [%s]", s, inner)
+			}
+			rest = rest[end+1:]
+		case '.':
+			if len(path) == 0 || len(rest) == 1 || rest[1] == '.' || rest[1] == '[' {
+				return nil, fmt.Errorf("path %q: empty key", s)
+			}
+			rest = rest[1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if strings.ContainsAny(key, " \t\"'") {
+				return nil, fmt.Errorf("path %q: invalid key %q", s, key)
+			}
+			path = append(path, key)
+			rest = rest[end:]
+		}
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	if _, ok := path[0].(string); !ok {
+		return nil, fmt.Errorf("path %q must start with a name", s)
+	}
+	return path, nil
+}
+
+// formatPath renders the first n elements of path for error messages
+func formatPath(path []any, n int) string {
+	var b strings.Builder
+	for i, p := range path[:n] {
+		switch p := p.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", p)
+		default:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			fmt.Fprint(&b, p)
+		}
+	}
+	return b.String()
+}
+
+// lookupPath resolves path in scope. Negative indices count from the end and
+// arrays and strings have a length.
+func lookupPath(scope map[string]any, path []any) (any, error) {
+	var current any = scope
+	for i, p := range path {
+		switch v := current.(type) {
+		case map[string]any:
+			key, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s is an object, not an array", formatPath(path, i))
+			}
+			value, exists := v[key]
+			if !exists {
+				return nil, fmt.Errorf("%s not found", formatPath(path, i+1))
+			}
+			current = value
+		case []any:
+			if p == "length" {
+				current = len(v)
+				continue
+			}
+			index, ok := p.(int)
+			if !ok {
+				return nil, fmt.Errorf("%s is an array, not an object", formatPath(path, i))
+			}
+			if index < 0 {
+				index += len(v)
+			}
+			if index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("%s: index %d out of range (length %d)", formatPath(path, i), p, len(v))
+			}
+			current = v[index]
+		case string:
+			if p != "length" {
+				return nil, fmt.Errorf("%s is a string", formatPath(path, i))
+			}
+			current = len(v)
+		case nil:
+			return nil, fmt.Errorf("%s is null", formatPath(path, i))
+		default:
+			return nil, fmt.Errorf("%s is a %T, not an object or array", formatPath(path, i), v)
+		}
+	}
+	return current, nil
+}
+
+func (o operand) eval(scope map[string]any) (any, error) {
+	if o.path == nil {
+		return o.literal, nil
+	}
+	return lookupPath(scope, o.path)
+}
+
+// eval evaluates the expression. Comparisons and negations return a bool.
+func (e *expression) eval(scope map[string]any) (any, error) {
+	left, err := e.left.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	value := left
+	if e.op != "" {
+		right, err := e.right.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = compareValues(left, e.op, right); err != nil {
+			return nil, fmt.Errorf("expression %q: %w", e.source, err)
+		}
+	}
+	if e.not {
+		return !truthy(value), nil
+	}
+	return value, nil
+}
+
+// compareValues compares numbers numerically and everything else by its JSON
+// form. Only numbers and strings can be ordered.
+func compareValues(left any, op string, right any) (bool, error) {
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			switch op {
+			case "==":
+				return l == r, nil
+			case "!=":
+				return l != r, nil
+			case ">":
+				return l > r, nil
+			case ">=":
+				return l >= r, nil
+			case "<":
+				return l < r, nil
+			case "<=":
+				return l <= r, nil
+			}
+		}
+	}
+
+	switch op {
+	case "==":
+		return reflect.DeepEqual(jsonValue(left), jsonValue(right)), nil
+	case "!=":
+		return !reflect.DeepEqual(jsonValue(left), jsonValue(right)), nil
+	}
+
+	l, lok := left.(string)
+	r, rok := right.(string)
+	if !lok || !rok {
+		return false, fmt.Errorf("cannot order %s and %s", compactJSON(left), compactJSON(right))
+	}
+	switch op {
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	case "<":
+		return l < r, nil
+	default:
+		return l <= r, nil
+	}
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// truthy reports whether v counts as true in a condition: null, false, zero,
+// empty strings, arrays and objects do not
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	}
+	if n, ok := toNumber(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+// renderTemplate substitutes the expressions in s. A string that is a single
+// expression yields the value itself, so arrays, objects and numbers keep
+// their type; otherwise values are inserted as text, non-strings as JSON.
+func renderTemplate(s string, scope map[string]any) (any, error) {
+	matches := templatePattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return evalSource(s[matches[0][2]:matches[0][3]], scope)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		value, err := evalSource(s[m[2]:m[3]], scope)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(templateText(value))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+func evalSource(source string, scope map[string]any) (any, error) {
+	e, err := parseExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	return e.eval(scope)
+}
+
+// templateText is the text inserted for value in a larger string
+func templateText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	return compactJSON(value)
+}
+
+// renderValue renders every string in a decoded JSON value
+func renderValue(v any, scope map[string]any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return renderTemplate(v, scope)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			rendered, err := renderValue(e, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			rendered, err := renderValue(e, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// templateExpressions returns the parsed expressions of every string in v
+func templateExpressions(v any) ([]*expression, error) {
+	var exprs []*expression
+	var walk func(v any) error
+	walk = func(v any) error {
+		switch v := v.(type) {
+		case string:
+			for _, m := range templatePattern.FindAllStringSubmatch(v, -1) {
+				e, err := parseExpression(m[1])
+				if err != nil {
+					return err
+				}
+				exprs = append(exprs, e)
+			}
+		case map[string]any:
+			for _, k := range slices.Sorted(maps.Keys(v)) {
+				if err := walk(v[k]); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for _, e := range v {
+				if err := walk(e); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return exprs, walk(v)
+}
+
+// paths returns the paths an expression reads
+func (e *expression) paths() [][]any {
+	var paths [][]any
+	for _, o := range []operand{e.left, e.right} {
+		if o.path != nil {
+			paths = append(paths, o.path)
+		}
+	}
+	return paths
+}
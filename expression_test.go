@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{"steps.search.structured.items[0].id", "[steps search structured items 0 id]", ""},
+		{"$.vars.query", "[vars query]", ""},
+		{`steps["my step"]['text']`, "[steps my step text]", ""},
+		{"item[-1]", "[item -1]", ""},
+		{"steps..x", "", "empty key"},
+		{"steps[x]", "", "invalid index"},
+		{"steps[0", "", "missing ]"},
+		{"[0].x", "", "must start with a name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parsePath(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("got %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	scope := map[string]any{
+		"vars": map[string]any{"query": "login", "limit": 5.0},
+		"steps": map[string]any{
+			"search": map[string]any{
+				"text":       "found",
+				"structured": map[string]any{"items": []any{map[string]any{"id": "a"}, map[string]any{"id": "b"}}, "total": 2.0},
+			},
+			"skipped": nil,
+		},
+	}
+
+	tests := []struct {
+		template string
+		want     any
+		wantErr  string
+	}{
+		{"plain", "plain", ""},
+		{"{{ vars.limit }}", 5.0, ""},
+		{"{{steps.search.structured.items[1].id}}", "b", ""},
+		{"{{ steps.search.structured.items[-1].id }}", "b", ""},
+		{"{{ steps.search.structured.items.length }}", 2, ""},
+		{"q={{ vars.query }}&n={{ vars.limit }}", "q=login&n=5", ""},
+		{"{{ steps.search.structured.items[0] }}", map[string]any{"id": "a"}, ""},
+		{"{{ steps.search.structured.total > 1 }}", true, ""},
+		{"{{ steps.search.structured.total == 2 }}", true, ""},
+		{"{{ vars.query == 'login' }}", true, ""},
+		{`{{ vars.query != "login" }}`, false, ""},
+		{"{{ !steps.skipped }}", true, ""},
+		{"{{ steps.search.text < steps.search.structured.total }}", nil, "cannot order"},
+		{"{{ steps.missing.text }}", nil, "steps.missing not found"},
+		{"{{ steps.skipped.text }}", nil, "steps.skipped is null"},
+		{"{{ steps.search.structured.items[5] }}", nil, "out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := renderTemplate(tt.template, scope)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruthy(t *testing.T) {
+	tests := []struct {
+		value any
+		want  bool
+	}{
+		{nil, false},
+		{false, false},
+		{0.0, false},
+		{"", false},
+		{[]any{}, false},
+		{map[string]any{}, false},
+		{true, true},
+		{1, true},
+		{"no", true},
+		{[]any{nil}, true},
+	}
+
+	for _, tt := range tests {
+		if got := truthy(tt.value); got != tt.want {
+			t.Errorf("truthy(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
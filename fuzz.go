@@ -0,0 +1,684 @@
+// fuzz.go - Schema-aware fuzzing of MCP tool arguments
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fuzzOutDir   string
+	fuzzRate     float64
+	fuzzTimeout  time.Duration
+	fuzzMaxCases int
+)
+
+// fuzzHugeSize is the length of oversized strings and arrays sent to tools
+const fuzzHugeSize = 1 << 16
+
+var fuzzCmd = &cobra.Command{
+	Use:   "fuzz <tool>",
+	Short: "Fuzz a tool with boundary and malformed arguments derived from its schema",
+	Long: `Fuzz a tool with boundary and malformed arguments derived from its schema.
+
+Test cases are generated from the tool's input schema: type confusion, huge
+strings, negative and overflowing integers, path traversal and injection
+payloads for string parameters, values outside enums and missing required
+fields. Cases are sent over a single session at a limited rate.
+
+Results are written to the output directory as each case completes, so an
+interrupted run keeps them. Secrets in tool output are redacted unless
+--no-redact is given.
+  results.jsonl   one line per case with arguments, timing, result and error;
+                  slowness is judged against the median once the run is
+                  over, so slow cases get a further {"index": N, "slow": true}
+                  line at the end
+  corpus/         unique interesting responses (crashes, errors, leaks, slow calls)
+  summary.json    counts per outcome and detected timing anomalies, written
+                  when the run finishes or is interrupted
+
+Examples:
+  mcpmap --sse=http://localhost:3000/sse fuzz read_file
+  mcpmap --http=http://localhost:8080/mcp fuzz search --rate 2 --out ./fuzz-search`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFuzz,
+}
+
+func init() {
+	rootCmd.AddCommand(fuzzCmd)
+	fuzzCmd.Flags().StringVarP(&fuzzOutDir, "out", "o", "fuzz-results", "Directory to write results to")
+	fuzzCmd.Flags().Float64Var(&fuzzRate, "rate", 5, "Maximum tool calls per second (0 for unlimited)")
+	fuzzCmd.Flags().DurationVar(&fuzzTimeout, "timeout", 10*time.Second, "Timeout for each tool call")
+	fuzzCmd.Flags().IntVar(&fuzzMaxCases, "max-cases", 0, "Maximum number of cases to run (0 for all)")
+
+	fuzzCmd.ValidArgsFunction = toolNameCompletion
+}
+
+// fuzzCase is a single set of arguments to send to a tool
+type fuzzCase struct {
+	Name      string         `json:"name"`
+	Param     string         `json:"param,omitempty"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+// fuzzValue is a labelled argument value produced by a mutation
+type fuzzValue struct {
+	label string
+	value any
+}
+
+// Fuzz outcomes
+const (
+	fuzzOutcomeOK      = "ok"
+	fuzzOutcomeError   = "error"
+	fuzzOutcomeTimeout = "timeout"
+	fuzzOutcomeCrash   = "crash"
+)
+
+// fuzzResult records what happened when a case was sent to the tool
+type fuzzResult struct {
+	Index       int                 `json:"index"`
+	Case        string              `json:"case"`
+	Param       string              `json:"param,omitempty"`
+	Arguments   map[string]any      `json:"arguments"`
+	Outcome     string              `json:"outcome"`
+	DurationMs  int64               `json:"duration_ms"`
+	Error       string              `json:"error,omitempty"`
+	Result      *mcp.CallToolResult `json:"result,omitempty"`
+	Interesting []string            `json:"interesting,omitempty"`
+	Slow        bool                `json:"slow,omitempty"`
+}
+
+// fuzzSlowMark is appended to results.jsonl for a case found slow after the
+// run, its result line having been written when it completed
+type fuzzSlowMark struct {
+	Index int  `json:"index"`
+	Slow  bool `json:"slow"`
+}
+
+// fuzzSummary is written to summary.json once the run completes
+type fuzzSummary struct {
+	Tool        string         `json:"tool"`
+	Server      string         `json:"server"`
+	Cases       int            `json:"cases"`
+	Completed   int            `json:"completed"`
+	Interrupted bool           `json:"interrupted,omitempty"`
+	Outcomes    map[string]int `json:"outcomes"`
+	Interesting int            `json:"interesting"`
+	MedianMs    int64          `json:"median_ms"`
+	SlowCases   []int          `json:"slow_cases"`
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  time.Time      `json:"finished_at"`
+}
+
+// String payloads that probe for path traversal and injection flaws
+var (
+	fuzzTraversalPayloads = []string{
+		"../../../../../../etc/passwd",
+		"..\\..\\..\\..\\..\\..\\windows\\win.ini",
+		"/etc/passwd",
+		"file:///etc/passwd",
+		"....//....//....//etc/passwd",
+		"%2e%2e%2f%2e%2e%2f%2e%2e%2fetc%2fpasswd",
+	}
+	fuzzInjectionPayloads = []string{
+		"; id",
+		"$(id)",
+		"`id`",
+		"| cat /etc/passwd",
+		"' OR '1'='1",
+		"\" OR \"1\"=\"1",
+		"1; DROP TABLE users--",
+		"<script>alert(1)</script>",
+		"{{7*7}}",
+		"${7*7}",
+		"${jndi:ldap://127.0.0.1/a}",
+		"%s%s%s%s%n",
+	}
+)
+
+// interestingPatterns flag responses that leak internals or file contents
+var interestingPatterns = map[string]*regexp.Regexp{
+	"stack-trace":     regexp.MustCompile(`(?im)traceback \(most recent call last\)|goroutine \d+ \[|^\s+at [\w.$]+\(|panic:|stack trace`),
+	"sql-error":       regexp.MustCompile(`(?i)sql syntax|sqlite_error|psql:|ORA-\d{5}|unterminated quoted string`),
+	"passwd-leak":     regexp.MustCompile(`root:[x*]?:0:0:`),
+	"win-ini-leak":    regexp.MustCompile(`(?i)\[(fonts|extensions)\]`),
+	"command-output":  regexp.MustCompile(`uid=\d+\(\w+\)`),
+	"path-disclosure": regexp.MustCompile(`(?i)(/home/\w+|/usr/local/|/var/www|[a-z]:\\users\\)`),
+}
+
+// digitsPattern normalizes numbers when computing response signatures
+var digitsPattern = regexp.MustCompile(`\d+`)
+
+// generateFuzzCases derives test cases from a tool schema
+func generateFuzzCases(schema *ToolSchema) []fuzzCase {
+	baseline := baselineArguments(schema)
+
+	cases := []fuzzCase{
+		{Name: "baseline", Arguments: baseline},
+		{Name: "empty-arguments", Arguments: map[string]any{}},
+		{Name: "unknown-parameter", Arguments: withArgument(baseline, "mcpmap_unknown", "mcpmap")},
+	}
+
+	for _, name := range sortedKeys(schema.Parameters) {
+		param := schema.Parameters[name]
+
+		if param.Required {
+			args := withArgument(baseline, name, nil)
+			delete(args, name)
+			cases = append(cases, fuzzCase{Name: "missing-required", Param: name, Arguments: args})
+		}
+
+		for _, v := range fuzzValues(param) {
+			cases = append(cases, fuzzCase{
+				Name:      v.label,
+				Param:     name,
+				Arguments: withArgument(baseline, name, v.value),
+			})
+		}
+	}
+
+	return cases
+}
+
+// withArgument copies args and sets name to value
+func withArgument(args map[string]any, name string, value any) map[string]any {
+	out := make(map[string]any, len(args)+1)
+	for k, v := range args {
+		out[k] = v
+	}
+	out[name] = value
+	return out
+}
+
+// baselineArguments builds a plausible valid argument set for the schema
+func baselineArguments(schema *ToolSchema) map[string]any {
+	args := make(map[string]any, len(schema.Parameters))
+	for name, param := range schema.Parameters {
+		args[name] = sampleValue(param)
+	}
+	return args
+}
+
+// sampleValue returns a valid-looking value for a parameter
+func sampleValue(param *ParameterSchema) any {
+	if param == nil {
+		return "mcpmap"
+	}
+	if param.Default != nil {
+		return param.Default
+	}
+	if len(param.Enum) > 0 {
+		return param.Enum[0]
+	}
+
+	switch param.Type {
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	case "null":
+		return nil
+	case "array":
+		if param.Items != nil {
+			return []any{sampleValue(param.Items)}
+		}
+		return []any{}
+	case "object":
+		obj := make(map[string]any, len(param.Properties))
+		for name, prop := range param.Properties {
+			obj[name] = sampleValue(prop)
+		}
+		return obj
+	default:
+		switch param.Format {
+		case "uri", "url":
+			return "https://example.com"
+		case "email":
+			return "mcpmap@example.com"
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		}
+		return "mcpmap"
+	}
+}
+
+// fuzzValues returns the mutations to try for a single parameter
+func fuzzValues(param *ParameterSchema) []fuzzValue {
+	var values []fuzzValue
+
+	// Type confusion: send a value of every other JSON type
+	confusion := []struct {
+		typ   string
+		value any
+	}{
+		{"string", "mcpmap"},
+		{"integer", 1},
+		{"boolean", true},
+		{"null", nil},
+		{"array", []any{"mcpmap"}},
+		{"object", map[string]any{"mcpmap": "mcpmap"}},
+	}
+	for _, c := range confusion {
+		if c.typ == param.Type || (c.typ == "integer" && param.Type == "number") {
+			continue
+		}
+		values = append(values, fuzzValue{"type-confusion-" + c.typ, c.value})
+	}
+
+	if len(param.Enum) > 0 {
+		values = append(values, fuzzValue{"enum-violation", "mcpmap-not-in-enum"})
+	}
+
+	switch param.Type {
+	case "integer":
+		values = append(values,
+			fuzzValue{"int-zero", 0},
+			fuzzValue{"int-negative", -1},
+			fuzzValue{"int32-max", math.MaxInt32},
+			fuzzValue{"int32-overflow", int64(math.MaxInt32) + 1},
+			fuzzValue{"int64-max", int64(math.MaxInt64)},
+			fuzzValue{"int64-min", int64(math.MinInt64)},
+			fuzzValue{"int64-overflow", json.Number("9223372036854775808")},
+			fuzzValue{"int-fraction", 1.5},
+		)
+	case "number":
+		values = append(values,
+			fuzzValue{"number-zero", 0.0},
+			fuzzValue{"number-negative", -1.0},
+			fuzzValue{"number-max", math.MaxFloat64},
+			fuzzValue{"number-min", -math.MaxFloat64},
+			fuzzValue{"number-tiny", math.SmallestNonzeroFloat64},
+			fuzzValue{"number-overflow", json.Number("1e309")},
+		)
+	case "array":
+		huge := make([]any, fuzzHugeSize)
+		for i := range huge {
+			huge[i] = sampleValue(param.Items)
+		}
+		values = append(values,
+			fuzzValue{"array-empty", []any{}},
+			fuzzValue{"array-nulls", []any{nil, nil}},
+			fuzzValue{"array-huge", huge},
+		)
+	case "object":
+		values = append(values,
+			fuzzValue{"object-empty", map[string]any{}},
+			fuzzValue{"object-deep", deepObject(256)},
+			fuzzValue{"object-proto", map[string]any{"__proto__": map[string]any{"polluted": true}}},
+		)
+	case "string", "":
+		values = append(values,
+			fuzzValue{"string-empty", ""},
+			fuzzValue{"string-huge", strings.Repeat("A", fuzzHugeSize)},
+			fuzzValue{"string-null-byte", "mcpmap\x00.txt"},
+			fuzzValue{"string-unicode", "\u202e\ufeff\U0001F4A9\x00\uffff"},
+			fuzzValue{"string-newlines", "mcpmap\r\nX-Injected: 1"},
+		)
+		for i, p := range fuzzTraversalPayloads {
+			values = append(values, fuzzValue{fmt.Sprintf("path-traversal-%d", i+1), p})
+		}
+		for i, p := range fuzzInjectionPayloads {
+			values = append(values, fuzzValue{fmt.Sprintf("injection-%d", i+1), p})
+		}
+	}
+
+	return values
+}
+
+// deepObject returns an object nested depth levels deep
+func deepObject(depth int) map[string]any {
+	obj := map[string]any{"mcpmap": "leaf"}
+	for i := 0; i < depth; i++ {
+		obj = map[string]any{"nested": obj}
+	}
+	return obj
+}
+
+// classifyFuzzCall determines the outcome of a single tool call
+func classifyFuzzCall(res *mcp.CallToolResult, err error) (outcome, message string) {
+	switch {
+	case err == nil && res != nil && res.IsError:
+		return fuzzOutcomeError, contentText(res.Content)
+	case err == nil:
+		return fuzzOutcomeOK, ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return fuzzOutcomeTimeout, err.Error()
+	case errors.Is(err, mcp.ErrConnectionClosed):
+		return fuzzOutcomeCrash, err.Error()
+	default:
+		// JSON-RPC internal errors usually mean the handler blew up
+		if strings.Contains(err.Error(), "-32603") || strings.Contains(strings.ToLower(err.Error()), "internal error") {
+			return fuzzOutcomeCrash, err.Error()
+		}
+		return fuzzOutcomeError, err.Error()
+	}
+}
+
+// contentText concatenates the text content of a tool result
+func contentText(content []mcp.Content) string {
+	var parts []string
+	for _, c := range content {
+		if text, ok := c.(*mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// findInteresting returns the names of patterns matching the response text
+// of a case, sorted
+func findInteresting(fc fuzzCase, text string) []string {
+	var found []string
+	for name, re := range interestingPatterns {
+		if re.MatchString(text) {
+			found = append(found, name)
+		}
+	}
+
+	// Template payloads multiply 7*7; seeing the product but not the payload means it was evaluated
+	if payload, ok := fc.Arguments[fc.Param].(string); ok && strings.Contains(payload, "7*7") {
+		if strings.Contains(text, "49") && !strings.Contains(text, "7*7") {
+			found = append(found, "template-eval")
+		}
+	}
+
+	sort.Strings(found)
+	return found
+}
+
+// markSlowCases flags results that took far longer than the median call
+func markSlowCases(results []fuzzResult) (median int64, slow []int) {
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	durations := make([]int64, len(results))
+	for i, r := range results {
+		durations[i] = r.DurationMs
+	}
+	slices.Sort(durations)
+	median = durations[len(durations)/2]
+
+	threshold := max(median*5, 500)
+	for i := range results {
+		if results[i].Outcome != fuzzOutcomeTimeout && results[i].DurationMs > threshold {
+			results[i].Slow = true
+			slow = append(slow, results[i].Index)
+		}
+	}
+	return median, slow
+}
+
+// fuzzSignature identifies a response so duplicates are kept out of the corpus
+func fuzzSignature(r fuzzResult) string {
+	text := r.Error
+	if text == "" && r.Result != nil {
+		text = contentText(r.Result.Content)
+	}
+	// Numbers often carry addresses, lengths or line numbers that vary between calls
+	text = digitsPattern.ReplaceAllString(text, "N")
+	if len(text) > 256 {
+		text = text[:256]
+	}
+	h := sha256.Sum256([]byte(r.Outcome + "\x00" + strings.Join(r.Interesting, ",") + "\x00" + text))
+	return hex.EncodeToString(h[:])[:16]
+}
+
+// fuzzRunner sends cases to a tool, reconnecting if the server drops the session
+type fuzzRunner struct {
+	toolName string
+	session  *mcp.ClientSession
+}
+
+func (fr *fuzzRunner) call(ctx context.Context, args map[string]any) (*mcp.CallToolResult, error) {
+	if fr.session == nil {
+		session, err := createSession(ctx, transportType, serverURL, proxyURL, authToken, clientName)
+		if err != nil {
+			return nil, fmt.Errorf("reconnect: %w", mcp.ErrConnectionClosed)
+		}
+		fr.session = session
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, fuzzTimeout)
+	defer cancel()
+
+	res, err := fr.session.CallTool(callCtx, &mcp.CallToolParams{Name: fr.toolName, Arguments: args})
+	if err != nil && errors.Is(err, mcp.ErrConnectionClosed) {
+		fr.session.Close()
+		fr.session = nil
+	}
+	return res, err
+}
+
+func (fr *fuzzRunner) Close() {
+	if fr.session != nil {
+		fr.session.Close()
+	}
+}
+
+// fuzzWriter stores each result as its case completes, so an interrupted or
+// crashed run keeps the results so far. Tool output is redacted like exec
+// output.
+type fuzzWriter struct {
+	results     *os.File
+	corpusDir   string
+	seen        map[string]bool
+	interesting int
+}
+
+// write appends r to results.jsonl and adds it to the corpus if it is notable
+func (w *fuzzWriter) write(r fuzzResult) error {
+	js, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("json marshal result: %w", err)
+	}
+	if _, err := w.results.Write(append(outputRedactor.JSON(js), '\n')); err != nil {
+		return fmt.Errorf("write result: %w", err)
+	}
+	if r.Outcome == fuzzOutcomeOK && len(r.Interesting) == 0 {
+		return nil
+	}
+	return w.saveCorpus(r)
+}
+
+// markSlow records that r turned out slow in results.jsonl and adds it to
+// the corpus unless it already is
+func (w *fuzzWriter) markSlow(r fuzzResult) error {
+	js, err := json.Marshal(fuzzSlowMark{Index: r.Index, Slow: true})
+	if err != nil {
+		return fmt.Errorf("json marshal result: %w", err)
+	}
+	if _, err := w.results.Write(append(js, '\n')); err != nil {
+		return fmt.Errorf("write result: %w", err)
+	}
+	if r.Outcome != fuzzOutcomeOK || len(r.Interesting) > 0 {
+		return nil
+	}
+	return w.saveCorpus(r)
+}
+
+// saveCorpus writes r to the corpus unless an equivalent response is there
+func (w *fuzzWriter) saveCorpus(r fuzzResult) error {
+	sig := fuzzSignature(r)
+	if w.seen[sig] {
+		return nil
+	}
+	w.seen[sig] = true
+	w.interesting++
+
+	js, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json marshal corpus entry: %w", err)
+	}
+	name := fmt.Sprintf("%04d-%s-%s.json", r.Index, r.Outcome, sig)
+	if err := os.WriteFile(filepath.Join(w.corpusDir, name), outputRedactor.JSON(js), 0644); err != nil {
+		return fmt.Errorf("write corpus entry: %w", err)
+	}
+	return nil
+}
+
+func runFuzz(cmd *cobra.Command, args []string) error {
+	// An interrupted run stops sending cases and still writes its summary
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	toolName := args[0]
+
+	session, err := createSession(ctx, transportType, serverURL, proxyURL, authToken, clientName)
+	if err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+	runner := &fuzzRunner{toolName: toolName, session: session}
+	defer runner.Close()
+
+	tool, err := getTool(ctx, session, toolName)
+	if err != nil {
+		return err
+	}
+	if err := defaultToolGate.Check(tool, toolName); err != nil {
+		return err
+	}
+	schema, err := toolSchema(tool)
+	if err != nil {
+		return err
+	}
+
+	cases := generateFuzzCases(schema)
+	if fuzzMaxCases > 0 && len(cases) > fuzzMaxCases {
+		cases = cases[:fuzzMaxCases]
+	}
+
+	corpusDir := filepath.Join(fuzzOutDir, "corpus")
+	if err := os.MkdirAll(corpusDir, 0755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+
+	resultsFile, err := os.Create(filepath.Join(fuzzOutDir, "results.jsonl"))
+	if err != nil {
+		return fmt.Errorf("create results file: %w", err)
+	}
+	defer resultsFile.Close()
+	out := &fuzzWriter{results: resultsFile, corpusDir: corpusDir, seen: make(map[string]bool)}
+
+	var tick <-chan time.Time
+	if fuzzRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / fuzzRate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	summary := fuzzSummary{
+		Tool:      toolName,
+		Server:    serverURL,
+		Cases:     len(cases),
+		Outcomes:  make(map[string]int),
+		StartedAt: time.Now(),
+	}
+	results := make([]fuzzResult, 0, len(cases))
+
+	fmt.Fprintf(os.Stderr, "Fuzzing %s with %d cases\n", toolName, len(cases))
+	for i, fc := range cases {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		start := time.Now()
+		res, err := runner.call(ctx, fc.Arguments)
+		if ctx.Err() != nil {
+			// The call was cut short by the interrupt, not by the tool
+			break
+		}
+		outcome, message := classifyFuzzCall(res, err)
+
+		result := fuzzResult{
+			Index:      i,
+			Case:       fc.Name,
+			Param:      fc.Param,
+			Arguments:  fc.Arguments,
+			Outcome:    outcome,
+			DurationMs: time.Since(start).Milliseconds(),
+			Result:     res,
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Interesting = findInteresting(fc, message+"\n"+contentText(resultContent(res)))
+		results = append(results, result)
+		if err := out.write(result); err != nil {
+			return err
+		}
+
+		summary.Outcomes[outcome]++
+		if outcome != fuzzOutcomeOK {
+			fmt.Fprintf(os.Stderr, "  [%d] %s %s: %s\n", i, fc.Name, fc.Param, outcome)
+		}
+	}
+
+	// Slowness is judged against the median, known once the run is over
+	summary.MedianMs, summary.SlowCases = markSlowCases(results)
+	for _, r := range results {
+		if r.Slow {
+			if err := out.markSlow(r); err != nil {
+				return err
+			}
+		}
+	}
+	summary.Completed = len(results)
+	summary.Interrupted = ctx.Err() != nil
+	summary.Interesting = out.interesting
+	summary.FinishedAt = time.Now()
+
+	js, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json marshal summary: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(fuzzOutDir, "summary.json"), js, 0644); err != nil {
+		return fmt.Errorf("write summary: %w", err)
+	}
+
+	if summary.Interrupted {
+		fmt.Printf("Interrupted after %d of %d cases\n", summary.Completed, summary.Cases)
+	}
+	fmt.Printf("Cases: %d\n", summary.Completed)
+	for _, outcome := range []string{fuzzOutcomeOK, fuzzOutcomeError, fuzzOutcomeTimeout, fuzzOutcomeCrash} {
+		fmt.Printf("  %s: %d\n", outcome, summary.Outcomes[outcome])
+	}
+	fmt.Printf("Slow cases: %d (median %dms)\n", len(summary.SlowCases), summary.MedianMs)
+	fmt.Printf("Interesting responses: %d\n", summary.Interesting)
+	fmt.Printf("Results written to %s\n", fuzzOutDir)
+
+	return nil
+}
+
+// resultContent returns the content of res, tolerating a nil result
+func resultContent(res *mcp.CallToolResult) []mcp.Content {
+	if res == nil {
+		return nil
+	}
+	return res.Content
+}
@@ -0,0 +1,561 @@
+// gateway.go - Aggregate several MCP servers behind one endpoint
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gatewayConfigFile string
+	gatewayListen     string
+)
+
+var gatewayCmd = &cobra.Command{
+	Use:   "gateway",
+	Short: "Serve several MCP servers as a single namespaced MCP server",
+	Long: `Serve several MCP servers as a single MCP server.
+
+mcpmap connects to every upstream in the config file and serves the union of
+their tools, resources and prompts. Tool and prompt names are prefixed per
+upstream (by default "<name>__") so they cannot collide, and calls are routed
+to the upstream that provides them. No prefix may start with another one, so
+an empty prefix is only allowed with a single upstream. Resources keep their
+URIs; if two upstreams expose the same URI the first one wins. When an
+upstream announces that a list changed, connected clients are told to fetch
+it again.
+
+Each upstream may restrict its tools with "allow" and "deny" glob patterns
+matched against the upstream's own tool names. A tool is served if it matches
+an allow pattern (or there are none) and no deny pattern.
+
+Example config:
+  servers:
+    - name: github
+      http: https://mcp.example.com/github/mcp
+      token: ghp_...
+      prefix: gh_
+      deny: ["delete_*"]
+    - name: files
+      command: ["npx", "-y", "@modelcontextprotocol/server-filesystem", "/srv"]
+      allow: ["read_*", "list_*"]
+    - name: legacy
+      sse: http://localhost:3000/sse
+
+Examples:
+  mcpmap gateway --config servers.yaml --listen :8080`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{annotationTransportOptional: "true"},
+	RunE:        runGateway,
+}
+
+func init() {
+	rootCmd.AddCommand(gatewayCmd)
+	gatewayCmd.Flags().StringVarP(&gatewayConfigFile, "config", "c", "", "JSON or YAML file listing the upstream servers")
+	gatewayCmd.Flags().StringVarP(&gatewayListen, "listen", "l", "127.0.0.1:8080", "Address to accept MCP clients on")
+	gatewayCmd.Flags().StringVar(&policyFilePath, "policy", "", "Enforce the tool call policy in this JSON or YAML file")
+	gatewayCmd.MarkFlagRequired("config")
+}
+
+// gatewayConfig is the gateway config file
+type gatewayConfig struct {
+	Servers []*gatewayUpstreamConfig `json:"servers"`
+}
+
+// gatewayUpstreamConfig describes one upstream server
+type gatewayUpstreamConfig struct {
+	Name    string            `json:"name"`
+	SSE     string            `json:"sse,omitempty"`
+	HTTP    string            `json:"http,omitempty"`
+	Command []string          `json:"command,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Token   string            `json:"token,omitempty"`
+	Prefix  *string           `json:"prefix,omitempty"`
+	Allow   []string          `json:"allow,omitempty"`
+	Deny    []string          `json:"deny,omitempty"`
+}
+
+// loadGatewayConfig reads and validates a gateway config file
+func loadGatewayConfig(path string) (*gatewayConfig, error) {
+	var cfg gatewayConfig
+	if err := decodeStructuredFile(path, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *gatewayConfig) validate() error {
+	if len(c.Servers) == 0 {
+		return fmt.Errorf("no servers configured")
+	}
+
+	names := make(map[string]bool)
+	var prefixed []*gatewayUpstreamConfig
+	for i, s := range c.Servers {
+		if s.Name == "" {
+			return fmt.Errorf("server %d: missing name", i+1)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("server %q: duplicate name", s.Name)
+		}
+		names[s.Name] = true
+
+		kinds := 0
+		for _, set := range []bool{s.SSE != "", s.HTTP != "", len(s.Command) > 0} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			return fmt.Errorf("server %q: specify exactly one of sse, http or command", s.Name)
+		}
+
+		// Overlapping prefixes would route one upstream's names to another
+		for _, other := range prefixed {
+			switch p, q := s.prefix(), other.prefix(); {
+			case p == q:
+				return fmt.Errorf("server %q: prefix %q already used by %q", s.Name, p, other.Name)
+			case strings.HasPrefix(p, q) || strings.HasPrefix(q, p):
+				return fmt.Errorf("server %q: prefix %q overlaps prefix %q of %q", s.Name, p, q, other.Name)
+			}
+		}
+		prefixed = append(prefixed, s)
+
+		for _, pattern := range append(append([]string{}, s.Allow...), s.Deny...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("server %q: invalid pattern %q", s.Name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// prefix returns the namespace prefix of the upstream's tools and prompts
+func (s *gatewayUpstreamConfig) prefix() string {
+	if s.Prefix != nil {
+		return *s.Prefix
+	}
+	return s.Name + "__"
+}
+
+// allowed applies the allow and deny lists to a tool name
+func (s *gatewayUpstreamConfig) allowed(name string) bool {
+	matchAny := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(s.Allow) > 0 && !matchAny(s.Allow) {
+		return false
+	}
+	return !matchAny(s.Deny)
+}
+
+// transport creates the client transport for the upstream
+func (s *gatewayUpstreamConfig) transport() (mcp.Transport, error) {
+	if len(s.Command) > 0 {
+		return newCommandTransport(s.Command, s.Env), nil
+	}
+
+	httpClient, err := createHTTPClient(proxyURL, s.Token)
+	if err != nil {
+		return nil, err
+	}
+	if s.SSE != "" {
+		return newClientTransport("sse", s.SSE, httpClient)
+	}
+	return newClientTransport("http", s.HTTP, httpClient)
+}
+
+// cache returns the cache entry of the upstream, keyed like a direct connection
+func (s *gatewayUpstreamConfig) cache() cache.Cache {
+	switch {
+	case len(s.Command) > 0:
+		return openCacheWithToken(strings.Join(s.Command, " "), "stdio", s.Token)
+	case s.SSE != "":
+		return openCacheWithToken(s.SSE, "sse", s.Token)
+	default:
+		return openCacheWithToken(s.HTTP, "http", s.Token)
+	}
+}
+
+// gatewayUpstream is a connected upstream server
+type gatewayUpstream struct {
+	config  *gatewayUpstreamConfig
+	session *mcp.ClientSession
+	// watcher updates the upstream's cache entry on list_changed notifications
+	watcher *listWatcher
+	// listChanged, if set, passes the upstream's list_changed notifications on
+	listChanged func(kind string)
+
+	// Capabilities advertised by the upstream
+	tools, resources, prompts bool
+}
+
+// connect opens the upstream session and records its capabilities
+func (u *gatewayUpstream) connect(ctx context.Context) error {
+	transport, err := u.config.transport()
+	if err != nil {
+		return err
+	}
+
+	client := mcp.NewClient(&mcp.Implementation{Name: clientName, Version: "v1.0.0"}, u.clientOptions())
+	client.AddSendingMiddleware(func(next mcp.MethodHandler[*mcp.ClientSession]) mcp.MethodHandler[*mcp.ClientSession] {
+		return func(ctx context.Context, cs *mcp.ClientSession, method string, params mcp.Params) (mcp.Result, error) {
+			res, err := next(ctx, cs, method, params)
+			if init, ok := res.(*mcp.InitializeResult); ok && init.Capabilities != nil {
+				u.tools = init.Capabilities.Tools != nil
+				u.resources = init.Capabilities.Resources != nil
+				u.prompts = init.Capabilities.Prompts != nil
+			}
+			return res, err
+		}
+	})
+
+	// The SSE stream is bound to the connect context, so it must not be
+	// cancelled once startup is done
+	u.session, err = client.Connect(context.WithoutCancel(ctx), transport)
+	return err
+}
+
+// clientOptions feeds the upstream's list_changed notifications to its
+// watcher and to listChanged
+func (u *gatewayUpstream) clientOptions() *mcp.ClientOptions {
+	changed := func(cs *mcp.ClientSession, kind string) {
+		u.watcher.changed(cs, kind)
+		if u.listChanged != nil {
+			// Notification handlers must not block
+			go u.listChanged(kind)
+		}
+	}
+	return &mcp.ClientOptions{
+		ToolListChangedHandler: func(ctx context.Context, cs *mcp.ClientSession, _ *mcp.ToolListChangedParams) {
+			changed(cs, "tools")
+		},
+		ResourceListChangedHandler: func(ctx context.Context, cs *mcp.ClientSession, _ *mcp.ResourceListChangedParams) {
+			changed(cs, "resources")
+		},
+		PromptListChangedHandler: func(ctx context.Context, cs *mcp.ClientSession, _ *mcp.PromptListChangedParams) {
+			changed(cs, "prompts")
+		},
+	}
+}
+
+// gatewayPlaceholder names the item re-added to the downstream server to make
+// it send a list_changed notification, see notifyListChanged
+const gatewayPlaceholder = "mcpmap-gateway-list-changed"
+
+// gateway routes requests from downstream sessions to the upstreams
+type gateway struct {
+	upstreams []*gatewayUpstream
+	// server serves every downstream session
+	server *mcp.Server
+	// policy, if set, is enforced on every tool call
+	policy *policyEngine
+
+	mu             sync.Mutex
+	resourceRoutes map[string]*gatewayUpstream
+}
+
+// newGateway connects to every configured upstream. Upstreams that cannot be
+// reached are skipped with a warning; it fails only if none can be reached.
+func newGateway(ctx context.Context, cfg *gatewayConfig) (*gateway, error) {
+	g := &gateway{resourceRoutes: make(map[string]*gatewayUpstream)}
+	g.server = mcp.NewServer(&mcp.Implementation{Name: "mcpmap-gateway", Version: "v1.0.0"}, nil)
+	g.server.AddReceivingMiddleware(g.middleware)
+
+	for _, s := range cfg.Servers {
+		u := &gatewayUpstream{config: s, listChanged: g.notifyListChanged}
+		u.watcher = newListWatcher(fmt.Sprintf("Upstream %q", s.Name), s.cache(), os.Stderr)
+		if err := u.connect(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping upstream %q: %v\n", s.Name, err)
+			continue
+		}
+		g.upstreams = append(g.upstreams, u)
+	}
+
+	if len(g.upstreams) == 0 {
+		return nil, fmt.Errorf("could not connect to any upstream")
+	}
+	return g, nil
+}
+
+// Close closes every upstream session once its pending cache updates are saved
+func (g *gateway) Close() {
+	for _, u := range g.upstreams {
+		u.watcher.flush()
+		u.session.Close()
+	}
+}
+
+// route finds the upstream serving a namespaced tool or prompt name and
+// returns the upstream's own name for it. Prefixes do not overlap, so at most
+// one upstream matches.
+func (g *gateway) route(name string) (*gatewayUpstream, string, bool) {
+	for _, u := range g.upstreams {
+		if prefix := u.config.prefix(); strings.HasPrefix(name, prefix) {
+			return u, name[len(prefix):], true
+		}
+	}
+	return nil, "", false
+}
+
+// Server returns the MCP server for downstream sessions
+func (g *gateway) Server() *mcp.Server {
+	return g.server
+}
+
+// notifyListChanged sends a list_changed notification for kind to every
+// downstream session. The SDK only sends them when the server's own items
+// change, so a placeholder is re-added; it is never served, as the
+// middleware answers every list and call itself.
+func (g *gateway) notifyListChanged(kind string) {
+	switch kind {
+	case "tools":
+		g.server.AddTool(&mcp.Tool{Name: gatewayPlaceholder, InputSchema: &jsonschema.Schema{Type: "object"}}, nil)
+	case "resources":
+		g.server.AddResourceTemplate(&mcp.ResourceTemplate{Name: gatewayPlaceholder, URITemplate: "mcpmap:///" + gatewayPlaceholder}, nil)
+	case "prompts":
+		g.server.AddPrompt(&mcp.Prompt{Name: gatewayPlaceholder}, nil)
+	}
+}
+
+func (g *gateway) middleware(next mcp.MethodHandler[*mcp.ServerSession]) mcp.MethodHandler[*mcp.ServerSession] {
+	return func(ctx context.Context, ss *mcp.ServerSession, method string, params mcp.Params) (mcp.Result, error) {
+		switch method {
+		case "initialize":
+			res, err := next(ctx, ss, method, params)
+			if err != nil {
+				return nil, err
+			}
+			return withCapabilities(res, g.capabilities())
+		case "tools/list":
+			return g.listTools(ctx), nil
+		case "tools/call":
+			p, err := decodeParams[mcp.CallToolParams](params)
+			if err != nil {
+				return nil, err
+			}
+			return g.callTool(ctx, p)
+		case "prompts/list":
+			return g.listPrompts(ctx), nil
+		case "prompts/get":
+			p, err := decodeParams[mcp.GetPromptParams](params)
+			if err != nil {
+				return nil, err
+			}
+			return g.getPrompt(ctx, p)
+		case "resources/list":
+			return g.listResources(ctx), nil
+		case "resources/templates/list":
+			return g.listResourceTemplates(ctx), nil
+		case "resources/read":
+			p, err := decodeParams[mcp.ReadResourceParams](params)
+			if err != nil {
+				return nil, err
+			}
+			return g.readResource(ctx, p)
+		}
+		return next(ctx, ss, method, params)
+	}
+}
+
+// capabilities advertises what at least one upstream offers. List changes
+// of the upstreams are passed on, see notifyListChanged.
+func (g *gateway) capabilities() map[string]any {
+	caps := make(map[string]any)
+	for _, u := range g.upstreams {
+		if u.tools {
+			caps["tools"] = map[string]any{"listChanged": true}
+		}
+		if u.resources {
+			caps["resources"] = map[string]any{"listChanged": true}
+		}
+		if u.prompts {
+			caps["prompts"] = map[string]any{"listChanged": true}
+		}
+	}
+	return caps
+}
+
+func (g *gateway) listTools(ctx context.Context) *mcp.ListToolsResult {
+	res := &mcp.ListToolsResult{Tools: []*mcp.Tool{}}
+	for _, u := range g.upstreams {
+		if !u.tools {
+			continue
+		}
+		for tool, err := range u.session.Tools(ctx, nil) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: list tools of %q: %v\n", u.config.Name, err)
+				break
+			}
+			if !u.config.allowed(tool.Name) {
+				continue
+			}
+			namespaced := *tool
+			namespaced.Name = u.config.prefix() + tool.Name
+			res.Tools = append(res.Tools, &namespaced)
+		}
+	}
+	return res
+}
+
+func (g *gateway) callTool(ctx context.Context, params *mcp.CallToolParams) (mcp.Result, error) {
+	u, name, ok := g.route(params.Name)
+	if !ok || !u.config.allowed(name) {
+		return nil, jsonRPCError(codeInvalidParams, fmt.Sprintf("unknown tool %q", params.Name))
+	}
+
+	if g.policy != nil {
+		args, _ := jsonValue(params.Arguments).(map[string]any)
+		err := g.policy.Check(ctx, params.Name, args, func(ctx context.Context) (*mcp.Tool, error) {
+			return getTool(ctx, u.session, name)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	forwarded := *params
+	forwarded.Name = name
+	return u.session.CallTool(ctx, &forwarded)
+}
+
+func (g *gateway) listPrompts(ctx context.Context) *mcp.ListPromptsResult {
+	res := &mcp.ListPromptsResult{Prompts: []*mcp.Prompt{}}
+	for _, u := range g.upstreams {
+		if !u.prompts {
+			continue
+		}
+		for prompt, err := range u.session.Prompts(ctx, nil) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: list prompts of %q: %v\n", u.config.Name, err)
+				break
+			}
+			namespaced := *prompt
+			namespaced.Name = u.config.prefix() + prompt.Name
+			res.Prompts = append(res.Prompts, &namespaced)
+		}
+	}
+	return res
+}
+
+func (g *gateway) getPrompt(ctx context.Context, params *mcp.GetPromptParams) (mcp.Result, error) {
+	u, name, ok := g.route(params.Name)
+	if !ok {
+		return nil, jsonRPCError(codeInvalidParams, fmt.Sprintf("unknown prompt %q", params.Name))
+	}
+
+	forwarded := *params
+	forwarded.Name = name
+	return u.session.GetPrompt(ctx, &forwarded)
+}
+
+func (g *gateway) listResources(ctx context.Context) *mcp.ListResourcesResult {
+	res := &mcp.ListResourcesResult{Resources: []*mcp.Resource{}}
+	routes := make(map[string]*gatewayUpstream)
+	for _, u := range g.upstreams {
+		if !u.resources {
+			continue
+		}
+		for resource, err := range u.session.Resources(ctx, nil) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: list resources of %q: %v\n", u.config.Name, err)
+				break
+			}
+			if _, dup := routes[resource.URI]; dup {
+				continue
+			}
+			routes[resource.URI] = u
+			res.Resources = append(res.Resources, resource)
+		}
+	}
+
+	g.mu.Lock()
+	g.resourceRoutes = routes
+	g.mu.Unlock()
+
+	return res
+}
+
+func (g *gateway) listResourceTemplates(ctx context.Context) *mcp.ListResourceTemplatesResult {
+	res := &mcp.ListResourceTemplatesResult{ResourceTemplates: []*mcp.ResourceTemplate{}}
+	for _, u := range g.upstreams {
+		if !u.resources {
+			continue
+		}
+		for template, err := range u.session.ResourceTemplates(ctx, nil) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: list resource templates of %q: %v\n", u.config.Name, err)
+				break
+			}
+			res.ResourceTemplates = append(res.ResourceTemplates, template)
+		}
+	}
+	return res
+}
+
+// readResource reads from the upstream that listed the URI, or else tries
+// each upstream in turn, which covers URIs from resource templates
+func (g *gateway) readResource(ctx context.Context, params *mcp.ReadResourceParams) (mcp.Result, error) {
+	g.mu.Lock()
+	u, ok := g.resourceRoutes[params.URI]
+	g.mu.Unlock()
+	if ok {
+		return u.session.ReadResource(ctx, params)
+	}
+
+	for _, u := range g.upstreams {
+		if !u.resources {
+			continue
+		}
+		if res, err := u.session.ReadResource(ctx, params); err == nil {
+			return res, nil
+		}
+	}
+	return nil, mcp.ResourceNotFoundError(params.URI)
+}
+
+func runGateway(cmd *cobra.Command, args []string) error {
+	cfg, err := loadGatewayConfig(gatewayConfigFile)
+	if err != nil {
+		return err
+	}
+
+	var policy *policyEngine
+	if policyFilePath != "" {
+		if policy, err = loadPolicy(policyFilePath); err != nil {
+			return err
+		}
+	}
+
+	g, err := newGateway(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	g.policy = policy
+
+	for _, u := range g.upstreams {
+		fmt.Fprintf(os.Stderr, "Upstream %q connected (prefix %q)\n", u.config.Name, u.config.prefix())
+	}
+
+	handler := newMCPHandler(func(*http.Request) *mcp.Server { return g.Server() })
+	return serveMCP(gatewayListen, "Gateway", handler)
+}
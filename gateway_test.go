@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func strPtr(s string) *string { return &s }
+
+// newTestGateway connects a client to a gateway in front of an HTTP upstream
+// with an echo tool and an SSE mock upstream with several tools
+func newTestGateway(t *testing.T) *mcp.ClientSession {
+	t.Helper()
+
+	echo := newTestMCPServer(t, nil)
+	mock := newMockServer(&cache.CacheData{
+		Tools: []*mcp.Tool{{Name: "echo"}, {Name: "read_file"}, {Name: "delete_all"}},
+		Resources: []*mcp.Resource{{URI: "file:///notes.txt", Name: "notes"}},
+		Prompts:   []*mcp.Prompt{{Name: "greet"}},
+	}, &mockFixtureSet{Tools: map[string][]mockResponse{"echo": {{Text: "mock echo"}}}})
+	mockServer := httptest.NewServer(newMCPHandler(func(*http.Request) *mcp.Server { return mock.Server() }))
+	t.Cleanup(mockServer.Close)
+
+	cfg := &gatewayConfig{Servers: []*gatewayUpstreamConfig{
+		{Name: "one", HTTP: echo.URL},
+		{Name: "two", SSE: mockServer.URL + "/sse", Prefix: strPtr("t_"), Deny: []string{"delete_*"}},
+	}}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	g, err := newGateway(ctx, cfg)
+	if err != nil {
+		t.Fatalf("newGateway: %v", err)
+	}
+	t.Cleanup(g.Close)
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := g.Server().Connect(context.Background(), serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "gateway-test"}, nil)
+	session, err := client.Connect(context.Background(), clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func TestGatewayTools(t *testing.T) {
+	session := newTestGateway(t)
+	ctx := context.Background()
+
+	tools, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, ","); got != "one__echo,t_echo,t_read_file" {
+		t.Errorf("unexpected tools %s", got)
+	}
+
+	tests := []struct {
+		name    string
+		tool    string
+		want    string
+		wantErr bool
+	}{
+		{"routes to http upstream", "one__echo", "hello", false},
+		{"routes to sse upstream", "t_echo", "mock echo", false},
+		{"denied tool", "t_delete_all", "", true},
+		{"unknown prefix", "three__echo", "", true},
+		{"unprefixed name", "echo", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: tt.tool, Arguments: map[string]any{"message": "hello"}})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "unknown tool") {
+					t.Errorf("expected unknown tool error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CallTool: %v", err)
+			}
+			if got := contentText(res.Content); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGatewayResourcesAndPrompts(t *testing.T) {
+	session := newTestGateway(t)
+	ctx := context.Background()
+
+	resources, err := session.ListResources(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListResources: %v", err)
+	}
+	if len(resources.Resources) != 1 || resources.Resources[0].URI != "file:///notes.txt" {
+		t.Fatalf("unexpected resources %v", resources.Resources)
+	}
+	read, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "file:///notes.txt"})
+	if err != nil {
+		t.Fatalf("ReadResource: %v", err)
+	}
+	if read.Contents[0].Text != "mock contents of file:///notes.txt" {
+		t.Errorf("unexpected resource contents %q", read.Contents[0].Text)
+	}
+
+	prompts, err := session.ListPrompts(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListPrompts: %v", err)
+	}
+	if len(prompts.Prompts) != 1 || prompts.Prompts[0].Name != "t_greet" {
+		t.Fatalf("unexpected prompts %v", prompts.Prompts)
+	}
+	if _, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "t_greet"}); err != nil {
+		t.Errorf("GetPrompt: %v", err)
+	}
+}
+
+func TestGatewayConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []*gatewayUpstreamConfig
+		wantErr string
+	}{
+		{"valid", []*gatewayUpstreamConfig{{Name: "a", HTTP: "http://a"}, {Name: "b", Command: []string{"b"}}}, ""},
+		{"empty", nil, "no servers"},
+		{"missing name", []*gatewayUpstreamConfig{{HTTP: "http://a"}}, "missing name"},
+		{"duplicate name", []*gatewayUpstreamConfig{{Name: "a", HTTP: "http://a"}, {Name: "a", SSE: "http://b"}}, "duplicate name"},
+		{"no transport", []*gatewayUpstreamConfig{{Name: "a"}}, "exactly one"},
+		{"two transports", []*gatewayUpstreamConfig{{Name: "a", HTTP: "http://a", SSE: "http://a"}}, "exactly one"},
+		{"duplicate prefix", []*gatewayUpstreamConfig{{Name: "a", HTTP: "http://a", Prefix: strPtr("x_")}, {Name: "b", HTTP: "http://b", Prefix: strPtr("x_")}}, "already used"},
+		{"prefix of another", []*gatewayUpstreamConfig{{Name: "a", HTTP: "http://a", Prefix: strPtr("gh_")}, {Name: "b", HTTP: "http://b", Prefix: strPtr("gh_admin_")}}, "overlaps"},
+		{"empty prefix with others", []*gatewayUpstreamConfig{{Name: "a", HTTP: "http://a"}, {Name: "b", HTTP: "http://b", Prefix: strPtr("")}}, "overlaps"},
+		{"empty prefix alone", []*gatewayUpstreamConfig{{Name: "a", HTTP: "http://a", Prefix: strPtr("")}}, ""},
+		{"bad pattern", []*gatewayUpstreamConfig{{Name: "a", HTTP: "http://a", Allow: []string{"["}}}, "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&gatewayConfig{Servers: tt.servers}).validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGatewayAllowed(t *testing.T) {
+	s := &gatewayUpstreamConfig{Allow: []string{"read_*", "list_*"}, Deny: []string{"read_secret*"}}
+
+	for name, want := range map[string]bool{
+		"read_file":    true,
+		"list_dir":     true,
+		"write_file":   false,
+		"read_secrets": false,
+	} {
+		if got := s.allowed(name); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestGatewayForwardsListChanged(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	upstream := mcp.NewServer(&mcp.Implementation{Name: "changing"}, nil)
+	addNoopTool(upstream, "first")
+	upstreamServer := httptest.NewServer(newMCPHandler(func(*http.Request) *mcp.Server { return upstream }))
+	defer upstreamServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	g, err := newGateway(ctx, &gatewayConfig{Servers: []*gatewayUpstreamConfig{{Name: "one", SSE: upstreamServer.URL + "/sse"}}})
+	if err != nil {
+		t.Fatalf("newGateway: %v", err)
+	}
+	defer g.Close()
+
+	notified := make(chan struct{}, 1)
+	client := mcp.NewClient(&mcp.Implementation{Name: "gateway-test"}, &mcp.ClientOptions{
+		ToolListChangedHandler: func(context.Context, *mcp.ClientSession, *mcp.ToolListChangedParams) {
+			notified <- struct{}{}
+		},
+	})
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := g.Server().Connect(context.Background(), serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	session, err := client.Connect(context.Background(), clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer session.Close()
+
+	addNoopTool(upstream, "second")
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tools/list_changed was not forwarded")
+	}
+
+	res, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	var names []string
+	for _, tool := range res.Tools {
+		names = append(names, tool.Name)
+	}
+	if got := strings.Join(names, ","); got != "one__first,one__second" {
+		t.Errorf("tools = %s, want one__first,one__second", got)
+	}
+}
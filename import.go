@@ -0,0 +1,367 @@
+// import.go - Import MCP servers configured in desktop apps and editors
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	importDetect bool
+	importForce  bool
+	importDryRun bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [config.json...]",
+	Short: "Import servers from Claude Desktop, VS Code or Cursor configs as profiles",
+	Long: `Import MCP servers defined in other clients' config files as mcpmap profiles.
+
+Claude Desktop and Cursor configs list servers under "mcpServers"; VS Code uses
+"servers" in mcp.json or "mcp.servers" in settings.json. Entries with a
+command become stdio profiles, entries with a url become SSE or streamable
+HTTP profiles. Comments and trailing commas are allowed, as in VS Code.
+
+With --detect, the default config locations of these clients and the
+.vscode and .cursor directories of the current directory are searched.
+Existing profiles are kept unless --force is given.
+
+Examples:
+  mcpmap import --detect
+  mcpmap import ~/.cursor/mcp.json --dry-run
+  mcpmap import .vscode/mcp.json --force`,
+	PersistentPreRunE: skipServerSetup,
+	RunE:              runImport,
+}
+
+func init() {
+	importCmd.Flags().BoolVar(&importDetect, "detect", false, "Search the default config locations of known clients")
+	importCmd.Flags().BoolVar(&importForce, "force", false, "Replace existing profiles with the same name")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Show what would be imported without saving")
+	rootCmd.AddCommand(importCmd)
+}
+
+// clientConfigFile is a config file of another MCP client
+type clientConfigFile struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+}
+
+// configuredServer is a server found in a client config file
+type configuredServer struct {
+	Source  string         `json:"source"`
+	Path    string         `json:"path"`
+	Name    string         `json:"name"`
+	Profile *serverProfile `json:"profile"`
+}
+
+// clientServerEntry is one server in the mcpServers/servers block of a client
+type clientServerEntry struct {
+	Type     string            `json:"type"`
+	Command  string            `json:"command"`
+	Args     []string          `json:"args"`
+	Env      map[string]string `json:"env"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	Disabled bool              `json:"disabled"`
+}
+
+// profile converts the entry into an mcpmap profile
+func (e *clientServerEntry) profile() (*serverProfile, error) {
+	p := &serverProfile{Headers: e.Headers}
+	switch {
+	case e.Command != "":
+		p.Transport = "stdio"
+		p.Command = append([]string{e.Command}, e.Args...)
+		p.Env = e.Env
+	case e.URL != "":
+		p.URL = e.URL
+		switch strings.ToLower(e.Type) {
+		case "sse":
+			p.Transport = "sse"
+		case "http", "streamable-http", "streamablehttp":
+			p.Transport = "http"
+		default:
+			// Clients that omit the type pick SSE for /sse endpoints
+			p.Transport = "http"
+			if strings.HasSuffix(strings.TrimRight(e.URL, "/"), "/sse") {
+				p.Transport = "sse"
+			}
+		}
+	default:
+		return nil, fmt.Errorf("neither command nor url is set")
+	}
+	return p, p.validate()
+}
+
+// knownClientConfigs lists the config files of supported clients, whether or
+// not they exist
+func knownClientConfigs() []clientConfigFile {
+	var files []clientConfigFile
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files,
+			clientConfigFile{"claude-desktop", filepath.Join(dir, "Claude", "claude_desktop_config.json")},
+			clientConfigFile{"vscode", filepath.Join(dir, "Code", "User", "mcp.json")},
+			clientConfigFile{"vscode", filepath.Join(dir, "Code", "User", "settings.json")},
+		)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, clientConfigFile{"cursor", filepath.Join(home, ".cursor", "mcp.json")})
+	}
+	if wd, err := os.Getwd(); err == nil {
+		files = append(files,
+			clientConfigFile{"vscode", filepath.Join(wd, ".vscode", "mcp.json")},
+			clientConfigFile{"cursor", filepath.Join(wd, ".cursor", "mcp.json")},
+		)
+	}
+	return files
+}
+
+// detectClientConfigs returns the known client config files that exist
+func detectClientConfigs() []clientConfigFile {
+	var found []clientConfigFile
+	for _, f := range knownClientConfigs() {
+		if info, err := os.Stat(f.Path); err == nil && info.Mode().IsRegular() {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// sourceForPath guesses which client a config file given by path belongs to
+func sourceForPath(path string) string {
+	p := filepath.ToSlash(path)
+	switch {
+	case strings.Contains(p, "claude_desktop_config"):
+		return "claude-desktop"
+	case strings.Contains(p, ".cursor/"):
+		return "cursor"
+	case strings.Contains(p, ".vscode/") || strings.Contains(p, "/Code/"):
+		return "vscode"
+	default:
+		return "file"
+	}
+}
+
+// parseClientConfig returns the servers defined in a client config file.
+// Disabled servers and entries that cannot be converted are skipped with a
+// warning.
+func parseClientConfig(f clientConfigFile) ([]configuredServer, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", f.Path, err)
+	}
+
+	var doc struct {
+		MCPServers map[string]*clientServerEntry `json:"mcpServers"`
+		Servers    map[string]*clientServerEntry `json:"servers"`
+		MCP        struct {
+			Servers map[string]*clientServerEntry `json:"servers"`
+		} `json:"mcp"`
+	}
+	if err := json.Unmarshal(stripJSONC(data), &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", f.Path, err)
+	}
+
+	entries := make(map[string]*clientServerEntry)
+	for _, block := range []map[string]*clientServerEntry{doc.MCP.Servers, doc.Servers, doc.MCPServers} {
+		for name, e := range block {
+			entries[name] = e
+		}
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var servers []configuredServer
+	for _, name := range names {
+		e := entries[name]
+		if e == nil || e.Disabled {
+			continue
+		}
+		p, err := e.profile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping server %q in %s: %v\n", name, f.Path, err)
+			continue
+		}
+		servers = append(servers, configuredServer{Source: f.Source, Path: f.Path, Name: name, Profile: p})
+	}
+	return servers, nil
+}
+
+// stripJSONC removes comments and trailing commas so that VS Code style
+// JSON with comments can be decoded by encoding/json
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			// Drop a trailing comma before the closing bracket
+			j := len(out) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(out[j])) {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// configuredServers parses every file, warning about the ones that fail
+func configuredServers(files []clientConfigFile) []configuredServer {
+	var servers []configuredServer
+	for _, f := range files {
+		found, err := parseClientConfig(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		servers = append(servers, found...)
+	}
+	return servers
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	var files []clientConfigFile
+	for _, path := range args {
+		files = append(files, clientConfigFile{Source: sourceForPath(path), Path: path})
+	}
+	if importDetect {
+		files = append(files, detectClientConfigs()...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("specify config files to import or use --detect")
+	}
+
+	// Explicitly named files must be readable; detected ones are best-effort
+	for _, f := range files[:len(args)] {
+		if _, err := os.Stat(f.Path); err != nil {
+			return fmt.Errorf("read %s: %w", f.Path, err)
+		}
+	}
+
+	servers := configuredServers(files)
+	if len(servers) == 0 {
+		fmt.Println("No servers found")
+		return nil
+	}
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+
+	imported := 0
+	seen := make(map[string]bool)
+	for _, s := range servers {
+		if seen[s.Name] {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping %q from %s: already imported from another file\n", s.Name, s.Path)
+			continue
+		}
+		seen[s.Name] = true
+
+		if _, exists := cfg.Profiles[s.Name]; exists && !importForce {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping %q: profile already exists (use --force to replace it)\n", s.Name)
+			continue
+		}
+		cfg.Profiles[s.Name] = s.Profile
+		imported++
+
+		verb := "Imported"
+		if importDryRun {
+			verb = "Would import"
+		}
+		fmt.Printf("%s %q (%s %s) from %s\n", verb, s.Name, s.Profile.Transport, s.Profile.target(), s.Path)
+	}
+
+	if importDryRun || imported == 0 {
+		return nil
+	}
+	if err := cfg.save(); err != nil {
+		return err
+	}
+	fmt.Printf("%d profile(s) saved\n", imported)
+	return nil
+}
+
+// runListConfigured prints mcpmap profiles and every server configured in a
+// detected client config file
+func runListConfigured() error {
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+	path, _ := configFilePath()
+
+	var servers []configuredServer
+	for _, name := range cfg.profileNames() {
+		servers = append(servers, configuredServer{Source: "mcpmap", Path: path, Name: name, Profile: cfg.Profiles[name].masked()})
+	}
+	for _, s := range configuredServers(detectClientConfigs()) {
+		s.Profile = s.Profile.masked()
+		servers = append(servers, s)
+	}
+
+	if jsonOutput {
+		for _, s := range servers {
+			if js, err := json.Marshal(s); err == nil {
+				fmt.Fprintln(os.Stdout, string(js))
+			}
+		}
+		return nil
+	}
+
+	if len(servers) == 0 {
+		fmt.Println("No configured servers found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tNAME\tTRANSPORT\tTARGET")
+	for _, s := range servers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Source, s.Name, s.Profile.Transport, s.Profile.target())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  any
+	}{
+		{"plain", `{"a": 1}`, map[string]any{"a": 1.0}},
+		{"line comment", "{\n// comment\n\"a\": 1 // trailing\n}", map[string]any{"a": 1.0}},
+		{"block comment", `{/* x */"a": /* y */ 1}`, map[string]any{"a": 1.0}},
+		{"trailing commas", "{\"a\": [1, 2,],\n}", map[string]any{"a": []any{1.0, 2.0}}},
+		{"comment markers in strings", `{"url": "http://x/*y*/", "s": "a,]"}`, map[string]any{"url": "http://x/*y*/", "s": "a,]"}},
+		{"escaped quote", `{"s": "a\"//b"}`, map[string]any{"s": `a"//b`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got any
+			if err := json.Unmarshal(stripJSONC([]byte(tt.input)), &got); err != nil {
+				t.Fatalf("unmarshal: %v (stripped: %s)", err, stripJSONC([]byte(tt.input)))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClientConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string]serverProfile
+	}{
+		{
+			name: "claude desktop",
+			file: "claude_desktop_config.json",
+			content: `{"mcpServers": {
+				"fs": {"command": "npx", "args": ["-y", "@modelcontextprotocol/server-filesystem", "/tmp"]},
+				"gh": {"command": "github-mcp", "env": {"GITHUB_TOKEN": "x"}},
+				"off": {"command": "x", "disabled": true}
+			}}`,
+			want: map[string]serverProfile{
+				"fs": {Transport: "stdio", Command: []string{"npx", "-y", "@modelcontextprotocol/server-filesystem", "/tmp"}},
+				"gh": {Transport: "stdio", Command: []string{"github-mcp"}, Env: map[string]string{"GITHUB_TOKEN": "x"}},
+			},
+		},
+		{
+			name: "cursor urls",
+			file: "mcp.json",
+			content: `{"mcpServers": {
+				"events": {"url": "https://mcp.example.com/sse"},
+				"api": {"url": "https://mcp.example.com/mcp", "headers": {"Authorization": "Bearer t"}},
+				"broken": {"args": ["x"]}
+			}}`,
+			want: map[string]serverProfile{
+				"events": {Transport: "sse", URL: "https://mcp.example.com/sse"},
+				"api":    {Transport: "http", URL: "https://mcp.example.com/mcp", Headers: map[string]string{"Authorization": "Bearer t"}},
+			},
+		},
+		{
+			name: "vscode mcp.json",
+			file: "mcp.json",
+			content: `{
+				// Workspace servers
+				"servers": {
+					"remote": {"type": "sse", "url": "https://x/events"},
+					"local": {"type": "stdio", "command": "node", "args": ["server.js"]},
+				},
+			}`,
+			want: map[string]serverProfile{
+				"remote": {Transport: "sse", URL: "https://x/events"},
+				"local":  {Transport: "stdio", Command: []string{"node", "server.js"}},
+			},
+		},
+		{
+			name:    "vscode settings.json",
+			file:    "settings.json",
+			content: `{"editor.tabSize": 2, "mcp": {"servers": {"remote": {"type": "http", "url": "https://x/api"}}}}`,
+			want: map[string]serverProfile{
+				"remote": {Transport: "http", URL: "https://x/api"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, tt.file, tt.content)
+			servers, err := parseClientConfig(clientConfigFile{Source: "test", Path: path})
+			if err != nil {
+				t.Fatalf("parseClientConfig: %v", err)
+			}
+
+			got := make(map[string]serverProfile)
+			for _, s := range servers {
+				got[s.Name] = *s.Profile
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceForPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"/home/u/.config/Claude/claude_desktop_config.json": "claude-desktop",
+		"/home/u/.cursor/mcp.json":                          "cursor",
+		"/work/project/.vscode/mcp.json":                    "vscode",
+		"/home/u/.config/Code/User/settings.json":           "vscode",
+		"servers.json":                                      "file",
+	} {
+		if got := sourceForPath(path); got != want {
+			t.Errorf("sourceForPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	writeTestConfig(t, &appConfig{Profiles: map[string]*serverProfile{
+		"fs": {Transport: "http", URL: "http://existing"},
+	}})
+	path := writeTestFile(t, "claude_desktop_config.json",
+		`{"mcpServers": {"fs": {"command": "fs-server"}, "git": {"command": "git-server"}}}`)
+
+	defer func() { importForce, importDryRun = false, false }()
+
+	importDryRun = true
+	if err := runImport(importCmd, []string{path}); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if cfg, _ := loadAppConfig(); len(cfg.Profiles) != 1 {
+		t.Fatalf("dry run saved profiles: %v", cfg.Profiles)
+	}
+
+	importDryRun = false
+	if err := runImport(importCmd, []string{path}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	cfg, _ := loadAppConfig()
+	if cfg.Profiles["fs"].URL != "http://existing" || cfg.Profiles["git"].target() != "git-server" {
+		t.Errorf("expected existing profile kept and new one added, got %+v", cfg.Profiles)
+	}
+
+	importForce = true
+	if err := runImport(importCmd, []string{path}); err != nil {
+		t.Fatalf("import --force: %v", err)
+	}
+	cfg, _ = loadAppConfig()
+	if cfg.Profiles["fs"].Transport != "stdio" {
+		t.Errorf("expected --force to replace the profile, got %+v", cfg.Profiles["fs"])
+	}
+
+	if err := runImport(importCmd, []string{"/nonexistent/mcp.json"}); err == nil || !strings.Contains(err.Error(), "nonexistent") {
+		t.Errorf("expected error for missing file, got %v", err)
+	}
+}
+
+func TestRunListConfiguredMasksSecrets(t *testing.T) {
+	writeTestConfig(t, &appConfig{Profiles: map[string]*serverProfile{
+		"prod": {
+			Transport: "http", URL: "https://mcp.example.com/mcp",
+			Token:   "profile-token",
+			Headers: map[string]string{"Authorization": "Bearer header-secret"},
+		},
+	}})
+	t.Setenv("HOME", t.TempDir())
+	claude := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "Claude", "claude_desktop_config.json")
+	os.MkdirAll(filepath.Dir(claude), 0700)
+	os.WriteFile(claude, []byte(`{"mcpServers": {"gh": {"command": "gh-server", "env": {"GITHUB_TOKEN": "env-secret"}}}}`), 0600)
+
+	oldJSON := jsonOutput
+	jsonOutput = true
+	defer func() { jsonOutput = oldJSON }()
+
+	h := newTestHelper(t)
+	output := h.captureOutput(func() {
+		if err := runListConfigured(); err != nil {
+			t.Errorf("runListConfigured: %v", err)
+		}
+	})
+	for _, secret := range []string{"profile-token", "header-secret", "env-secret"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("listing shows %q:\n%s", secret, output)
+		}
+	}
+	h.assertStringContains(output, []string{`"Authorization":"********"`, `"GITHUB_TOKEN":"********"`})
+}
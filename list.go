@@ -5,25 +5,43 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"mcpmap/cache"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
 
-var jsonOutput bool
+var (
+	jsonOutput     bool
+	riskOutput     bool
+	minRiskLevel   string
+	riskCategories []string
+	allConfigured  bool
+)
 
 var listCmd = &cobra.Command{
 	Use:   "list [resources|tools|prompts]",
 	Short: "List available resources, tools, or prompts from the MCP server",
 	Long:  `List available resources, tools, or prompts from the MCP server. If no type is specified, all types will be listed.`,
 	Args:  cobra.MaximumNArgs(1),
-	RunE:  runList,
+	// A server is required unless --all-configured is given, see runList
+	Annotations: map[string]string{annotationTransportOptional: "true"},
+	RunE:        runList,
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+	addRecordFlag(listCmd)
 	listCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results in raw JSON format")
+	listCmd.Flags().BoolVar(&riskOutput, "risk", false, "Classify tools by capability and sort them by risk")
+	listCmd.Flags().
+		StringVar(&minRiskLevel, "min-risk", "", "With --risk, only show tools at or above this level (info, low, medium, high, critical)")
+	listCmd.Flags().
+		StringSliceVar(&riskCategories, "risk-category", nil, "With --risk, only show tools in these categories (e.g. code-exec,network-egress)")
+	listCmd.Flags().
+		BoolVar(&allConfigured, "all-configured", false, "List mcpmap profiles and servers configured in Claude Desktop, VS Code and Cursor")
 }
 
 // fetchAllServerData retrieves tools, resources, and prompts from the server
@@ -31,6 +49,7 @@ func fetchAllServerData(ctx context.Context, session *mcp.ClientSession) (*cache
 	var tools []*mcp.Tool
 	var resources []*mcp.Resource
 	var prompts []*mcp.Prompt
+	var templates []*mcp.ResourceTemplate
 
 	// Fetch tools
 	if toolsRes, err := session.ListTools(ctx, &mcp.ListToolsParams{}); err == nil {
@@ -42,15 +61,22 @@ func fetchAllServerData(ctx context.Context, session *mcp.ClientSession) (*cache
 		resources = resourcesRes.Resources
 	}
 
+	// Fetch resource templates
+	if templatesRes, err := session.ListResourceTemplates(ctx, &mcp.ListResourceTemplatesParams{}); err == nil {
+		templates = templatesRes.ResourceTemplates
+	}
+
 	// Fetch prompts
 	if promptsRes, err := session.ListPrompts(ctx, &mcp.ListPromptsParams{}); err == nil {
 		prompts = promptsRes.Prompts
 	}
 
 	return &cache.CacheData{
-		Tools:     tools,
-		Resources: resources,
-		Prompts:   prompts,
+		Tools:             tools,
+		Resources:         resources,
+		Prompts:           prompts,
+		ResourceTemplates: templates,
+		ServerInfo:        sessionServerInfo(session),
 	}, nil
 }
 
@@ -64,11 +90,9 @@ func displayCachedData(data *cache.CacheData, args []string) error {
 	switch listType {
 	case "tools":
 		if data.Tools != nil {
-			items := make([]any, len(data.Tools))
-			for i, tool := range data.Tools {
-				items[i] = tool
+			if err := outputTools(data.Tools); err != nil {
+				return err
 			}
-			outputItems(items, "tool")
 		}
 	case "resources":
 		if data.Resources != nil {
@@ -88,11 +112,9 @@ func displayCachedData(data *cache.CacheData, args []string) error {
 		}
 	case "all":
 		if data.Tools != nil {
-			items := make([]any, len(data.Tools))
-			for i, tool := range data.Tools {
-				items[i] = tool
+			if err := outputTools(data.Tools); err != nil {
+				return err
 			}
-			outputItems(items, "tool")
 		}
 		if data.Resources != nil {
 			items := make([]any, len(data.Resources))
@@ -119,25 +141,37 @@ func displayCachedData(data *cache.CacheData, args []string) error {
 }
 
 func runList(cmd *cobra.Command, args []string) error {
+	if allConfigured {
+		return runListConfigured()
+	}
+	if serverURL == "" {
+		return errNoTransport
+	}
+
 	ctx := context.Background()
 
 	// Initialize cache
-	c := cache.New(serverURL, transportType, authToken, clientName)
+	c := openCache(serverURL, transportType)
 
 	// Try cache first for faster response (will still fetch fresh data)
-	var cachedData *cache.CacheData
-	if data, _, _ := c.Load(); data != nil {
-		cachedData = data
-	}
+	cachedData, fresh := loadCached(c)
 
 	// Query server for fresh data synchronously
 	session, err := createSession(ctx, transportType, serverURL, proxyURL, authToken, clientName)
 	if err != nil {
 		// If cache exists, use it as fallback
 		if cachedData != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Using cached data (server unavailable)\n")
+			if fresh {
+				fmt.Fprintf(os.Stderr, "Warning: Using cached data (server unavailable)\n")
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: Using stale cached data older than --cache-ttl (server unavailable)\n")
+			}
 			return displayCachedData(cachedData, args)
 		}
+		if data := loadServerCache(serverURL, transportType); data != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Using data cached with another token or client name (server unavailable)\n")
+			return displayCachedData(data, args)
+		}
 		return fmt.Errorf("create session: %w", err)
 	}
 	defer session.Close()
@@ -161,9 +195,9 @@ func runList(cmd *cobra.Command, args []string) error {
 
 	switch listType {
 	case "tools":
-		items := make([]any, len(cachedData.Tools))
-		for i, tool := range cachedData.Tools { items[i] = tool }
-		outputItems(items, "tool")
+		if err := outputTools(cachedData.Tools); err != nil {
+			return err
+		}
 	case "resources":
 		items := make([]any, len(cachedData.Resources))
 		for i, r := range cachedData.Resources { items[i] = r }
@@ -173,10 +207,10 @@ func runList(cmd *cobra.Command, args []string) error {
 		for i, p := range cachedData.Prompts { items[i] = p }
 		outputItems(items, "prompt")
 	case "all":
-		items := make([]any, len(cachedData.Tools))
-		for i, tool := range cachedData.Tools { items[i] = tool }
-		outputItems(items, "tool")
-		items = make([]any, len(cachedData.Resources))
+		if err := outputTools(cachedData.Tools); err != nil {
+			return err
+		}
+		items := make([]any, len(cachedData.Resources))
 		for i, r := range cachedData.Resources { items[i] = r }
 		outputItems(items, "resource")
 		items = make([]any, len(cachedData.Prompts))
@@ -189,6 +223,42 @@ func runList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// outputTools prints tools, classified and ranked by risk when --risk is set
+func outputTools(tools []*mcp.Tool) error {
+	if !riskOutput {
+		items := make([]any, len(tools))
+		for i, tool := range tools {
+			items[i] = tool
+		}
+		outputItems(items, "tool")
+		return nil
+	}
+
+	ranked, err := rankTools(tools, minRiskLevel, riskCategories)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		for _, rt := range ranked {
+			js, err := json.Marshal(struct {
+				*mcp.Tool
+				Risk ToolRisk `json:"risk"`
+			}{rt.Tool, rt.Risk})
+			if err == nil {
+				fmt.Fprintln(os.Stdout, string(js))
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, rt := range ranked {
+		fmt.Fprintf(w, "tool:%s\t%s\t%s\n", rt.Tool.Name, rt.Risk.Level, strings.Join(rt.Risk.Categories, ","))
+	}
+	return w.Flush()
+}
+
 func outputItems(items []any, prefix string) {
 	if jsonOutput {
 		for _, item := range items {
@@ -0,0 +1,211 @@
+// listchanged.go - Keep cached metadata in sync with list_changed notifications
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// listChangedKinds maps the list_changed notifications to the list they announce
+var listChangedKinds = map[string]string{
+	"notifications/tools/list_changed":     "tools",
+	"notifications/resources/list_changed": "resources",
+	"notifications/prompts/list_changed":   "prompts",
+}
+
+// listRefetchTimeout bounds the refetch triggered by one notification
+const listRefetchTimeout = 30 * time.Second
+
+// listWatcher refetches a list when the server announces that it changed,
+// stores it in the server's cache entry and reports what changed
+type listWatcher struct {
+	label string
+	cache cache.Cache
+	out   io.Writer
+
+	// mu serializes refetches so concurrent ones do not overwrite each other
+	mu sync.Mutex
+	// pending counts the refetches still running, see flush
+	pending sync.WaitGroup
+}
+
+func newListWatcher(label string, c cache.Cache, out io.Writer) *listWatcher {
+	return &listWatcher{label: label, cache: c, out: out}
+}
+
+// changed refetches the list in the background. Notification handlers must
+// not block, and the refetch is a request on the same session.
+func (w *listWatcher) changed(session *mcp.ClientSession, kind string) {
+	w.pending.Add(1)
+	go func() {
+		defer w.pending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), listRefetchTimeout)
+		defer cancel()
+		if err := w.refetch(ctx, session, kind); err != nil {
+			fmt.Fprintf(w.out, "Warning: %s: refetch %s: %v\n", w.label, kind, err)
+		}
+	}()
+}
+
+// flush waits for running refetches, so their cache updates are saved
+// before the process exits
+func (w *listWatcher) flush() {
+	w.pending.Wait()
+}
+
+// clientOptions returns client options whose list_changed handlers feed w
+func (w *listWatcher) clientOptions() *mcp.ClientOptions {
+	if w == nil {
+		return nil
+	}
+	return &mcp.ClientOptions{
+		ToolListChangedHandler: func(ctx context.Context, cs *mcp.ClientSession, _ *mcp.ToolListChangedParams) {
+			w.changed(cs, "tools")
+		},
+		ResourceListChangedHandler: func(ctx context.Context, cs *mcp.ClientSession, _ *mcp.ResourceListChangedParams) {
+			w.changed(cs, "resources")
+		},
+		PromptListChangedHandler: func(ctx context.Context, cs *mcp.ClientSession, _ *mcp.PromptListChangedParams) {
+			w.changed(cs, "prompts")
+		},
+	}
+}
+
+// refetch fetches one list and updates its sections of the cache entry
+func (w *listWatcher) refetch(ctx context.Context, session *mcp.ClientSession, kind string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cached, _, _ := w.cache.Load()
+	if cached == nil {
+		cached = &cache.CacheData{}
+	}
+	data := &cache.CacheData{}
+	if err := fetchList(ctx, session, kind, data); err != nil {
+		return err
+	}
+	before, after := listEntries(cached, kind), listEntries(data, kind)
+
+	// A snapshot backend keeps serving the data it was opened with
+	if err := w.cache.Update(data, listSections(kind)...); err != nil && !errors.Is(err, cache.ErrReadOnly) {
+		return fmt.Errorf("save cache: %w", err)
+	}
+	fmt.Fprintf(w.out, "%s: %s\n", w.label, describeListChange(kind, before, after))
+	return nil
+}
+
+// listSections returns the cache sections of one list. Resource templates
+// have no notification of their own and change with the resources.
+func listSections(kind string) []cache.Section {
+	if kind == "resources" {
+		return []cache.Section{cache.SectionResources, cache.SectionResourceTemplates}
+	}
+	return []cache.Section{cache.Section(kind)}
+}
+
+// fetchList fetches every page of one list into data, with the resource
+// templates for the resources
+func fetchList(ctx context.Context, session *mcp.ClientSession, kind string, data *cache.CacheData) error {
+	switch kind {
+	case "tools":
+		tools := []*mcp.Tool{}
+		for tool, err := range session.Tools(ctx, nil) {
+			if err != nil {
+				return err
+			}
+			tools = append(tools, tool)
+		}
+		data.Tools = tools
+	case "resources":
+		resources := []*mcp.Resource{}
+		for resource, err := range session.Resources(ctx, nil) {
+			if err != nil {
+				return err
+			}
+			resources = append(resources, resource)
+		}
+		data.Resources = resources
+		templates := []*mcp.ResourceTemplate{}
+		for template, err := range session.ResourceTemplates(ctx, nil) {
+			if err != nil {
+				return err
+			}
+			templates = append(templates, template)
+		}
+		data.ResourceTemplates = templates
+	case "prompts":
+		prompts := []*mcp.Prompt{}
+		for prompt, err := range session.Prompts(ctx, nil) {
+			if err != nil {
+				return err
+			}
+			prompts = append(prompts, prompt)
+		}
+		data.Prompts = prompts
+	default:
+		return fmt.Errorf("unknown list %q", kind)
+	}
+	return nil
+}
+
+// listEntries returns the JSON of each entry of one list by name, or by URI
+// for resources and URI template for resource templates
+func listEntries(data *cache.CacheData, kind string) map[string]string {
+	entries := make(map[string]string)
+	add := func(key string, v any) {
+		js, _ := json.Marshal(v)
+		entries[key] = string(js)
+	}
+	switch kind {
+	case "tools":
+		for _, t := range data.Tools {
+			add(t.Name, t)
+		}
+	case "resources":
+		for _, r := range data.Resources {
+			add(r.URI, r)
+		}
+		for _, t := range data.ResourceTemplates {
+			add(t.URITemplate, t)
+		}
+	case "prompts":
+		for _, p := range data.Prompts {
+			add(p.Name, p)
+		}
+	}
+	return entries
+}
+
+// describeListChange summarizes the difference between two versions of a
+// list: +added, -removed and ~modified entries
+func describeListChange(kind string, before, after map[string]string) string {
+	var changes []string
+	for key, js := range after {
+		if old, ok := before[key]; !ok {
+			changes = append(changes, "+"+key)
+		} else if old != js {
+			changes = append(changes, "~"+key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, "-"+key)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i][1:] < changes[j][1:] })
+
+	if len(changes) == 0 {
+		return fmt.Sprintf("%s list changed, no differences to the cached %d", kind, len(after))
+	}
+	return fmt.Sprintf("%s list changed (%s), now %d cached", kind, strings.Join(changes, " "), len(after))
+}
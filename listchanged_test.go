@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestDescribeListChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]string
+		after  map[string]string
+		want   string
+	}{
+		{"added and removed", map[string]string{"a": "1", "c": "1"}, map[string]string{"a": "1", "b": "1"}, "tools list changed (+b -c), now 2 cached"},
+		{"modified", map[string]string{"a": "1"}, map[string]string{"a": "2"}, "tools list changed (~a), now 1 cached"},
+		{"unchanged", map[string]string{"a": "1"}, map[string]string{"a": "1"}, "tools list changed, no differences to the cached 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeListChange("tools", tt.before, tt.after); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// addNoopTool adds a tool without arguments to server
+func addNoopTool(server *mcp.Server, name string) {
+	mcp.AddTool(server, &mcp.Tool{Name: name},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[struct{}]) (*mcp.CallToolResultFor[any], error) {
+			return &mcp.CallToolResultFor[any]{}, nil
+		})
+}
+
+// waitForOutput polls out until it contains want
+func waitForOutput(t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(string(out.Bytes()), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q, got:\n%s", want, out.Bytes())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// cachedToolNames returns the names of the tools in c
+func cachedToolNames(t *testing.T, c cache.Cache) []string {
+	t.Helper()
+	data, _, err := c.Load()
+	if err != nil || data == nil {
+		t.Fatalf("load cache: %v", err)
+	}
+	var names []string
+	for _, tool := range data.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func TestListWatcherUpdatesCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c := openCacheWithToken("mem://server", "http", "")
+	prompts := []*mcp.Prompt{{Name: "kept"}}
+	if err := c.Save(&cache.CacheData{Tools: []*mcp.Tool{{Name: "old"}}, Prompts: prompts}); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "changing"}, nil)
+	addNoopTool(server, "first")
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(context.Background(), serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+
+	var out syncBuffer
+	watcher := newListWatcher("Upstream \"mem\"", c, &out)
+	client := mcp.NewClient(&mcp.Implementation{Name: "watcher-test"}, watcher.clientOptions())
+	session, err := client.Connect(context.Background(), clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer session.Close()
+
+	addNoopTool(server, "second")
+	waitForOutput(t, &out, "+second")
+
+	if got := strings.Join(cachedToolNames(t, c), ","); got != "first,second" {
+		t.Errorf("cached tools = %s, want first,second", got)
+	}
+	if !strings.Contains(string(out.Bytes()), `Upstream "mem": tools list changed (+first -old +second)`) {
+		t.Errorf("unexpected report: %s", out.Bytes())
+	}
+	if data, _, _ := c.Load(); len(data.Prompts) != 1 {
+		t.Errorf("other lists must be kept, got prompts %v", data.Prompts)
+	}
+}
+
+func TestListWatcherUpdatesResourceTemplates(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c := openCacheWithToken("mem://resources", "http", "")
+	if err := c.Save(&cache.CacheData{ResourceTemplates: []*mcp.ResourceTemplate{{Name: "old", URITemplate: "old://{id}"}}}); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+
+	read := func(context.Context, *mcp.ServerSession, *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error) {
+		return &mcp.ReadResourceResult{}, nil
+	}
+	server := mcp.NewServer(&mcp.Implementation{Name: "changing"}, nil)
+	server.AddResource(&mcp.Resource{Name: "readme", URI: "file:///README.md"}, read)
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(context.Background(), serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+
+	var out syncBuffer
+	watcher := newListWatcher("Server", c, &out)
+	client := mcp.NewClient(&mcp.Implementation{Name: "watcher-test"}, watcher.clientOptions())
+	session, err := client.Connect(context.Background(), clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer session.Close()
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{Name: "file", URITemplate: "file:///{path}"}, read)
+	waitForOutput(t, &out, "+file:///{path}")
+
+	data, _, err := c.Load()
+	if err != nil || data == nil {
+		t.Fatalf("load cache: %v", err)
+	}
+	if len(data.Resources) != 1 || len(data.ResourceTemplates) != 1 || data.ResourceTemplates[0].URITemplate != "file:///{path}" {
+		t.Errorf("cached resources %v and templates %v, want both refetched", data.Resources, data.ResourceTemplates)
+	}
+	if !strings.Contains(string(out.Bytes()), "Server: resources list changed (+file:///README.md +file:///{path} -old://{id}), now 2 cached") {
+		t.Errorf("unexpected report: %s", out.Bytes())
+	}
+}
+
+func TestProxyRefetchesOnListChanged(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	upstream := mcp.NewServer(&mcp.Implementation{Name: "changing"}, nil)
+	addNoopTool(upstream, "first")
+	dial := func() (mcp.Transport, error) {
+		serverTransport, clientTransport := mcp.NewInMemoryTransports()
+		if _, err := upstream.Connect(context.Background(), serverTransport); err != nil {
+			return nil, err
+		}
+		return clientTransport, nil
+	}
+
+	var out syncBuffer
+	c := openCacheWithToken("mem://proxied", "http", "")
+	watcher := newListWatcher("Server", c, &out)
+	proxy := httptest.NewServer(newMCPHandler(func(*http.Request) *mcp.Server {
+		return newProxyServer(dial, nil, proxyHooks{listChanged: watcher.changed})
+	}))
+	defer proxy.Close()
+
+	// The downstream client still receives the notification
+	notified := make(chan struct{}, 1)
+	client := mcp.NewClient(&mcp.Implementation{Name: "proxy-test-client"}, &mcp.ClientOptions{
+		ToolListChangedHandler: func(context.Context, *mcp.ClientSession, *mcp.ToolListChangedParams) {
+			notified <- struct{}{}
+		},
+	})
+	session, err := client.Connect(context.Background(), mcp.NewSSEClientTransport(proxy.URL+"/sse", nil))
+	if err != nil {
+		t.Fatalf("connect through proxy: %v", err)
+	}
+	defer session.Close()
+
+	addNoopTool(upstream, "second")
+	waitForOutput(t, &out, "Server: tools list changed (+first +second)")
+
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Error("notification was not forwarded to the client")
+	}
+	if got := strings.Join(cachedToolNames(t, c), ","); got != "first,second" {
+		t.Errorf("cached tools = %s, want first,second", got)
+	}
+}
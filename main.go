@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"mcpmap/cache"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +17,8 @@ var (
 	proxyURL      string
 	authToken     string
 	clientName    string
+	readOnlyMode  bool
+	assumeYes     bool
 )
 
 var rootCmd = &cobra.Command{
@@ -24,7 +29,26 @@ It supports both SSE (Server-Sent Events) and Streamable HTTP transport options.
 }
 
 func validateFlags(cmd *cobra.Command, args []string) error {
-	config, err := parseTransportFlags(cmd)
+	if err := configureRedaction(); err != nil {
+		return err
+	}
+	if err := configureCache(cmd); err != nil {
+		return err
+	}
+
+	profile, err := selectedProfile(cmd)
+	if err != nil {
+		return err
+	}
+	profile = scopeProfile(cmd, profile)
+	if profile != nil {
+		applyProfile(cmd, profile)
+	}
+	if err := resolveCredentials(cmd, profile); err != nil {
+		return err
+	}
+
+	config, err := parseTransportFlags(cmd, profile)
 	if err != nil {
 		return err
 	}
@@ -41,12 +65,20 @@ func validateFlags(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// errNoTransport is returned when no server was selected
+var errNoTransport = errors.New("must specify either --sse=<url>, --http=<url> or a profile with --profile")
+
+// annotationTransportOptional marks commands that may run without --sse or --http
+const annotationTransportOptional = "mcpmap/transport-optional"
+
 type transportConfig struct {
 	transportType string
 	serverURL     string
 }
 
-func parseTransportFlags(cmd *cobra.Command) (*transportConfig, error) {
+// parseTransportFlags returns the server selected by --sse or --http, falling
+// back to profile if neither flag is given
+func parseTransportFlags(cmd *cobra.Command, profile *serverProfile) (*transportConfig, error) {
 	if cmd.Name() == "completion" || cmd.Name() == "__complete" ||
 		cmd.Name() == "__completeNoDesc" || cmd.Name() == "cache" ||
 		cmd.Name() == "clear" || cmd.Name() == "info" {
@@ -56,6 +88,11 @@ func parseTransportFlags(cmd *cobra.Command) (*transportConfig, error) {
 	sseFlag := cmd.Flag("sse")
 	httpFlag := cmd.Flag("http")
 
+	// Commands that can also work without a server opt out of the requirement
+	if cmd.Annotations[annotationTransportOptional] == "true" && !sseFlag.Changed && !httpFlag.Changed && profile == nil {
+		return nil, nil
+	}
+
 	if sseFlag.Changed && httpFlag.Changed {
 		return nil, fmt.Errorf("cannot specify both --sse and --http flags")
 	}
@@ -66,8 +103,11 @@ func parseTransportFlags(cmd *cobra.Command) (*transportConfig, error) {
 	if httpFlag.Changed {
 		return &transportConfig{"http", httpFlag.Value.String()}, nil
 	}
+	if profile != nil {
+		return &transportConfig{profile.Transport, profile.target()}, nil
+	}
 
-	return nil, fmt.Errorf("must specify either --sse=<url> or --http=<url>")
+	return nil, errNoTransport
 }
 
 // createCompletionCommand creates the completion command
@@ -102,11 +142,44 @@ func init() {
 	rootCmd.PersistentFlags().
 		StringVar(&proxyURL, "proxy", "", "HTTP proxy URL (e.g., http://proxy.example.com:8080)")
 	rootCmd.PersistentFlags().
-		StringVar(&authToken, "token", "", "Bearer token for authentication")
+		StringVar(&authToken, "token", "", "Bearer token for authentication (default $"+envToken+")")
+	rootCmd.PersistentFlags().
+		StringVar(&tokenFile, "token-file", "", "Read the bearer token from this file")
+	rootCmd.PersistentFlags().
+		StringVar(&tokenCmd, "token-cmd", "", "Run this shell command (sh, or cmd on Windows) to get the bearer token, again if the server rejects it")
 	rootCmd.PersistentFlags().
 		StringVarP(&clientName, "name", "n", "mcpmap", "Client name to send in MCP initialize request")
+	rootCmd.PersistentFlags().
+		BoolVar(&readOnlyMode, "read-only", false, "Refuse to call any tool not annotated as read-only")
+	rootCmd.PersistentFlags().
+		BoolVarP(&assumeYes, "yes", "y", false, "Call destructive or open-world tools without asking for confirmation")
+	rootCmd.PersistentFlags().
+		StringVarP(&profileName, "profile", "s", "", "Use the named server profile from the config file (default $"+envProfile+")")
+	rootCmd.PersistentFlags().
+		BoolVar(&noRedact, "no-redact", false, "Show secrets in tool output and recorded traffic instead of masking them")
+	rootCmd.PersistentFlags().
+		StringArrayVar(&redactPatterns, "redact-pattern", []string{}, "Additional regular expression to redact (can be repeated)")
+	rootCmd.PersistentFlags().
+		DurationVar(&cacheTTL, "cache-ttl", cache.DefaultTTL, "How long cached server metadata counts as fresh (0 keeps it fresh forever)")
+	rootCmd.PersistentFlags().
+		BoolVar(&noCache, "no-cache", false, "Neither read nor write cached server metadata")
+	rootCmd.PersistentFlags().
+		BoolVar(&refreshCache, "refresh", false, "Ignore cached server metadata and update it from the server")
+	rootCmd.PersistentFlags().
+		StringVar(&cacheBackend, "cache-backend", cache.BackendFiles,
+			"Where to cache server metadata: "+strings.Join(cache.Backends, ", "))
+	rootCmd.PersistentFlags().
+		StringVar(&cachePath, "cache-path", "", "Store file of the single-file backend or bundle served by the snapshot backend")
+	rootCmd.PersistentFlags().
+		StringVar(&cacheKeyFile, "cache-key-file", "", "Encrypt cached metadata with a key derived from this file (default $"+envCachePassphrase+" as passphrase)")
+
+	rootCmd.RegisterFlagCompletionFunc("profile", profileNameCompletion)
+	rootCmd.RegisterFlagCompletionFunc("cache-backend", cobra.FixedCompletions(cache.Backends, cobra.ShellCompDirectiveNoFileComp))
 
 	rootCmd.PersistentPreRunE = validateFlags
+	rootCmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
+		outputRedactor.Report(os.Stderr)
+	}
 	rootCmd.AddCommand(createCompletionCommand())
 }
 
@@ -0,0 +1,470 @@
+// mock.go - Mock MCP server built from cached or file-based metadata
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"text/template"
+
+	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mockFromCache string
+	mockFromFile  string
+	mockFixtures  string
+	mockListen    string
+)
+
+var mockCmd = &cobra.Command{
+	Use:   "mock",
+	Short: "Serve a mock MCP server from cached or file-based metadata",
+	Long: `Serve a mock MCP server exposing exactly the tools, resources and prompts of
+another server, without the real backend.
+
+The definitions come from the cache of a server mcpmap has listed before
+(--from-cache <server-url>) or from a JSON/YAML file with the same "tools",
+"resources" and "prompts" lists as the cache (--from-file).
+
+Responses come from a fixture file (--fixtures). Tool and prompt fixtures are
+tried in order and the first whose "match" arguments all equal the call's
+arguments is used; "text" and "error" are Go templates executed with the
+arguments, e.g. "Results for {{.query}}". Without a matching fixture a
+generic response is returned.
+
+Example fixture file:
+  tools:
+    search:
+      - match: {query: "nothing"}
+        text: "No results"
+      - text: "Results for {{.query}}"
+    delete_file:
+      - error: "permission denied: {{.path}}"
+  resources:
+    "file:///README.md":
+      text: "# Mock readme"
+      mimeType: text/markdown
+  prompts:
+    greet:
+      - text: "Hello {{.name}}"
+
+Examples:
+  mcpmap mock --from-cache https://mcp.example.com/mcp --fixtures fixtures.yaml
+  mcpmap mock --from-file server.json --listen 127.0.0.1:9000`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{annotationTransportOptional: "true"},
+	RunE:        runMock,
+}
+
+func init() {
+	rootCmd.AddCommand(mockCmd)
+	mockCmd.Flags().StringVar(&mockFromCache, "from-cache", "", "Serve the cached metadata of this server URL")
+	mockCmd.Flags().StringVar(&mockFromFile, "from-file", "", "Serve the metadata in this JSON or YAML file")
+	mockCmd.Flags().StringVar(&mockFixtures, "fixtures", "", "JSON or YAML file with canned responses")
+	mockCmd.Flags().StringVarP(&mockListen, "listen", "l", "127.0.0.1:9000", "Address to accept MCP clients on")
+}
+
+// mockFixtureSet holds the canned responses of a mock server
+type mockFixtureSet struct {
+	Tools     map[string][]mockResponse `json:"tools"`
+	Resources map[string]mockResponse   `json:"resources"`
+	Prompts   map[string][]mockResponse `json:"prompts"`
+}
+
+// mockResponse is one canned response. Text and Error are templates executed
+// with the call's arguments; Result is returned verbatim.
+type mockResponse struct {
+	Match    map[string]any  `json:"match,omitempty"`
+	Text     string          `json:"text,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	MIMEType string          `json:"mimeType,omitempty"`
+}
+
+// matches reports whether every argument in Match equals the call's argument
+func (r *mockResponse) matches(args map[string]any) bool {
+	for name, want := range r.Match {
+		got, ok := args[name]
+		if !ok || !reflect.DeepEqual(jsonValue(want), jsonValue(got)) {
+			return false
+		}
+	}
+	return true
+}
+
+// render executes tmpl with the call's arguments
+func (r *mockResponse) render(tmpl string, args map[string]any) (string, error) {
+	t, err := template.New("fixture").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, args); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// validate parses every template so mistakes surface at startup
+func (f *mockFixtureSet) validate() error {
+	check := func(kind, name string, r mockResponse) error {
+		for _, tmpl := range []string{r.Text, r.Error} {
+			if _, err := template.New("fixture").Parse(tmpl); err != nil {
+				return fmt.Errorf("%s %q: %w", kind, name, err)
+			}
+		}
+		return nil
+	}
+
+	for name, responses := range f.Tools {
+		for _, r := range responses {
+			if err := check("tool", name, r); err != nil {
+				return err
+			}
+		}
+	}
+	for uri, r := range f.Resources {
+		if err := check("resource", uri, r); err != nil {
+			return err
+		}
+	}
+	for name, responses := range f.Prompts {
+		for _, r := range responses {
+			if err := check("prompt", name, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// firstMatch returns the first response matching args, or nil
+func firstMatch(responses []mockResponse, args map[string]any) *mockResponse {
+	for i := range responses {
+		if responses[i].matches(args) {
+			return &responses[i]
+		}
+	}
+	return nil
+}
+
+// mockServer answers MCP requests from static definitions and fixtures
+type mockServer struct {
+	defs      *cache.CacheData
+	fixtures  *mockFixtureSet
+	tools     map[string]*mcp.Tool
+	resources map[string]*mcp.Resource
+	prompts   map[string]*mcp.Prompt
+}
+
+func newMockServer(defs *cache.CacheData, fixtures *mockFixtureSet) *mockServer {
+	if fixtures == nil {
+		fixtures = &mockFixtureSet{}
+	}
+	m := &mockServer{
+		defs:      defs,
+		fixtures:  fixtures,
+		tools:     make(map[string]*mcp.Tool),
+		resources: make(map[string]*mcp.Resource),
+		prompts:   make(map[string]*mcp.Prompt),
+	}
+	for _, t := range defs.Tools {
+		m.tools[t.Name] = t
+	}
+	for _, r := range defs.Resources {
+		m.resources[r.URI] = r
+	}
+	for _, p := range defs.Prompts {
+		m.prompts[p.Name] = p
+	}
+	return m
+}
+
+// Server returns an MCP server for a single session. Requests are answered by
+// middleware, so tool schemas are served exactly as defined.
+func (m *mockServer) Server() *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "mcpmap-mock", Version: "v1.0.0"}, nil)
+	server.AddReceivingMiddleware(m.middleware)
+	return server
+}
+
+func (m *mockServer) middleware(next mcp.MethodHandler[*mcp.ServerSession]) mcp.MethodHandler[*mcp.ServerSession] {
+	return func(ctx context.Context, ss *mcp.ServerSession, method string, params mcp.Params) (mcp.Result, error) {
+		switch method {
+		case "initialize":
+			res, err := next(ctx, ss, method, params)
+			if err != nil {
+				return nil, err
+			}
+			return withCapabilities(res, m.capabilities())
+		case "tools/list":
+			return &mcp.ListToolsResult{Tools: m.defs.Tools}, nil
+		case "resources/list":
+			return &mcp.ListResourcesResult{Resources: m.defs.Resources}, nil
+		case "prompts/list":
+			return &mcp.ListPromptsResult{Prompts: m.defs.Prompts}, nil
+		case "tools/call":
+			p, err := decodeParams[mcp.CallToolParams](params)
+			if err != nil {
+				return nil, err
+			}
+			return m.callTool(p)
+		case "resources/read":
+			p, err := decodeParams[mcp.ReadResourceParams](params)
+			if err != nil {
+				return nil, err
+			}
+			return m.readResource(p)
+		case "prompts/get":
+			p, err := decodeParams[mcp.GetPromptParams](params)
+			if err != nil {
+				return nil, err
+			}
+			return m.getPrompt(p)
+		}
+		return next(ctx, ss, method, params)
+	}
+}
+
+// capabilities advertises the kinds of definitions being served
+func (m *mockServer) capabilities() map[string]any {
+	caps := make(map[string]any)
+	if len(m.defs.Tools) > 0 {
+		caps["tools"] = map[string]any{}
+	}
+	if len(m.defs.Resources) > 0 {
+		caps["resources"] = map[string]any{}
+	}
+	if len(m.defs.Prompts) > 0 {
+		caps["prompts"] = map[string]any{}
+	}
+	return caps
+}
+
+func (m *mockServer) callTool(params *mcp.CallToolParams) (mcp.Result, error) {
+	tool, ok := m.tools[params.Name]
+	if !ok {
+		return nil, jsonRPCError(codeInvalidParams, fmt.Sprintf("unknown tool %q", params.Name))
+	}
+
+	args, _ := jsonValue(params.Arguments).(map[string]any)
+	if args == nil {
+		args = make(map[string]any)
+	}
+
+	if schema, err := toolSchema(tool); err == nil {
+		for _, name := range schema.Required {
+			if _, ok := args[name]; !ok {
+				return toolTextResult(fmt.Sprintf("missing required argument %q", name), true), nil
+			}
+		}
+	}
+
+	fixture := firstMatch(m.fixtures.Tools[params.Name], args)
+	if fixture == nil {
+		return toolTextResult(fmt.Sprintf("mock %s(%s)", params.Name, compactJSON(args)), false), nil
+	}
+	if len(fixture.Result) > 0 {
+		return rawMessage(fixture.Result), nil
+	}
+	if fixture.Error != "" {
+		text, err := fixture.render(fixture.Error, args)
+		if err != nil {
+			return nil, fmt.Errorf("fixture for tool %q: %w", params.Name, err)
+		}
+		return toolTextResult(text, true), nil
+	}
+	text, err := fixture.render(fixture.Text, args)
+	if err != nil {
+		return nil, fmt.Errorf("fixture for tool %q: %w", params.Name, err)
+	}
+	return toolTextResult(text, false), nil
+}
+
+func toolTextResult(text string, isError bool) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+		IsError: isError,
+	}
+}
+
+func (m *mockServer) readResource(params *mcp.ReadResourceParams) (mcp.Result, error) {
+	resource, defined := m.resources[params.URI]
+	fixture, hasFixture := m.fixtures.Resources[params.URI]
+	if !defined && !hasFixture {
+		return nil, mcp.ResourceNotFoundError(params.URI)
+	}
+
+	contents := &mcp.ResourceContents{URI: params.URI, Text: fmt.Sprintf("mock contents of %s", params.URI)}
+	if defined {
+		contents.MIMEType = resource.MIMEType
+	}
+	if hasFixture {
+		if len(fixture.Result) > 0 {
+			return rawMessage(fixture.Result), nil
+		}
+		vars := map[string]any{"uri": params.URI}
+		if fixture.Error != "" {
+			message, err := fixture.render(fixture.Error, vars)
+			if err != nil {
+				return nil, fmt.Errorf("fixture for resource %q: %w", params.URI, err)
+			}
+			return nil, fmt.Errorf("%s", message)
+		}
+		text, err := fixture.render(fixture.Text, vars)
+		if err != nil {
+			return nil, fmt.Errorf("fixture for resource %q: %w", params.URI, err)
+		}
+		contents.Text = text
+		if fixture.MIMEType != "" {
+			contents.MIMEType = fixture.MIMEType
+		}
+	}
+
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{contents}}, nil
+}
+
+func (m *mockServer) getPrompt(params *mcp.GetPromptParams) (mcp.Result, error) {
+	prompt, ok := m.prompts[params.Name]
+	if !ok {
+		return nil, jsonRPCError(codeInvalidParams, fmt.Sprintf("unknown prompt %q", params.Name))
+	}
+
+	args := make(map[string]any, len(params.Arguments))
+	for k, v := range params.Arguments {
+		args[k] = v
+	}
+	for _, arg := range prompt.Arguments {
+		if _, ok := args[arg.Name]; arg.Required && !ok {
+			return nil, jsonRPCError(codeInvalidParams, fmt.Sprintf("missing required argument %q", arg.Name))
+		}
+	}
+
+	text := fmt.Sprintf("mock %s(%s)", params.Name, compactJSON(args))
+	if fixture := firstMatch(m.fixtures.Prompts[params.Name], args); fixture != nil {
+		if len(fixture.Result) > 0 {
+			return rawMessage(fixture.Result), nil
+		}
+		if fixture.Error != "" {
+			message, err := fixture.render(fixture.Error, args)
+			if err != nil {
+				return nil, fmt.Errorf("fixture for prompt %q: %w", params.Name, err)
+			}
+			return nil, fmt.Errorf("%s", message)
+		}
+		rendered, err := fixture.render(fixture.Text, args)
+		if err != nil {
+			return nil, fmt.Errorf("fixture for prompt %q: %w", params.Name, err)
+		}
+		text = rendered
+	}
+
+	return &mcp.GetPromptResult{
+		Description: prompt.Description,
+		Messages:    []*mcp.PromptMessage{{Role: "user", Content: &mcp.TextContent{Text: text}}},
+	}, nil
+}
+
+// withCapabilities merges caps into the capabilities of an InitializeResult.
+// The SDK only advertises what was registered with the server itself.
+func withCapabilities(res mcp.Result, caps map[string]any) (mcp.Result, error) {
+	m, ok := jsonValue(res).(map[string]any)
+	if !ok {
+		return res, nil
+	}
+	merged, _ := m["capabilities"].(map[string]any)
+	if merged == nil {
+		merged = make(map[string]any)
+	}
+	for k, v := range caps {
+		merged[k] = v
+	}
+	m["capabilities"] = merged
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("marshal initialize result: %w", err)
+	}
+	return rawMessage(data), nil
+}
+
+// loadMockDefinitions loads the served definitions from the cache or a file
+func loadMockDefinitions(fromCache, fromFile string) (*cache.CacheData, error) {
+	switch {
+	case fromCache != "" && fromFile != "":
+		return nil, fmt.Errorf("cannot specify both --from-cache and --from-file")
+	case fromFile != "":
+		var defs cache.CacheData
+		if err := decodeStructuredFile(fromFile, &defs); err != nil {
+			return nil, err
+		}
+		return &defs, nil
+	case fromCache != "":
+		for _, transport := range []string{"http", "sse"} {
+			c := openCache(fromCache, transport)
+			if data, fresh, err := c.Load(); err == nil && data != nil {
+				if !fresh {
+					fmt.Fprintf(os.Stderr, "Warning: Cached metadata for %s is older than --cache-ttl, run 'mcpmap cache refresh' to update it\n", fromCache)
+				}
+				return data, nil
+			}
+		}
+		return nil, fmt.Errorf("no cached metadata for %s, run 'mcpmap list' against it first", fromCache)
+	}
+	return nil, fmt.Errorf("must specify either --from-cache=<server-url> or --from-file=<path>")
+}
+
+func runMock(cmd *cobra.Command, args []string) error {
+	defs, err := loadMockDefinitions(mockFromCache, mockFromFile)
+	if err != nil {
+		return err
+	}
+
+	fixtures := &mockFixtureSet{}
+	if mockFixtures != "" {
+		if err := decodeStructuredFile(mockFixtures, fixtures); err != nil {
+			return err
+		}
+		if err := fixtures.validate(); err != nil {
+			return fmt.Errorf("invalid fixtures: %w", err)
+		}
+		for _, name := range sortedFixtureNames(fixtures.Tools) {
+			if !hasTool(defs.Tools, name) {
+				fmt.Fprintf(os.Stderr, "Warning: fixtures for unknown tool %q\n", name)
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving %d tools, %d resources, %d prompts\n",
+		len(defs.Tools), len(defs.Resources), len(defs.Prompts))
+
+	mock := newMockServer(defs, fixtures)
+	handler := newMCPHandler(func(*http.Request) *mcp.Server { return mock.Server() })
+	return serveMCP(mockListen, "Mock server", handler)
+}
+
+func sortedFixtureNames(m map[string][]mockResponse) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hasTool(tools []*mcp.Tool, name string) bool {
+	for _, t := range tools {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcpmap/cache"
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const testFixturesYAML = `
+tools:
+  search:
+    - match: {query: "nothing", limit: 5}
+      text: "No results"
+    - text: "Results for {{.query}}"
+  delete_file:
+    - error: "permission denied: {{.path}}"
+resources:
+  "file:///README.md":
+    text: "# Mock readme"
+    mimeType: text/markdown
+  "file:///secret":
+    error: "access denied: {{.uri}}"
+prompts:
+  greet:
+    - match: {name: "Mallory"}
+      error: "{{.name}} is not welcome"
+    - text: "Hello {{.name}}"
+`
+
+func testMockDefinitions() *cache.CacheData {
+	return &cache.CacheData{
+		Tools: []*mcp.Tool{
+			{Name: "search", InputSchema: &jsonschema.Schema{
+				Type: "object",
+				Properties: map[string]*jsonschema.Schema{
+					"query": {Type: "string"},
+					"limit": {Type: "integer"},
+				},
+				Required: []string{"query"},
+			}},
+			{Name: "delete_file"},
+			{Name: "ping"},
+		},
+		Resources: []*mcp.Resource{{URI: "file:///README.md", Name: "readme", MIMEType: "text/plain"}},
+		Prompts: []*mcp.Prompt{{Name: "greet", Arguments: []*mcp.PromptArgument{
+			{Name: "name", Required: true},
+		}}},
+	}
+}
+
+// connectMock connects a client to a mock server over in-memory transports
+func connectMock(t *testing.T, mock *mockServer) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := mock.Server().Connect(ctx, serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "mock-test"}, nil)
+	session, err := client.Connect(ctx, clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestMockServerTools(t *testing.T) {
+	var fixtures mockFixtureSet
+	if err := decodeStructuredFile(writeTestFile(t, "fixtures.yaml", testFixturesYAML), &fixtures); err != nil {
+		t.Fatalf("decode fixtures: %v", err)
+	}
+	if err := fixtures.validate(); err != nil {
+		t.Fatalf("validate fixtures: %v", err)
+	}
+	session := connectMock(t, newMockServer(testMockDefinitions(), &fixtures))
+	ctx := context.Background()
+
+	tools, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	if len(tools.Tools) != 3 || tools.Tools[0].InputSchema.Required[0] != "query" {
+		t.Errorf("expected the defined tools with their schemas, got %v", tools.Tools)
+	}
+
+	tests := []struct {
+		name      string
+		tool      string
+		args      map[string]any
+		want      string
+		wantError bool
+	}{
+		{"matching fixture", "search", map[string]any{"query": "nothing", "limit": 5}, "No results", false},
+		{"templated fixture", "search", map[string]any{"query": "cats"}, "Results for cats", false},
+		{"missing required", "search", map[string]any{}, `missing required argument "query"`, true},
+		{"error fixture", "delete_file", map[string]any{"path": "/etc"}, "permission denied: /etc", true},
+		{"no fixture", "ping", map[string]any{"x": 1}, `mock ping({"x":1})`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: tt.tool, Arguments: tt.args})
+			if err != nil {
+				t.Fatalf("CallTool: %v", err)
+			}
+			if got := contentText(res.Content); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if res.IsError != tt.wantError {
+				t.Errorf("IsError = %v, want %v", res.IsError, tt.wantError)
+			}
+		})
+	}
+
+	if _, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "unknown"}); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+		t.Errorf("expected unknown tool error, got %v", err)
+	}
+}
+
+func TestMockServerResourcesAndPrompts(t *testing.T) {
+	var fixtures mockFixtureSet
+	if err := decodeStructuredFile(writeTestFile(t, "fixtures.yaml", testFixturesYAML), &fixtures); err != nil {
+		t.Fatalf("decode fixtures: %v", err)
+	}
+	session := connectMock(t, newMockServer(testMockDefinitions(), &fixtures))
+	ctx := context.Background()
+
+	res, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "file:///README.md"})
+	if err != nil {
+		t.Fatalf("ReadResource: %v", err)
+	}
+	if c := res.Contents[0]; c.Text != "# Mock readme" || c.MIMEType != "text/markdown" {
+		t.Errorf("unexpected resource contents %+v", c)
+	}
+	if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "file:///missing"}); err == nil {
+		t.Error("expected error reading undefined resource")
+	}
+
+	prompt, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "greet", Arguments: map[string]string{"name": "Ada"}})
+	if err != nil {
+		t.Fatalf("GetPrompt: %v", err)
+	}
+	if text := prompt.Messages[0].Content.(*mcp.TextContent).Text; text != "Hello Ada" {
+		t.Errorf("unexpected prompt text %q", text)
+	}
+	if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "file:///secret"}); err == nil || !strings.Contains(err.Error(), "access denied: file:///secret") {
+		t.Errorf("expected the rendered resource error fixture, got %v", err)
+	}
+	if _, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "greet", Arguments: map[string]string{"name": "Mallory"}}); err == nil || !strings.Contains(err.Error(), "Mallory is not welcome") {
+		t.Errorf("expected the rendered prompt error fixture, got %v", err)
+	}
+	if _, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "greet"}); err == nil {
+		t.Error("expected error for missing required prompt argument")
+	}
+}
+
+func TestLoadMockDefinitions(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	if err := cache.New("http://cached.example/mcp", "sse", "", clientName).Save(testMockDefinitions()); err != nil {
+		t.Fatalf("save cache: %v", err)
+	}
+	defs, err := loadMockDefinitions("http://cached.example/mcp", "")
+	if err != nil {
+		t.Fatalf("from cache: %v", err)
+	}
+	if len(defs.Tools) != 3 {
+		t.Errorf("expected 3 cached tools, got %d", len(defs.Tools))
+	}
+
+	if _, err := loadMockDefinitions("http://uncached.example/mcp", ""); err == nil {
+		t.Error("expected error for uncached server")
+	}
+
+	path := writeTestFile(t, "server.yaml", `
+tools:
+  - name: search
+    inputSchema:
+      type: object
+      properties:
+        query: {type: string}
+resources:
+  - uri: "file:///a"
+    name: a
+`)
+	defs, err = loadMockDefinitions("", path)
+	if err != nil {
+		t.Fatalf("from file: %v", err)
+	}
+	if len(defs.Tools) != 1 || defs.Tools[0].InputSchema.Properties["query"].Type != "string" || len(defs.Resources) != 1 {
+		t.Errorf("unexpected definitions from file: %+v", defs)
+	}
+
+	if _, err := loadMockDefinitions("a", "b"); err == nil {
+		t.Error("expected error for both sources")
+	}
+}
+
+func TestFixtureValidate(t *testing.T) {
+	fixtures := &mockFixtureSet{Tools: map[string][]mockResponse{"x": {{Text: "{{.broken"}}}}
+	if err := fixtures.validate(); err == nil || !strings.Contains(err.Error(), `tool "x"`) {
+		t.Errorf("expected template error for tool x, got %v", err)
+	}
+}
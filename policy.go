@@ -0,0 +1,426 @@
+// policy.go - Policy enforcement for tool calls forwarded by proxy and gateway
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// codePolicyDenied is the JSON-RPC error code of calls refused by policy
+const codePolicyDenied = -32001
+
+// Policy actions
+const (
+	policyAllow = "allow"
+	policyDeny  = "deny"
+)
+
+// policyFilePath is the policy enforced by proxy and gateway, if set
+var policyFilePath string
+
+// policyFile is the policy file format
+type policyFile struct {
+	// Default is the action for tools no rule matches: allow or deny
+	Default string `json:"default"`
+	// BlockDestructive refuses tools annotated with destructiveHint
+	BlockDestructive  bool         `json:"block_destructive"`
+	MaxCallsPerMinute int          `json:"max_calls_per_minute"`
+	Rules             []policyRule `json:"rules"`
+}
+
+// policyRule applies to tools matching any of its name globs. The first
+// matching rule decides.
+type policyRule struct {
+	Tools             []string                    `json:"tools"`
+	Action            string                      `json:"action"`
+	Reason            string                      `json:"reason"`
+	Arguments         map[string]*argumentMatcher `json:"arguments"`
+	MaxCallsPerMinute int                         `json:"max_calls_per_minute"`
+}
+
+// argumentMatcher constrains the value of the parameters matching its key
+type argumentMatcher struct {
+	Required bool       `json:"required"`
+	Under    stringList `json:"under"`
+	Pattern  string     `json:"pattern"`
+	Enum     []any      `json:"enum"`
+	Min      *float64   `json:"min"`
+	Max      *float64   `json:"max"`
+
+	re *regexp.Regexp
+}
+
+// stringList accepts either a single string or a list of strings
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or a list of strings")
+	}
+	*l = list
+	return nil
+}
+
+// loadPolicy reads and validates a policy file
+func loadPolicy(path string) (*policyEngine, error) {
+	var p policyFile
+	if err := decodeStructuredFile(path, &p); err != nil {
+		return nil, err
+	}
+	if err := p.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return newPolicyEngine(&p), nil
+}
+
+// compile validates the policy and prepares its matchers
+func (p *policyFile) compile() error {
+	switch p.Default {
+	case "":
+		p.Default = policyAllow
+	case policyAllow, policyDeny:
+	default:
+		return fmt.Errorf("default must be %q or %q, got %q", policyAllow, policyDeny, p.Default)
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if len(r.Tools) == 0 {
+			return fmt.Errorf("rule %d: no tools", i+1)
+		}
+		for _, pattern := range r.Tools {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid tool pattern %q", i+1, pattern)
+			}
+		}
+		switch r.Action {
+		case "":
+			r.Action = policyAllow
+		case policyAllow, policyDeny:
+		default:
+			return fmt.Errorf("rule %d: action must be %q or %q, got %q", i+1, policyAllow, policyDeny, r.Action)
+		}
+		for name, m := range r.Arguments {
+			if _, err := path.Match(name, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid argument pattern %q", i+1, name)
+			}
+			if m == nil {
+				return fmt.Errorf("rule %d: empty matcher for argument %q", i+1, name)
+			}
+			if m.Pattern != "" {
+				re, err := regexp.Compile(m.Pattern)
+				if err != nil {
+					return fmt.Errorf("rule %d: argument %q: %w", i+1, name, err)
+				}
+				m.re = re
+			}
+			for _, dir := range m.Under {
+				if !filepath.IsAbs(dir) {
+					return fmt.Errorf("rule %d: argument %q: %q is not an absolute path", i+1, name, dir)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to the tool name
+func (r *policyRule) matches(name string) bool {
+	for _, pattern := range r.Tools {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns why value violates the matcher, or nil
+func (m *argumentMatcher) check(value any) error {
+	if len(m.Under) > 0 || m.re != nil {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		if len(m.Under) > 0 && !pathUnder(s, m.Under) {
+			return fmt.Errorf("%q is not under %s", s, strings.Join(m.Under, ", "))
+		}
+		if m.re != nil && !m.re.MatchString(s) {
+			return fmt.Errorf("%q does not match %s", s, m.Pattern)
+		}
+	}
+
+	if len(m.Enum) > 0 {
+		found := false
+		for _, allowed := range m.Enum {
+			if reflect.DeepEqual(jsonValue(allowed), jsonValue(value)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s is not one of the allowed values", compactJSON(value))
+		}
+	}
+
+	if m.Min != nil || m.Max != nil {
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("must be a number")
+		}
+		if m.Min != nil && n < *m.Min {
+			return fmt.Errorf("%v is below the minimum %v", n, *m.Min)
+		}
+		if m.Max != nil && n > *m.Max {
+			return fmt.Errorf("%v is above the maximum %v", n, *m.Max)
+		}
+	}
+	return nil
+}
+
+// pathUnder reports whether p lies inside one of dirs. Relative paths are
+// resolved against the first directory.
+func pathUnder(p string, dirs []string) bool {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dirs[0], p)
+	}
+	p = filepath.Clean(p)
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyDecision is the outcome of evaluating a tool call
+type policyDecision struct {
+	Allowed bool
+	// Rule is the 1-based index of the deciding rule, or 0 for the default
+	Rule   int
+	Reason string
+}
+
+// policyEngine evaluates tool calls against a policy and tracks call rates.
+// It is shared by all sessions.
+type policyEngine struct {
+	policy *policyFile
+	now    func() time.Time
+
+	mu    sync.Mutex
+	calls map[int][]time.Time
+}
+
+func newPolicyEngine(p *policyFile) *policyEngine {
+	return &policyEngine{policy: p, now: time.Now, calls: make(map[int][]time.Time)}
+}
+
+// toolLookup fetches the definition of the tool being called
+type toolLookup func(ctx context.Context) (*mcp.Tool, error)
+
+// Evaluate decides whether the tool named name may be called with args. The
+// tool definition is only looked up when a check needs it.
+func (e *policyEngine) Evaluate(ctx context.Context, name string, args map[string]any, lookup toolLookup) policyDecision {
+	var rule *policyRule
+	decision := policyDecision{}
+	for i := range e.policy.Rules {
+		if e.policy.Rules[i].matches(name) {
+			rule = &e.policy.Rules[i]
+			decision.Rule = i + 1
+			break
+		}
+	}
+
+	deny := func(reason string) policyDecision {
+		decision.Reason = reason
+		return decision
+	}
+
+	switch {
+	case rule != nil && rule.Action == policyDeny:
+		if rule.Reason != "" {
+			return deny(rule.Reason)
+		}
+		return deny("denied by rule")
+	case rule == nil && e.policy.Default == policyDeny:
+		return deny("no rule allows this tool")
+	}
+
+	var tool *mcp.Tool
+	var toolErr error
+	looked := false
+	getTool := func() (*mcp.Tool, error) {
+		if !looked {
+			tool, toolErr = lookup(ctx)
+			looked = true
+		}
+		return tool, toolErr
+	}
+
+	if e.policy.BlockDestructive {
+		t, err := getTool()
+		if err != nil {
+			return deny(fmt.Sprintf("cannot check annotations: %v", err))
+		}
+		if t != nil && t.Annotations != nil && !t.Annotations.ReadOnlyHint &&
+			t.Annotations.DestructiveHint != nil && *t.Annotations.DestructiveHint {
+			return deny("tool is annotated destructive")
+		}
+	}
+
+	if rule != nil && len(rule.Arguments) > 0 {
+		var schema *ToolSchema
+		if t, err := getTool(); err == nil && t != nil {
+			schema, _ = toolSchema(t)
+		}
+		if reason := checkArguments(rule.Arguments, args, schema); reason != "" {
+			return deny(reason)
+		}
+	}
+
+	if reason := e.takeCall(decision.Rule, rule); reason != "" {
+		return deny(reason)
+	}
+
+	decision.Allowed = true
+	return decision
+}
+
+// checkArguments applies argument matchers. Matcher keys are globs over the
+// parameter names of the tool's schema and of the call's arguments.
+func checkArguments(matchers map[string]*argumentMatcher, args map[string]any, schema *ToolSchema) string {
+	names := make(map[string]bool)
+	for name := range args {
+		names[name] = true
+	}
+	if schema != nil {
+		for name := range schema.Parameters {
+			names[name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sortedMatcherKeys(matchers) {
+		m := matchers[key]
+		matched := false
+		for _, name := range sorted {
+			if ok, _ := path.Match(key, name); !ok {
+				continue
+			}
+			matched = true
+			value, present := args[name]
+			if !present {
+				if m.Required {
+					return fmt.Sprintf("argument %q is required", name)
+				}
+				continue
+			}
+			if err := m.check(value); err != nil {
+				return fmt.Sprintf("argument %q: %v", name, err)
+			}
+		}
+		if !matched && m.Required {
+			return fmt.Sprintf("argument %q is required", key)
+		}
+	}
+	return ""
+}
+
+// takeCall records a call against the global and rule rate limits, or
+// returns why the call exceeds them
+func (e *policyEngine) takeCall(ruleIndex int, rule *policyRule) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	window := now.Add(-time.Minute)
+	recent := func(key int) []time.Time {
+		kept := e.calls[key][:0]
+		for _, t := range e.calls[key] {
+			if t.After(window) {
+				kept = append(kept, t)
+			}
+		}
+		e.calls[key] = kept
+		return kept
+	}
+
+	// The global limit is tracked under key 0, rules under their index
+	if limit := e.policy.MaxCallsPerMinute; limit > 0 && len(recent(0)) >= limit {
+		return fmt.Sprintf("rate limit of %d calls per minute exceeded", limit)
+	}
+	if rule != nil && rule.MaxCallsPerMinute > 0 && len(recent(ruleIndex)) >= rule.MaxCallsPerMinute {
+		return fmt.Sprintf("rate limit of %d calls per minute for this tool exceeded", rule.MaxCallsPerMinute)
+	}
+
+	e.calls[0] = append(e.calls[0], now)
+	if rule != nil && rule.MaxCallsPerMinute > 0 {
+		e.calls[ruleIndex] = append(e.calls[ruleIndex], now)
+	}
+	return ""
+}
+
+// Check evaluates a call, logs the decision and returns the JSON-RPC error
+// sent to the client if the call is denied
+func (e *policyEngine) Check(ctx context.Context, name string, args map[string]any, lookup toolLookup) error {
+	d := e.Evaluate(ctx, name, args, lookup)
+
+	rule := "default"
+	if d.Rule > 0 {
+		rule = fmt.Sprintf("rule %d", d.Rule)
+	}
+	if d.Allowed {
+		log.Printf("Policy: allowed call to tool %q (%s)", name, rule)
+		return nil
+	}
+	log.Printf("Policy: denied call to tool %q (%s): %s", name, rule, d.Reason)
+	return jsonRPCError(codePolicyDenied, fmt.Sprintf("call to tool %q denied by policy: %s", name, d.Reason))
+}
+
+// beforeForward enforces the policy on tools/call requests passing through
+// the proxy
+func (e *policyEngine) beforeForward(ctx context.Context, upstream *mcp.ClientSession, method string, params mcp.Params) (mcp.Result, error) {
+	if method != "tools/call" {
+		return nil, nil
+	}
+	p, err := decodeParams[mcp.CallToolParams](params)
+	if err != nil {
+		return nil, err
+	}
+	args, _ := jsonValue(p.Arguments).(map[string]any)
+
+	return nil, e.Check(ctx, p.Name, args, func(ctx context.Context) (*mcp.Tool, error) {
+		return getTool(ctx, upstream, p.Name)
+	})
+}
+
+func sortedMatcherKeys(m map[string]*argumentMatcher) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const testPolicyYAML = `
+default: deny
+block_destructive: true
+rules:
+  - tools: ["shell_*"]
+    action: deny
+    reason: "no shell access"
+  - tools: ["read_file", "write_file"]
+    arguments:
+      "*path":
+        under: /workspace
+        required: true
+  - tools: ["fetch"]
+    arguments:
+      url: {pattern: "^https://"}
+      retries: {min: 0, max: 3}
+      method: {enum: [GET, HEAD]}
+  - tools: ["echo", "drop_table"]
+    max_calls_per_minute: 2
+`
+
+func loadTestPolicy(t *testing.T) *policyEngine {
+	t.Helper()
+	engine, err := loadPolicy(writeTestFile(t, "policy.yaml", testPolicyYAML))
+	if err != nil {
+		t.Fatalf("loadPolicy: %v", err)
+	}
+	return engine
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	tools := map[string]*mcp.Tool{
+		"read_file": {Name: "read_file", InputSchema: &jsonschema.Schema{
+			Type:       "object",
+			Properties: map[string]*jsonschema.Schema{"file_path": {Type: "string"}},
+		}},
+		"drop_table": {Name: "drop_table", Annotations: &mcp.ToolAnnotations{DestructiveHint: boolPtr(true)}},
+	}
+	lookupOf := func(name string) toolLookup {
+		return func(context.Context) (*mcp.Tool, error) {
+			if tool, ok := tools[name]; ok {
+				return tool, nil
+			}
+			return &mcp.Tool{Name: name}, nil
+		}
+	}
+
+	tests := []struct {
+		name       string
+		tool       string
+		args       map[string]any
+		wantReason string
+	}{
+		{"denied by rule", "shell_exec", nil, "no shell access"},
+		{"default deny", "unknown", nil, "no rule allows this tool"},
+		{"destructive blocked", "drop_table", nil, "annotated destructive"},
+		{"path under workspace", "read_file", map[string]any{"file_path": "/workspace/a.txt"}, ""},
+		{"relative path", "read_file", map[string]any{"file_path": "src/main.go"}, ""},
+		{"path outside workspace", "read_file", map[string]any{"file_path": "/etc/passwd"}, `"/etc/passwd" is not under /workspace`},
+		{"path traversal", "read_file", map[string]any{"file_path": "/workspace/../etc/passwd"}, "not under /workspace"},
+		{"relative traversal", "read_file", map[string]any{"file_path": "../../etc/passwd"}, "not under /workspace"},
+		{"sibling prefix", "read_file", map[string]any{"file_path": "/workspace2/x"}, "not under /workspace"},
+		{"required from schema", "read_file", map[string]any{}, `argument "file_path" is required`},
+		{"path not a string", "read_file", map[string]any{"file_path": 1.0}, "must be a string"},
+		{"pattern match", "fetch", map[string]any{"url": "https://example.com"}, ""},
+		{"pattern mismatch", "fetch", map[string]any{"url": "http://169.254.169.254/"}, "does not match"},
+		{"above max", "fetch", map[string]any{"retries": 5.0}, "above the maximum"},
+		{"enum allowed", "fetch", map[string]any{"method": "HEAD"}, ""},
+		{"enum refused", "fetch", map[string]any{"method": "POST"}, "not one of the allowed values"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := loadTestPolicy(t)
+			d := engine.Evaluate(context.Background(), tt.tool, tt.args, lookupOf(tt.tool))
+
+			if tt.wantReason == "" && !d.Allowed {
+				t.Errorf("expected call to be allowed, denied: %s", d.Reason)
+			}
+			if tt.wantReason != "" && (d.Allowed || !strings.Contains(d.Reason, tt.wantReason)) {
+				t.Errorf("expected denial containing %q, got allowed=%v reason=%q", tt.wantReason, d.Allowed, d.Reason)
+			}
+		})
+	}
+}
+
+func TestPolicyRateLimit(t *testing.T) {
+	engine := loadTestPolicy(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	lookup := func(context.Context) (*mcp.Tool, error) { return &mcp.Tool{Name: "echo"}, nil }
+
+	for i := 0; i < 2; i++ {
+		if d := engine.Evaluate(context.Background(), "echo", nil, lookup); !d.Allowed {
+			t.Fatalf("call %d: unexpected denial %q", i, d.Reason)
+		}
+	}
+	if d := engine.Evaluate(context.Background(), "echo", nil, lookup); d.Allowed || !strings.Contains(d.Reason, "rate limit") {
+		t.Fatalf("expected rate limit denial, got %+v", d)
+	}
+
+	now = now.Add(61 * time.Second)
+	if d := engine.Evaluate(context.Background(), "echo", nil, lookup); !d.Allowed {
+		t.Errorf("expected the window to have moved on, got %q", d.Reason)
+	}
+}
+
+func TestPolicyLookupIsLazy(t *testing.T) {
+	engine := newPolicyEngine(&policyFile{Rules: []policyRule{{Tools: []string{"*"}}}})
+	if err := engine.policy.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	lookup := func(context.Context) (*mcp.Tool, error) { return nil, errors.New("should not be called") }
+
+	if d := engine.Evaluate(context.Background(), "anything", nil, lookup); !d.Allowed {
+		t.Errorf("unexpected denial %q", d.Reason)
+	}
+}
+
+func TestPolicyCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  policyFile
+		wantErr string
+	}{
+		{"bad default", policyFile{Default: "maybe"}, "default must be"},
+		{"rule without tools", policyFile{Rules: []policyRule{{}}}, "no tools"},
+		{"bad action", policyFile{Rules: []policyRule{{Tools: []string{"x"}, Action: "block"}}}, "action must be"},
+		{"bad tool glob", policyFile{Rules: []policyRule{{Tools: []string{"["}}}}, "invalid tool pattern"},
+		{"bad regexp", policyFile{Rules: []policyRule{{Tools: []string{"x"}, Arguments: map[string]*argumentMatcher{"a": {Pattern: "("}}}}}, `argument "a"`},
+		{"relative under", policyFile{Rules: []policyRule{{Tools: []string{"x"}, Arguments: map[string]*argumentMatcher{"a": {Under: stringList{"workspace"}}}}}}, "not an absolute path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.compile()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProxyEnforcesPolicy(t *testing.T) {
+	upstream := newTestMCPServer(t, nil)
+	engine, err := loadPolicy(writeTestFile(t, "policy.json",
+		`{"rules": [{"tools": ["echo"], "arguments": {"message": {"pattern": "^safe"}}}]}`))
+	if err != nil {
+		t.Fatalf("loadPolicy: %v", err)
+	}
+
+	dial := func() (mcp.Transport, error) {
+		return newClientTransport("http", upstream.URL, http.DefaultClient)
+	}
+	proxy := httptest.NewServer(newMCPHandler(func(*http.Request) *mcp.Server {
+		return newProxyServer(dial, nil, proxyHooks{beforeForward: engine.beforeForward})
+	}))
+	defer proxy.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := mcp.NewClient(&mcp.Implementation{Name: "policy-test"}, nil)
+	session, err := client.Connect(ctx, mcp.NewStreamableClientTransport(proxy.URL+"/mcp", nil))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer session.Close()
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "echo", Arguments: map[string]any{"message": "safe text"}})
+	if err != nil || contentText(res.Content) != "safe text" {
+		t.Fatalf("expected allowed call to be forwarded, got %v, %v", res, err)
+	}
+
+	_, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "echo", Arguments: map[string]any{"message": "rm -rf /"}})
+	if err == nil {
+		t.Fatal("expected denied call to fail")
+	}
+	if wire := wireErrorOf(err); wire.Code != codePolicyDenied || !strings.Contains(wire.Message, "denied by policy") {
+		t.Errorf("expected policy JSON-RPC error, got %+v", wire)
+	}
+}
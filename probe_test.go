@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type fetchParams struct {
+	URL string `json:"url"`
+}
+
+type readParams struct {
+	Path string `json:"path"`
+}
+
+// newVulnerableSession connects to an in-memory server with an SSRF-prone fetch
+// tool, a traversal-prone read tool and a safe tool that ignores its URL
+func newVulnerableSession(t *testing.T) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "vulnerable", Version: "v0.0.1"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "fetch"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[fetchParams]) (*mcp.CallToolResultFor[any], error) {
+			resp, err := http.Get(params.Arguments.URL)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			return &mcp.CallToolResultFor[any]{Content: []mcp.Content{&mcp.TextContent{Text: string(body)}}}, nil
+		})
+	mcp.AddTool(server, &mcp.Tool{Name: "read"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[readParams]) (*mcp.CallToolResultFor[any], error) {
+			text := "not found"
+			if strings.HasSuffix(params.Arguments.Path, "etc/passwd") {
+				text = "root:x:0:0:root:/root:/bin/bash"
+			}
+			return &mcp.CallToolResultFor[any]{Content: []mcp.Content{&mcp.TextContent{Text: text}}}, nil
+		})
+	mcp.AddTool(server, &mcp.Tool{Name: "safe"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[fetchParams]) (*mcp.CallToolResultFor[any], error) {
+			return &mcp.CallToolResultFor[any]{Content: []mcp.Content{&mcp.TextContent{Text: "ok"}}}, nil
+		})
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(ctx, serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client"}, nil)
+	session, err := client.Connect(ctx, clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func TestCanaryServer(t *testing.T) {
+	canary, err := newCanaryServer("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatalf("newCanaryServer: %v", err)
+	}
+	defer canary.Close()
+
+	resp, err := http.Get(canary.URL("abc123"))
+	if err != nil {
+		t.Fatalf("GET canary: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != canaryMarker("abc123") {
+		t.Errorf("expected canary marker body, got %q", body)
+	}
+	if hits := canary.Hits("abc123"); len(hits) != 1 || hits[0].Method != "GET" {
+		t.Errorf("expected one GET hit, got %v", hits)
+	}
+	if hits := canary.Hits("other"); len(hits) != 0 {
+		t.Errorf("expected no hits for unknown token, got %v", hits)
+	}
+
+	public, err := newCanaryServer("127.0.0.1:0", "http://10.0.0.2:8899/")
+	if err != nil {
+		t.Fatalf("newCanaryServer: %v", err)
+	}
+	defer public.Close()
+	if got := public.URL("t"); got != "http://10.0.0.2:8899/mcpmap/t" {
+		t.Errorf("unexpected public canary URL %q", got)
+	}
+}
+
+func TestBuildParamProbes(t *testing.T) {
+	canary, err := newCanaryServer("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatalf("newCanaryServer: %v", err)
+	}
+	defer canary.Close()
+
+	schema := &ToolSchema{Parameters: map[string]*ParameterSchema{
+		"target":   {Name: "target", Type: "string", Format: "uri"},
+		"filepath": {Name: "filepath", Type: "string"},
+		"count":    {Name: "count", Type: "integer"},
+		"note":     {Name: "note", Type: "string"},
+	}}
+
+	probes := buildParamProbes("tool", schema, canary)
+
+	kinds := make(map[string]map[string]int)
+	for _, p := range probes {
+		if kinds[p.Param] == nil {
+			kinds[p.Param] = make(map[string]int)
+		}
+		kinds[p.Param][p.Kind]++
+	}
+
+	if kinds["target"][probeKindSSRF] != 1 {
+		t.Errorf("expected one SSRF probe for target, got %v", kinds["target"])
+	}
+	if kinds["filepath"][probeKindRead] != len(probeReadPayloads) {
+		t.Errorf("expected %d read probes for filepath, got %v", len(probeReadPayloads), kinds["filepath"])
+	}
+	if _, ok := kinds["count"]; ok {
+		t.Error("integer parameter should not be probed")
+	}
+	if _, ok := kinds["note"]; ok {
+		t.Error("unrelated string parameter should not be probed")
+	}
+}
+
+func TestProbeTools(t *testing.T) {
+	session := newVulnerableSession(t)
+
+	canary, err := newCanaryServer("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatalf("newCanaryServer: %v", err)
+	}
+	defer canary.Close()
+
+	oldWait, oldTimeout := probeWait, probeTimeout
+	probeWait, probeTimeout = 100*time.Millisecond, 5*time.Second
+	defer func() { probeWait, probeTimeout = oldWait, oldTimeout }()
+
+	results, err := probeTools(context.Background(), session, []string{"fetch", "read", "safe"}, canary)
+	if err != nil {
+		t.Fatalf("probeTools: %v", err)
+	}
+
+	verdicts := make(map[string][]string)
+	for _, r := range results {
+		if r.Exploitable {
+			verdicts[r.Tool] = append(verdicts[r.Tool], probeVerdict(r))
+		}
+	}
+
+	if len(verdicts["fetch"]) != 1 || verdicts["fetch"][0] != "full SSRF (response returned)" {
+		t.Errorf("expected full SSRF for fetch, got %v", verdicts["fetch"])
+	}
+	if len(verdicts["read"]) == 0 || verdicts["read"][0] != "arbitrary file read" {
+		t.Errorf("expected arbitrary file read for read, got %v", verdicts["read"])
+	}
+	if len(verdicts["safe"]) != 0 {
+		t.Errorf("expected safe tool to not be exploitable, got %v", verdicts["safe"])
+	}
+}
+
+func TestRunProbeParamsRequiresOptIn(t *testing.T) {
+	oldAllow := probeAllow
+	probeAllow = false
+	defer func() { probeAllow = oldAllow }()
+
+	err := runProbeParams(probeParamsCmd, []string{"fetch"})
+	if err == nil || !strings.Contains(err.Error(), "--allow-probes") {
+		t.Errorf("expected opt-in error, got %v", err)
+	}
+}
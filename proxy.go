@@ -0,0 +1,468 @@
+// proxy.go - Intercepting MCP proxy with traffic logging
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	proxyListen  string
+	proxyLogFile string
+)
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Run an intercepting MCP proxy in front of the server",
+	Long: `Run an intercepting MCP proxy in front of the server.
+
+mcpmap acts as an MCP server on the listen address and forwards every JSON-RPC
+request and notification to the upstream server given with --sse or --http,
+opening one upstream session per client session. Requests, responses and
+notifications in both directions are logged as JSONL with timestamps.
+
+Clients can connect with either transport:
+  SSE:              http://<listen>/sse
+  Streamable HTTP:  http://<listen>/mcp
+
+Examples:
+  mcpmap --sse=http://localhost:3000/sse proxy --listen :9000
+  mcpmap --http=https://mcp.example.com/mcp --token=$TOKEN proxy --listen 127.0.0.1:9000 --log traffic.jsonl`,
+	Args: cobra.NoArgs,
+	RunE: runProxy,
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+	proxyCmd.Flags().StringVarP(&proxyListen, "listen", "l", "127.0.0.1:9000", "Address to accept MCP clients on")
+	proxyCmd.Flags().StringVar(&proxyLogFile, "log", "", "File to append the JSONL traffic log to (default: stdout)")
+	proxyCmd.Flags().StringVar(&policyFilePath, "policy", "", "Enforce the tool call policy in this JSON or YAML file")
+}
+
+// Traffic directions, relative to the proxied client and server
+const (
+	directionClientToServer = "client->server"
+	directionServerToClient = "server->client"
+)
+
+// trafficEntry is a single logged JSON-RPC message
+type trafficEntry struct {
+	Time       time.Time `json:"time"`
+	Session    int64     `json:"session"`
+	Direction  string    `json:"direction"`
+	Kind       string    `json:"kind"`
+	Seq        int64     `json:"seq"`
+	Method     string    `json:"method"`
+	Params     any       `json:"params,omitempty"`
+	Result     any       `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs *int64    `json:"duration_ms,omitempty"`
+}
+
+// trafficLog writes trafficEntry values as JSONL, masking secrets with redact
+type trafficLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	redact *redactor
+	seq    atomic.Int64
+}
+
+func newTrafficLog(w io.Writer, redact *redactor) *trafficLog {
+	return &trafficLog{w: w, redact: redact}
+}
+
+func (l *trafficLog) write(e trafficEntry) {
+	if l == nil {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err == nil {
+		l.mu.Lock()
+		_, err = fmt.Fprintf(l.w, "%s\n", l.redact.JSON(data))
+		l.mu.Unlock()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write traffic log: %v\n", err)
+	}
+}
+
+// logged wraps a forwarding call with request, response and notification entries
+func (l *trafficLog) logged(
+	session int64,
+	direction, method string,
+	params mcp.Params,
+	call func() (mcp.Result, error),
+) (mcp.Result, error) {
+	if l == nil {
+		return call()
+	}
+
+	seq := l.seq.Add(1)
+	kind := "request"
+	if isNotification(method) {
+		kind = "notification"
+	}
+	l.write(trafficEntry{
+		Time: time.Now(), Session: session, Direction: direction,
+		Kind: kind, Seq: seq, Method: method, Params: params,
+	})
+
+	start := time.Now()
+	res, err := call()
+	if kind == "notification" {
+		return res, err
+	}
+
+	reply := directionServerToClient
+	if direction == directionServerToClient {
+		reply = directionClientToServer
+	}
+	duration := time.Since(start).Milliseconds()
+	entry := trafficEntry{
+		Time: time.Now(), Session: session, Direction: reply,
+		Kind: "response", Seq: seq, Method: method, Result: res, DurationMs: &duration,
+	}
+	if err != nil {
+		entry.Kind = "error"
+		entry.Result = nil
+		entry.Error = err.Error()
+	}
+	l.write(entry)
+
+	return res, err
+}
+
+func isNotification(method string) bool {
+	return strings.HasPrefix(method, "notifications/")
+}
+
+// proxyBridge connects one downstream server session to one upstream client session
+type proxyBridge struct {
+	id    int64
+	log   *trafficLog
+	dial  func() (mcp.Transport, error)
+	hooks proxyHooks
+
+	mu           sync.Mutex
+	upstream     *mcp.ClientSession
+	upstreamSend mcp.MethodHandler[*mcp.ClientSession]
+	downstream   *mcp.ServerSession
+	serverSend   mcp.MethodHandler[*mcp.ServerSession]
+	initResult   *mcp.InitializeResult
+}
+
+// proxyHooks let other commands observe or alter forwarded traffic
+type proxyHooks struct {
+	// beforeForward is called for every client request before it is sent
+	// upstream. A non-nil result or error is returned to the client instead.
+	beforeForward func(ctx context.Context, upstream *mcp.ClientSession, method string, params mcp.Params) (mcp.Result, error)
+
+	// listChanged is called when the upstream announces that its tools,
+	// resources or prompts changed; kind names the list
+	listChanged func(upstream *mcp.ClientSession, kind string)
+}
+
+var proxySessionIDs atomic.Int64
+
+// newProxyServer returns an MCP server for a single downstream session that
+// forwards everything to a fresh upstream session created with dial
+func newProxyServer(dial func() (mcp.Transport, error), log *trafficLog, hooks proxyHooks) *mcp.Server {
+	b := &proxyBridge{id: proxySessionIDs.Add(1), log: log, dial: dial, hooks: hooks}
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "mcpmap-proxy", Version: "v1.0.0"}, nil)
+	server.AddSendingMiddleware(func(next mcp.MethodHandler[*mcp.ServerSession]) mcp.MethodHandler[*mcp.ServerSession] {
+		b.serverSend = next
+		return next
+	})
+	server.AddReceivingMiddleware(b.receiveFromClient)
+
+	return server
+}
+
+// receiveFromClient handles messages arriving from the downstream client
+func (b *proxyBridge) receiveFromClient(next mcp.MethodHandler[*mcp.ServerSession]) mcp.MethodHandler[*mcp.ServerSession] {
+	return func(ctx context.Context, ss *mcp.ServerSession, method string, params mcp.Params) (mcp.Result, error) {
+		switch method {
+		case "initialize":
+			return b.logged(directionClientToServer, method, params, func() (mcp.Result, error) {
+				return b.initialize(ctx, ss, method, params, next)
+			})
+		case "notifications/initialized":
+			// The upstream session was already initialized by Connect
+			return next(ctx, ss, method, params)
+		}
+
+		return b.logged(directionClientToServer, method, params, func() (mcp.Result, error) {
+			b.mu.Lock()
+			upstream, send := b.upstream, b.upstreamSend
+			b.mu.Unlock()
+			if upstream == nil {
+				return nil, fmt.Errorf("proxy: upstream session not initialized")
+			}
+
+			if b.hooks.beforeForward != nil && !isNotification(method) {
+				if res, err := b.hooks.beforeForward(ctx, upstream, method, params); res != nil || err != nil {
+					return res, err
+				}
+			}
+			return send(ctx, upstream, method, params)
+		})
+	}
+}
+
+// initialize connects upstream on behalf of the client and returns the
+// upstream server's InitializeResult so capabilities pass through unchanged
+func (b *proxyBridge) initialize(
+	ctx context.Context,
+	ss *mcp.ServerSession,
+	method string,
+	params mcp.Params,
+	next mcp.MethodHandler[*mcp.ServerSession],
+) (mcp.Result, error) {
+	impl := &mcp.Implementation{Name: clientName, Version: "v1.0.0"}
+	if p, ok := params.(*mcp.InitializeParams); ok && p.ClientInfo != nil {
+		impl = p.ClientInfo
+	}
+
+	client := mcp.NewClient(impl, &mcp.ClientOptions{
+		// Advertise sampling so upstream servers may request it; the request
+		// itself is forwarded by receiveFromServer
+		CreateMessageHandler: func(context.Context, *mcp.ClientSession, *mcp.CreateMessageParams) (*mcp.CreateMessageResult, error) {
+			return nil, fmt.Errorf("proxy: sampling not forwarded")
+		},
+	})
+	client.AddSendingMiddleware(func(next mcp.MethodHandler[*mcp.ClientSession]) mcp.MethodHandler[*mcp.ClientSession] {
+		b.mu.Lock()
+		b.upstreamSend = next
+		b.mu.Unlock()
+		return func(ctx context.Context, cs *mcp.ClientSession, method string, params mcp.Params) (mcp.Result, error) {
+			res, err := next(ctx, cs, method, params)
+			if method == "initialize" && err == nil {
+				b.mu.Lock()
+				b.initResult, _ = res.(*mcp.InitializeResult)
+				b.mu.Unlock()
+			}
+			return res, err
+		}
+	})
+	client.AddReceivingMiddleware(b.receiveFromServer)
+
+	b.mu.Lock()
+	b.downstream = ss
+	b.mu.Unlock()
+
+	transport, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	// The upstream session outlives the initialize request that created it
+	upstream, err := client.Connect(context.WithoutCancel(ctx), transport)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: connect upstream: %w", err)
+	}
+
+	b.mu.Lock()
+	b.upstream = upstream
+	initResult := b.initResult
+	b.mu.Unlock()
+
+	// Tear down each side when the other goes away
+	go func() {
+		ss.Wait()
+		upstream.Close()
+	}()
+	go func() {
+		upstream.Wait()
+		ss.Close()
+	}()
+
+	// Let the local server mark the session initialized
+	local, err := next(ctx, ss, method, params)
+	if err != nil {
+		return nil, err
+	}
+	if initResult == nil {
+		return local, nil
+	}
+	return initResult, nil
+}
+
+// receiveFromServer handles messages arriving from the upstream server by
+// forwarding them to the downstream client
+func (b *proxyBridge) receiveFromServer(next mcp.MethodHandler[*mcp.ClientSession]) mcp.MethodHandler[*mcp.ClientSession] {
+	return func(ctx context.Context, cs *mcp.ClientSession, method string, params mcp.Params) (mcp.Result, error) {
+		if kind, ok := listChangedKinds[method]; ok && b.hooks.listChanged != nil {
+			b.hooks.listChanged(cs, kind)
+		}
+
+		b.mu.Lock()
+		downstream, send := b.downstream, b.serverSend
+		b.mu.Unlock()
+		if downstream == nil || send == nil {
+			return next(ctx, cs, method, params)
+		}
+
+		return b.logged(directionServerToClient, method, params, func() (mcp.Result, error) {
+			return send(ctx, downstream, method, params)
+		})
+	}
+}
+
+func (b *proxyBridge) logged(
+	direction, method string,
+	params mcp.Params,
+	call func() (mcp.Result, error),
+) (mcp.Result, error) {
+	return b.log.logged(b.id, direction, method, params, call)
+}
+
+// newMCPHandler serves MCP over SSE at /sse and streamable HTTP at /mcp
+func newMCPHandler(getServer func(*http.Request) *mcp.Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/sse", mcp.NewSSEHandler(getServer))
+	mux.Handle("/mcp", mcp.NewStreamableHTTPHandler(getServer, nil))
+	return mux
+}
+
+// serveMCP listens on addr and serves handler until the server fails or the
+// process is interrupted
+func serveMCP(addr, name string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	base := "http://" + listener.Addr().String()
+	fmt.Fprintf(os.Stderr, "%s listening on %s/sse (SSE) and %s/mcp (streamable HTTP)\n", name, base, base)
+
+	// Stop on SIGINT or SIGTERM instead of being killed, so deferred cleanup
+	// such as flushing pending cache updates runs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Handler: handler}
+	errc := make(chan error, 1)
+	go func() { errc <- server.Serve(listener) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Fprintf(os.Stderr, "%s shutting down\n", name)
+	// SSE streams never end on their own, so connections are not drained
+	server.Close()
+	return nil
+}
+
+func runProxy(cmd *cobra.Command, args []string) error {
+	out := io.Writer(os.Stdout)
+	if proxyLogFile != "" {
+		f, err := os.OpenFile(proxyLogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("open traffic log: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	log := newTrafficLog(out, outputRedactor)
+
+	dial := func() (mcp.Transport, error) {
+		return createTransport(transportType, serverURL, proxyURL, authToken, clientName)
+	}
+
+	// Keep the server's cache entry current while clients are connected
+	watcher := newListWatcher("Server", openCache(serverURL, transportType), os.Stderr)
+	defer watcher.flush()
+	hooks := proxyHooks{listChanged: watcher.changed}
+	if policyFilePath != "" {
+		policy, err := loadPolicy(policyFilePath)
+		if err != nil {
+			return err
+		}
+		hooks.beforeForward = policy.beforeForward
+	}
+
+	handler := newMCPHandler(func(*http.Request) *mcp.Server {
+		return newProxyServer(dial, log, hooks)
+	})
+
+	return serveMCP(proxyListen, "Proxy", handler)
+}
+
+// rawMessage is a Params or Result whose JSON form is passed through verbatim
+type rawMessage json.RawMessage
+
+func (m rawMessage) MarshalJSON() ([]byte, error) {
+	if len(m) == 0 {
+		return []byte("{}"), nil
+	}
+	return m, nil
+}
+
+func (rawMessage) GetMeta() map[string]any { return nil }
+func (rawMessage) SetMeta(map[string]any)  {}
+
+// jsonRPCError returns an error that is sent to the peer with the given
+// JSON-RPC error code. The SDK's wire error type is internal, so a fresh
+// value is taken from mcp.ResourceNotFoundError and overwritten. Should the
+// type change shape, a plain error carrying only the message is returned.
+func jsonRPCError(code int64, message string) error {
+	return overwriteWireError(mcp.ResourceNotFoundError(""), code, message)
+}
+
+// overwriteWireError sets the code and message of the wire error err, or
+// returns a plain error if err has no such fields
+func overwriteWireError(err error, code int64, message string) error {
+	v := reflect.ValueOf(err)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New(message)
+	}
+	e := v.Elem()
+	codeField, messageField, dataField := e.FieldByName("Code"), e.FieldByName("Message"), e.FieldByName("Data")
+	if !settable(codeField, reflect.Int64) || !settable(messageField, reflect.String) ||
+		(dataField.IsValid() && !dataField.CanSet()) {
+		return errors.New(message)
+	}
+	codeField.SetInt(code)
+	messageField.SetString(message)
+	if dataField.IsValid() {
+		dataField.SetZero()
+	}
+	return err
+}
+
+// settable reports whether field exists, can be set and is of the given kind
+func settable(field reflect.Value, kind reflect.Kind) bool {
+	return field.IsValid() && field.CanSet() && field.Kind() == kind
+}
+
+// decodeParams converts params received by a server into T. Servers receive
+// tools/call arguments as raw JSON, so a type assertion is not enough.
+func decodeParams[T any](params mcp.Params) (*T, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+	var out T
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, jsonRPCError(codeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+	}
+	return &out, nil
+}
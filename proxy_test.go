@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestProxyForwardsAndLogs(t *testing.T) {
+	upstream := newTestMCPServer(t, nil)
+
+	var logBuf syncBuffer
+	log := newTrafficLog(&logBuf, nil)
+	dial := func() (mcp.Transport, error) {
+		return newClientTransport("http", upstream.URL, http.DefaultClient)
+	}
+	proxy := httptest.NewServer(newMCPHandler(func(*http.Request) *mcp.Server {
+		return newProxyServer(dial, log, proxyHooks{})
+	}))
+	defer proxy.Close()
+
+	tests := []struct {
+		name      string
+		transport mcp.Transport
+	}{
+		{"streamable", mcp.NewStreamableClientTransport(proxy.URL+"/mcp", nil)},
+		{"sse", mcp.NewSSEClientTransport(proxy.URL+"/sse", nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			client := mcp.NewClient(&mcp.Implementation{Name: "proxy-test-client", Version: "v0.0.1"}, nil)
+			session, err := client.Connect(ctx, tt.transport)
+			if err != nil {
+				t.Fatalf("connect through proxy: %v", err)
+			}
+			defer session.Close()
+
+			tools, err := session.ListTools(ctx, nil)
+			if err != nil {
+				t.Fatalf("ListTools: %v", err)
+			}
+			if len(tools.Tools) != 1 || tools.Tools[0].Name != "echo" {
+				t.Fatalf("expected upstream echo tool, got %v", tools.Tools)
+			}
+
+			res, err := session.CallTool(ctx, &mcp.CallToolParams{
+				Name:      "echo",
+				Arguments: map[string]any{"message": "via proxy"},
+			})
+			if err != nil {
+				t.Fatalf("CallTool: %v", err)
+			}
+			if text := contentText(res.Content); text != "via proxy" {
+				t.Errorf("expected echoed message, got %q", text)
+			}
+		})
+	}
+
+	entries := readTrafficLog(t, logBuf.Bytes())
+	seen := make(map[string]int)
+	for _, e := range entries {
+		seen[e.Direction+" "+e.Kind+" "+e.Method]++
+		if e.Time.IsZero() || e.Session == 0 {
+			t.Errorf("entry missing time or session: %+v", e)
+		}
+	}
+
+	for _, want := range []string{
+		"client->server request initialize",
+		"server->client response initialize",
+		"client->server request tools/list",
+		"server->client response tools/list",
+		"client->server request tools/call",
+		"server->client response tools/call",
+	} {
+		if seen[want] != 2 {
+			t.Errorf("expected %q logged once per transport, got %d", want, seen[want])
+		}
+	}
+}
+
+func TestProxyBeforeForwardHook(t *testing.T) {
+	upstream := newTestMCPServer(t, nil)
+
+	dial := func() (mcp.Transport, error) {
+		return newClientTransport("http", upstream.URL, http.DefaultClient)
+	}
+	hooks := proxyHooks{beforeForward: func(ctx context.Context, upstream *mcp.ClientSession, method string, params mcp.Params) (mcp.Result, error) {
+		if method == "tools/call" {
+			return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "intercepted"}}}, nil
+		}
+		return nil, nil
+	}}
+	proxy := httptest.NewServer(newMCPHandler(func(*http.Request) *mcp.Server {
+		return newProxyServer(dial, nil, hooks)
+	}))
+	defer proxy.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "proxy-test-client", Version: "v0.0.1"}, nil)
+	session, err := client.Connect(ctx, mcp.NewStreamableClientTransport(proxy.URL+"/mcp", nil))
+	if err != nil {
+		t.Fatalf("connect through proxy: %v", err)
+	}
+	defer session.Close()
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "echo", Arguments: map[string]any{"message": "hi"}})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if text := contentText(res.Content); text != "intercepted" {
+		t.Errorf("expected hook result, got %q", text)
+	}
+}
+
+func readTrafficLog(t *testing.T, data []byte) []trafficEntry {
+	t.Helper()
+
+	var entries []trafficEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e trafficEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
@@ -0,0 +1,175 @@
+// record.go - Recording of JSON-RPC frames exchanged with the server
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+// recordFile is the JSONL file every frame is recorded to, if set
+var recordFile string
+
+// addRecordFlag registers --record on commands that talk to the server
+func addRecordFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&recordFile, "record", "", "Record every JSON-RPC frame exchanged with the server to this JSONL file")
+}
+
+// Frame directions, relative to mcpmap as the client
+const (
+	frameSend = "send"
+	frameRecv = "recv"
+)
+
+// recordedFrame is a single JSON-RPC message in a recording
+type recordedFrame struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"direction"`
+	ID        any             `json:"id,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *recordedError  `json:"error,omitempty"`
+}
+
+// recordedError is the JSON-RPC error object of a recorded response
+type recordedError struct {
+	Code    int64           `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func newRecordedFrame(direction string, msg jsonrpc.Message) recordedFrame {
+	frame := recordedFrame{Time: time.Now(), Direction: direction}
+	switch m := msg.(type) {
+	case *jsonrpc.Request:
+		frame.ID = m.ID.Raw()
+		frame.Method = m.Method
+		frame.Params = m.Params
+	case *jsonrpc.Response:
+		frame.ID = m.ID.Raw()
+		frame.Result = m.Result
+		if m.Error != nil {
+			frame.Error = wireErrorOf(m.Error)
+		}
+	}
+	return frame
+}
+
+// wireErrorOf finds the JSON-RPC error in err's chain. Wire errors marshal to
+// their code and message; other errors only keep their message.
+func wireErrorOf(err error) *recordedError {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		var re recordedError
+		if data, merr := json.Marshal(e); merr == nil && json.Unmarshal(data, &re) == nil && re.Message != "" {
+			return &re
+		}
+	}
+	return &recordedError{Message: err.Error()}
+}
+
+// redacted returns frame with secrets in its payload masked by r
+func (frame recordedFrame) redacted(r *redactor) recordedFrame {
+	if r == nil {
+		return frame
+	}
+	frame.Params = r.JSON(frame.Params)
+	frame.Result = r.JSON(frame.Result)
+	if frame.Error != nil {
+		e := *frame.Error
+		e.Message = r.String(e.Message)
+		e.Data = r.JSON(e.Data)
+		frame.Error = &e
+	}
+	return frame
+}
+
+// recordingTransport records every frame of the connections it creates,
+// masking secrets with redact
+type recordingTransport struct {
+	delegate mcp.Transport
+	path     string
+	redact   *redactor
+}
+
+func newRecordingTransport(delegate mcp.Transport, path string, redact *redactor) *recordingTransport {
+	return &recordingTransport{delegate: delegate, path: path, redact: redact}
+}
+
+func (t *recordingTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+
+	conn, err := t.delegate.Connect(ctx)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	return &recordingConn{delegate: conn, redact: t.redact, f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+type recordingConn struct {
+	delegate mcp.Connection
+	redact   *redactor
+
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	enc    *json.Encoder
+	closed bool
+}
+
+func (c *recordingConn) SessionID() string { return c.delegate.SessionID() }
+
+func (c *recordingConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	msg, err := c.delegate.Read(ctx)
+	if err == nil {
+		c.record(newRecordedFrame(frameRecv, msg))
+	}
+	return msg, err
+}
+
+func (c *recordingConn) Write(ctx context.Context, msg jsonrpc.Message) error {
+	// Record before writing so the reply can never be recorded first
+	c.record(newRecordedFrame(frameSend, msg))
+	return c.delegate.Write(ctx, msg)
+}
+
+func (c *recordingConn) record(frame recordedFrame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	if err := c.enc.Encode(frame.redacted(c.redact)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record frame: %v\n", err)
+	}
+}
+
+func (c *recordingConn) Close() error {
+	err := c.delegate.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		if ferr := c.w.Flush(); ferr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write recording: %v\n", ferr)
+		}
+		c.f.Close()
+	}
+	return err
+}
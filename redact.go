@@ -0,0 +1,198 @@
+// redact.go - Masking of secrets in tool output and recorded traffic
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	noRedact       bool
+	redactPatterns []string
+)
+
+// secretPattern matches one kind of secret. If re has a subexpression named
+// "secret", only that part of each match is masked so surrounding context
+// such as "Authorization: Bearer" stays readable.
+type secretPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// builtinSecretPatterns are always applied unless redaction is disabled. The
+// order matters: JWTs are masked before the generic bearer token pattern
+// would swallow them.
+var builtinSecretPatterns = []secretPattern{
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{4,}\.eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]*`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"bearer-token", regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]{8,}=*)`)},
+}
+
+// redactor masks secrets and counts how many it masked. A nil redactor
+// passes everything through unchanged.
+type redactor struct {
+	patterns []secretPattern
+	count    atomic.Int64
+}
+
+// outputRedactor is applied to exec results and recorded traffic. It is
+// replaced by configureRedaction once the flags are parsed.
+var outputRedactor = mustNewRedactor()
+
+func mustNewRedactor() *redactor {
+	r, err := newRedactor(nil)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// newRedactor returns a redactor for the built-in patterns plus the custom
+// regular expressions in custom
+func newRedactor(custom []string) (*redactor, error) {
+	r := &redactor{patterns: append([]secretPattern(nil), builtinSecretPatterns...)}
+	for _, expr := range custom {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		r.patterns = append(r.patterns, secretPattern{"custom", re})
+	}
+	return r, nil
+}
+
+// configureRedaction sets outputRedactor from --no-redact and --redact-pattern
+func configureRedaction() error {
+	if noRedact {
+		outputRedactor = nil
+		return nil
+	}
+	r, err := newRedactor(redactPatterns)
+	if err != nil {
+		return err
+	}
+	outputRedactor = r
+	return nil
+}
+
+// redactedMarker starts the text that replaces a masked secret
+const redactedMarker = "[REDACTED:"
+
+// String returns s with every secret replaced by a [REDACTED:<kind>] marker
+func (r *redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, p := range r.patterns {
+		s = r.replace(p, s)
+	}
+	return s
+}
+
+func (r *redactor) replace(p secretPattern, s string) string {
+	matches := p.re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	group := p.re.SubexpIndex("secret")
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 && m[2*group] >= 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(redactedMarker + p.name + "]")
+		last = end
+	}
+	b.WriteString(s[last:])
+	r.count.Add(int64(len(matches)))
+	return b.String()
+}
+
+// JSON masks secrets in the string values of a JSON document. The document
+// is returned unchanged if it contains no secrets or is not valid JSON.
+func (r *redactor) JSON(data []byte) []byte {
+	if r == nil {
+		return data
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return data
+	}
+
+	before := r.count.Load()
+	v = r.value(v)
+	if r.count.Load() == before {
+		return data
+	}
+
+	// Encode without HTML escaping so the markers stay readable
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return data
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func (r *redactor) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.String(v)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = r.value(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = r.value(e)
+		}
+	}
+	return v
+}
+
+// uncounted returns a redactor with the same patterns whose masking is not
+// reported, for output that is only compared and never printed
+func (r *redactor) uncounted() *redactor {
+	if r == nil {
+		return nil
+	}
+	return &redactor{patterns: r.patterns}
+}
+
+// Count returns the number of secrets masked so far
+func (r *redactor) Count() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.count.Load()
+}
+
+// Report prints the number of masked secrets to w, if there were any
+func (r *redactor) Report(w io.Writer) {
+	n := r.Count()
+	if n == 0 {
+		return
+	}
+	noun := "secrets"
+	if n == 1 {
+		noun = "secret"
+	}
+	fmt.Fprintf(w, "Redacted %d %s from output (use --no-redact to show them)\n", n, noun)
+}
@@ -0,0 +1,489 @@
+// replay.go - Replay recorded sessions against a server or serve them offline
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+// Standard JSON-RPC error codes
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+var (
+	replayServe  bool
+	replayListen string
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <session.jsonl>",
+	Short: "Replay a recorded session against a server, or serve it as a fake server",
+	Long: `Replay a session recorded with --record.
+
+With --sse or --http, every recorded request is sent again to the live server
+and its response is compared with the recorded one. Differences are reported
+field by field and the command fails if any response differs. Recorded tool
+calls go through the same --read-only and confirmation checks as exec, before
+any request is sent. Requests with masked secrets cannot be sent again, so
+sessions to replay live must be recorded with --no-redact.
+
+With --serve, mcpmap instead acts as an MCP server that answers each request
+with the recorded response for the same method and parameters, so clients can
+be tested offline. Repeated requests get the recorded responses in order.
+
+Examples:
+  mcpmap --http=https://mcp.example.com/mcp exec search --param q=x --record session.jsonl
+  mcpmap --http=https://mcp.example.com/mcp replay session.jsonl
+  mcpmap replay session.jsonl --serve --listen 127.0.0.1:9001`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{annotationTransportOptional: "true"},
+	RunE:        runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+	replayCmd.Flags().BoolVar(&replayServe, "serve", false, "Serve the recorded responses as a fake MCP server")
+	replayCmd.Flags().StringVarP(&replayListen, "listen", "l", "127.0.0.1:9001", "With --serve, address to accept MCP clients on")
+	replayCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output replay results as JSON lines")
+}
+
+// recordedCall is a client request from a recording paired with its response
+type recordedCall struct {
+	Method string
+	Params json.RawMessage
+	Result json.RawMessage
+	Error  *recordedError
+}
+
+// loadRecording reads a recording and pairs each client request with the
+// server's response. Notifications and server-initiated requests are skipped.
+func loadRecording(path string) ([]recordedCall, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	defer f.Close()
+
+	var calls []recordedCall
+	pending := make(map[string]int)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var frame recordedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if frame.ID == nil {
+			continue
+		}
+
+		id := fmt.Sprint(frame.ID)
+		switch {
+		case frame.Direction == frameSend && frame.Method != "":
+			pending[id] = len(calls)
+			calls = append(calls, recordedCall{Method: frame.Method, Params: frame.Params})
+		case frame.Direction == frameRecv && frame.Method == "":
+			if i, ok := pending[id]; ok {
+				calls[i].Result = frame.Result
+				calls[i].Error = frame.Error
+				delete(pending, id)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read recording: %w", err)
+	}
+
+	return calls, nil
+}
+
+// Replay statuses
+const (
+	replayMatch   = "match"
+	replayDiffers = "differs"
+)
+
+// replayResult compares one recorded response with the live one
+type replayResult struct {
+	Index  int      `json:"index"`
+	Method string   `json:"method"`
+	Status string   `json:"status"`
+	Diffs  []string `json:"diffs,omitempty"`
+}
+
+// checkUnredacted returns an error if a recorded request had secrets masked,
+// as sending the masks in place of the real values would not replay it
+func checkUnredacted(calls []recordedCall) error {
+	for i, call := range calls {
+		if bytes.Contains(call.Params, []byte(redactedMarker)) {
+			return fmt.Errorf("request %d (%s) has redacted secrets and cannot be replayed live; record the session with --no-redact, or use --serve", i, call.Method)
+		}
+	}
+	return nil
+}
+
+// checkReplayedTools runs the tool gate for every recorded tool call, so a
+// replay is refused as a whole before any tool is called
+func checkReplayedTools(ctx context.Context, session *mcp.ClientSession, calls []recordedCall) error {
+	var tools map[string]*mcp.Tool
+	for i, call := range calls {
+		if call.Method != "tools/call" {
+			continue
+		}
+		var params struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(call.Params, &params); err != nil {
+			return fmt.Errorf("request %d (%s): %w", i, call.Method, err)
+		}
+		if tools == nil {
+			list, err := getTools(ctx, session)
+			if err != nil {
+				return fmt.Errorf("list tools: %w", err)
+			}
+			tools = make(map[string]*mcp.Tool, len(list))
+			for _, tool := range list {
+				tools[tool.Name] = tool
+			}
+		}
+		if err := defaultToolGate.Check(tools[params.Name], params.Name); err != nil {
+			return fmt.Errorf("request %d (%s): %w", i, call.Method, err)
+		}
+	}
+	return nil
+}
+
+// replayLive re-sends the recorded requests over transport and compares the
+// responses. The initialize request is sent by Connect itself.
+func replayLive(ctx context.Context, transport mcp.Transport, calls []recordedCall) ([]replayResult, error) {
+	if err := checkUnredacted(calls); err != nil {
+		return nil, err
+	}
+
+	var (
+		send       mcp.MethodHandler[*mcp.ClientSession]
+		initResult *mcp.InitializeResult
+	)
+	client := mcp.NewClient(&mcp.Implementation{Name: clientName, Version: "v1.0.0"}, nil)
+	client.AddSendingMiddleware(func(next mcp.MethodHandler[*mcp.ClientSession]) mcp.MethodHandler[*mcp.ClientSession] {
+		send = next
+		return func(ctx context.Context, cs *mcp.ClientSession, method string, params mcp.Params) (mcp.Result, error) {
+			res, err := next(ctx, cs, method, params)
+			if method == "initialize" && err == nil {
+				initResult, _ = res.(*mcp.InitializeResult)
+			}
+			return res, err
+		}
+	})
+
+	session, err := client.Connect(ctx, transport)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer session.Close()
+
+	if err := checkReplayedTools(ctx, session, calls); err != nil {
+		return nil, err
+	}
+
+	results := make([]replayResult, 0, len(calls))
+	for i, call := range calls {
+		var res mcp.Result
+		var err error
+		if call.Method == "initialize" {
+			res = initResult
+		} else {
+			res, err = send(ctx, session, call.Method, rawMessage(call.Params))
+		}
+		results = append(results, compareReplay(i, call, res, err))
+	}
+
+	return results, nil
+}
+
+// compareReplay diffs a live response against the recorded one
+func compareReplay(index int, call recordedCall, res mcp.Result, err error) replayResult {
+	result := replayResult{Index: index, Method: call.Method, Status: replayMatch}
+
+	switch {
+	case err != nil && call.Error != nil:
+		live := wireErrorOf(err)
+		if live.Code != call.Error.Code || live.Message != call.Error.Message {
+			result.Diffs = []string{fmt.Sprintf("error: recorded %d %q, live %d %q",
+				call.Error.Code, call.Error.Message, live.Code, live.Message)}
+		}
+	case err != nil:
+		result.Diffs = []string{fmt.Sprintf("error: recorded a result, live error %q", err.Error())}
+	case call.Error != nil:
+		result.Diffs = []string{fmt.Sprintf("error: recorded error %q, live returned a result", call.Error.Message)}
+	default:
+		// Recordings have their secrets masked, so mask the live result too.
+		// It is not printed, so its secrets are not reported.
+		live := outputRedactor.uncounted().value(jsonValue(res))
+		result.Diffs = diffJSON("result", normalizeAs(res, call.Result), live)
+	}
+
+	if len(result.Diffs) > 0 {
+		result.Status = replayDiffers
+	}
+	return result
+}
+
+// jsonValue returns the generic JSON form of v
+func jsonValue(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+// normalizeAs decodes raw into a new value of sample's type and returns its
+// generic JSON form, so recorded and live values drop the same unknown fields
+func normalizeAs(sample any, raw json.RawMessage) any {
+	t := reflect.TypeOf(sample)
+	if t == nil || t.Kind() != reflect.Pointer || len(raw) == 0 {
+		var out any
+		_ = json.Unmarshal(raw, &out)
+		return out
+	}
+
+	v := reflect.New(t.Elem()).Interface()
+	if err := json.Unmarshal(raw, v); err != nil {
+		var out any
+		_ = json.Unmarshal(raw, &out)
+		return out
+	}
+	return jsonValue(v)
+}
+
+// diffJSON lists the differences between two generic JSON values
+func diffJSON(path string, want, got any) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range w {
+			keys[k] = true
+		}
+		for k := range g {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var diffs []string
+		for _, k := range sorted {
+			wv, inWant := w[k]
+			gv, inGot := g[k]
+			switch {
+			case !inGot:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing from live response", path, k))
+			case !inWant:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: not in recording", path, k))
+			default:
+				diffs = append(diffs, diffJSON(path+"."+k, wv, gv)...)
+			}
+		}
+		return diffs
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		var diffs []string
+		for i := 0; i < len(w) && i < len(g); i++ {
+			diffs = append(diffs, diffJSON(fmt.Sprintf("%s[%d]", path, i), w[i], g[i])...)
+		}
+		if len(w) != len(g) {
+			diffs = append(diffs, fmt.Sprintf("%s: recorded %d items, live %d", path, len(w), len(g)))
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(want, got) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: recorded %s, live %s", path, compactJSON(want), compactJSON(got))}
+}
+
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// replayResponder answers requests of one session from a recording
+type replayResponder struct {
+	mu    sync.Mutex
+	calls []recordedCall
+	used  []bool
+}
+
+// newReplayServer returns an MCP server for a single session that answers
+// with the recorded responses
+func newReplayServer(calls []recordedCall) *mcp.Server {
+	r := &replayResponder{calls: calls, used: make([]bool, len(calls))}
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "mcpmap-replay", Version: "v1.0.0"}, nil)
+	server.AddReceivingMiddleware(r.middleware)
+	return server
+}
+
+func (r *replayResponder) middleware(next mcp.MethodHandler[*mcp.ServerSession]) mcp.MethodHandler[*mcp.ServerSession] {
+	return func(ctx context.Context, ss *mcp.ServerSession, method string, params mcp.Params) (mcp.Result, error) {
+		switch {
+		case method == "initialize":
+			// Let the local server mark the session initialized, but answer
+			// with the recorded server info and capabilities
+			local, err := next(ctx, ss, method, params)
+			if err != nil {
+				return nil, err
+			}
+			if call := r.match(method, nil); call != nil && call.Error == nil {
+				return rawMessage(call.Result), nil
+			}
+			return local, nil
+		case method == "ping" || isNotification(method):
+			return next(ctx, ss, method, params)
+		}
+
+		call := r.match(method, params)
+		if call == nil {
+			if r.recorded(method) {
+				return nil, jsonRPCError(codeInvalidParams, fmt.Sprintf("no recorded response for %s with these parameters", method))
+			}
+			return nil, jsonRPCError(codeMethodNotFound, fmt.Sprintf("no recorded response for %s", method))
+		}
+		if call.Error != nil {
+			return nil, jsonRPCError(call.Error.Code, call.Error.Message)
+		}
+		return rawMessage(call.Result), nil
+	}
+}
+
+// match returns the first unused recorded call with the same method and
+// parameters, or the last used one once all have been used. A nil params
+// matches any recorded parameters.
+func (r *replayResponder) match(method string, params mcp.Params) *recordedCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var got any
+	if params != nil {
+		got = withoutMeta(jsonValue(params))
+	}
+
+	var last *recordedCall
+	for i := range r.calls {
+		call := &r.calls[i]
+		if call.Method != method {
+			continue
+		}
+		if params != nil && !reflect.DeepEqual(got, withoutMeta(normalizeAs(params, call.Params))) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return call
+		}
+		last = call
+	}
+	return last
+}
+
+// recorded reports whether the recording has any request for method
+func (r *replayResponder) recorded(method string) bool {
+	for _, call := range r.calls {
+		if call.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutMeta drops the _meta field, which carries per-request progress tokens
+func withoutMeta(v any) any {
+	if m, ok := v.(map[string]any); ok {
+		delete(m, "_meta")
+	}
+	return v
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	calls, err := loadRecording(args[0])
+	if err != nil {
+		return err
+	}
+	if len(calls) == 0 {
+		return fmt.Errorf("no requests found in %s", args[0])
+	}
+
+	if replayServe {
+		handler := newMCPHandler(func(*http.Request) *mcp.Server {
+			return newReplayServer(calls)
+		})
+		fmt.Fprintf(os.Stderr, "Serving %d recorded responses from %s\n", len(calls), args[0])
+		return serveMCP(replayListen, "Replay server", handler)
+	}
+
+	if serverURL == "" {
+		return fmt.Errorf("must specify either --sse=<url> or --http=<url>, or --serve")
+	}
+
+	transport, err := createTransport(transportType, serverURL, proxyURL, authToken, clientName)
+	if err != nil {
+		return err
+	}
+
+	results, err := replayLive(context.Background(), transport, calls)
+	if err != nil {
+		return err
+	}
+
+	differing := 0
+	for _, r := range results {
+		if r.Status == replayDiffers {
+			differing++
+		}
+		if jsonOutput {
+			if js, err := json.Marshal(r); err == nil {
+				fmt.Fprintln(os.Stdout, string(js))
+			}
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%3d  %-28s %s\n", r.Index, r.Method, r.Status)
+		for _, d := range r.Diffs {
+			fmt.Fprintf(os.Stdout, "       %s\n", d)
+		}
+	}
+
+	if differing > 0 {
+		return fmt.Errorf("%d of %d replayed responses differ", differing, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+// risk.go - Capability-based risk classification of MCP tools
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Risk categories describe what a tool is capable of
+const (
+	riskCodeExec        = "code-exec"
+	riskFilesystemWrite = "filesystem-write"
+	riskNetworkEgress   = "network-egress"
+	riskDataWrite       = "data-write"
+	riskFilesystemRead  = "filesystem-read"
+	riskDataRead        = "data-read"
+)
+
+// riskCategoryScores ranks categories, the highest one determines a tool's level
+var riskCategoryScores = map[string]int{
+	riskCodeExec:        4,
+	riskFilesystemWrite: 3,
+	riskNetworkEgress:   3,
+	riskDataWrite:       2,
+	riskFilesystemRead:  2,
+	riskDataRead:        1,
+}
+
+// riskLevels maps scores to level names, indexed by score
+var riskLevels = []string{"info", "low", "medium", "high", "critical"}
+
+// ToolRisk is the result of classifying a single tool
+type ToolRisk struct {
+	Level      string   `json:"level"`
+	Score      int      `json:"score"`
+	Categories []string `json:"categories"`
+	Reasons    []string `json:"reasons"`
+}
+
+// riskRule matches a regular expression against tool text and assigns a category
+type riskRule struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// Heuristics applied to tool names and descriptions
+var toolRiskRules = []riskRule{
+	{riskCodeExec, regexp.MustCompile(`(?i)\b(exec(ute)?|eval(uate)?|shell|bash|zsh|powershell|cmd|command|subprocess|spawn|run_?(code|command|script|python|js)|terminal)\b|(^|_)(exec|eval|shell|run)(_|$)`)},
+	{riskFilesystemWrite, regexp.MustCompile(`(?i)\b(write|create|delete|remove|move|rename|mkdir|rmdir|upload|edit|patch|append|overwrite|save|chmod|chown)[_ ]?(a |the )?(file|dir|directory|folder|path)s?\b|(^|_)(write|delete|remove|move|edit|save)_?(file|dir|directory|folder)s?(_|$)`)},
+	{riskFilesystemRead, regexp.MustCompile(`(?i)\b(read|get|list|cat|open|view|download|search)[_ ]?(a |the )?(file|dir|directory|folder|path)s?\b|(^|_)(read|list|get|view)_?(file|dir|directory|folder)s?(_|$)`)},
+	{riskNetworkEgress, regexp.MustCompile(`(?i)\b(fetch|http|https|url|request|download|browse|crawl|scrape|webhook|curl|wget|send_?(email|message|mail)|post_?to|api call)\b|(^|_)(fetch|http|browse|crawl|scrape|webhook)(_|$)`)},
+	{riskDataWrite, regexp.MustCompile(`(?i)\b(insert|update|upsert|delete|drop|truncate|alter|modify|remove|write)\b|(^|_)(insert|update|upsert|delete|drop|remove|write)(_|$)`)},
+	{riskDataRead, regexp.MustCompile(`(?i)\b(sql|query|select|database|db|read|get|list|search|find|lookup|fetch|show|describe)\b|(^|_)(sql|query|read|get|list|search|find|lookup|show)(_|$)`)},
+}
+
+// Heuristics applied to parameter names
+var paramRiskRules = []riskRule{
+	{riskCodeExec, regexp.MustCompile(`(?i)^(command|cmd|script|code|shell|expression|expr|program)$`)},
+	{riskNetworkEgress, regexp.MustCompile(`(?i)(^|_)(url|uri|endpoint|host|hostname|webhook|callback|domain|address)(_|$)|url$|uri$`)},
+	{riskDataRead, regexp.MustCompile(`(?i)^(sql|query|statement)$`)},
+}
+
+// pathParamPattern matches parameters that take filesystem paths
+var pathParamPattern = regexp.MustCompile(`(?i)(^|_)(path|file|filename|filepath|dir|directory|folder|dest|destination|source|src)s?(_|$)|path$`)
+
+// classifyToolRisk classifies a tool using its annotations and heuristics over
+// its name, description and parameters
+func classifyToolRisk(tool *mcp.Tool) ToolRisk {
+	categories := make(map[string]bool)
+	var reasons []string
+	add := func(category, reason string) {
+		categories[category] = true
+		if !slices.Contains(reasons, reason) {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	text := tool.Name + " " + strings.ReplaceAll(tool.Name, "_", " ") + " " + tool.Description
+	for _, rule := range toolRiskRules {
+		if m := rule.pattern.FindString(text); m != "" {
+			add(rule.category, fmt.Sprintf("%s: matched %q", rule.category, strings.TrimSpace(m)))
+		}
+	}
+
+	writes := categories[riskFilesystemWrite] || categories[riskDataWrite]
+	if tool.InputSchema != nil {
+		schema, _ := extractFullSchema(tool.InputSchema)
+		for _, param := range flattenParameters(schema.Parameters) {
+			for _, rule := range paramRiskRules {
+				if rule.pattern.MatchString(param.Name) {
+					add(rule.category, fmt.Sprintf("%s: parameter %q", rule.category, param.Name))
+				}
+			}
+			if param.Format == "uri" || param.Format == "url" || param.Format == "uri-reference" {
+				add(riskNetworkEgress, fmt.Sprintf("%s: parameter %q has format %s", riskNetworkEgress, param.Name, param.Format))
+			}
+			if pathParamPattern.MatchString(param.Name) {
+				category := riskFilesystemRead
+				if writes {
+					category = riskFilesystemWrite
+				}
+				add(category, fmt.Sprintf("%s: parameter %q", category, param.Name))
+			}
+		}
+	}
+
+	if ann := tool.Annotations; ann != nil {
+		if ann.ReadOnlyHint {
+			// Read-only tools cannot write, whatever their names suggest
+			delete(categories, riskFilesystemWrite)
+			delete(categories, riskDataWrite)
+			reasons = append(reasons, "annotation: readOnlyHint")
+			if len(categories) == 0 {
+				categories[riskDataRead] = true
+			}
+		} else {
+			if ann.DestructiveHint != nil && *ann.DestructiveHint {
+				add(riskDataWrite, "annotation: destructiveHint")
+			}
+		}
+		if ann.OpenWorldHint != nil && *ann.OpenWorldHint {
+			add(riskNetworkEgress, "annotation: openWorldHint")
+		}
+	}
+
+	risk := ToolRisk{Categories: []string{}, Reasons: reasons}
+	for category := range categories {
+		risk.Categories = append(risk.Categories, category)
+		risk.Score = max(risk.Score, riskCategoryScores[category])
+	}
+	sort.Slice(risk.Categories, func(i, j int) bool {
+		si, sj := riskCategoryScores[risk.Categories[i]], riskCategoryScores[risk.Categories[j]]
+		if si != sj {
+			return si > sj
+		}
+		return risk.Categories[i] < risk.Categories[j]
+	})
+
+	// Explicitly destructive tools are at least high risk
+	if ann := tool.Annotations; ann != nil && !ann.ReadOnlyHint && ann.DestructiveHint != nil && *ann.DestructiveHint {
+		risk.Score = max(risk.Score, 3)
+	}
+	risk.Level = riskLevels[risk.Score]
+	if risk.Reasons == nil {
+		risk.Reasons = []string{}
+	}
+
+	return risk
+}
+
+// flattenParameters returns parameters and their nested object properties and array items
+func flattenParameters(params map[string]*ParameterSchema) []*ParameterSchema {
+	var out []*ParameterSchema
+	for _, name := range sortedKeys(params) {
+		param := params[name]
+		if param == nil {
+			continue
+		}
+		out = append(out, param)
+		if param.Properties != nil {
+			out = append(out, flattenParameters(param.Properties)...)
+		}
+		if param.Items != nil && param.Items.Properties != nil {
+			out = append(out, flattenParameters(param.Items.Properties)...)
+		}
+	}
+	return out
+}
+
+// sortedKeys returns the keys of a parameter map in a stable order
+func sortedKeys(params map[string]*ParameterSchema) []string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// riskLevelScore converts a level name back to its score
+func riskLevelScore(level string) (int, error) {
+	for i, l := range riskLevels {
+		if strings.EqualFold(l, level) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown risk level %q, supported levels: %s", level, strings.Join(riskLevels, ", "))
+}
+
+// rankedTool pairs a tool with its classification
+type rankedTool struct {
+	Tool *mcp.Tool
+	Risk ToolRisk
+}
+
+// rankTools classifies tools, drops those below minLevel or outside categories
+// (if any are given) and sorts the rest by descending risk, then name
+func rankTools(tools []*mcp.Tool, minLevel string, categories []string) ([]rankedTool, error) {
+	minScore := 0
+	if minLevel != "" {
+		var err error
+		if minScore, err = riskLevelScore(minLevel); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range categories {
+		if _, ok := riskCategoryScores[c]; !ok {
+			return nil, fmt.Errorf("unknown risk category %q", c)
+		}
+	}
+
+	ranked := make([]rankedTool, 0, len(tools))
+	for _, tool := range tools {
+		risk := classifyToolRisk(tool)
+		if risk.Score < minScore {
+			continue
+		}
+		if len(categories) > 0 && !slices.ContainsFunc(categories, func(c string) bool {
+			return slices.Contains(risk.Categories, c)
+		}) {
+			continue
+		}
+		ranked = append(ranked, rankedTool{Tool: tool, Risk: risk})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Risk.Score != ranked[j].Risk.Score {
+			return ranked[i].Risk.Score > ranked[j].Risk.Score
+		}
+		return ranked[i].Tool.Name < ranked[j].Tool.Name
+	})
+
+	return ranked, nil
+}
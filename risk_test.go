@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestClassifyToolRisk(t *testing.T) {
+	tests := []struct {
+		name         string
+		tool         *mcp.Tool
+		wantLevel    string
+		wantCategory string
+		notCategory  string
+	}{
+		{
+			name:         "shell command",
+			tool:         &mcp.Tool{Name: "run_command", Description: "Run a shell command"},
+			wantLevel:    "critical",
+			wantCategory: riskCodeExec,
+		},
+		{
+			name: "command parameter",
+			tool: &mcp.Tool{Name: "do_task", InputSchema: &jsonschema.Schema{
+				Type:       "object",
+				Properties: map[string]*jsonschema.Schema{"cmd": {Type: "string"}},
+			}},
+			wantLevel:    "critical",
+			wantCategory: riskCodeExec,
+		},
+		{
+			name: "write file",
+			tool: &mcp.Tool{Name: "write_file", Description: "Write a file to disk", InputSchema: &jsonschema.Schema{
+				Type:       "object",
+				Properties: map[string]*jsonschema.Schema{"path": {Type: "string"}, "content": {Type: "string"}},
+			}},
+			wantLevel:    "high",
+			wantCategory: riskFilesystemWrite,
+		},
+		{
+			name: "read file",
+			tool: &mcp.Tool{Name: "read_file", Description: "Read the contents of a file", InputSchema: &jsonschema.Schema{
+				Type:       "object",
+				Properties: map[string]*jsonschema.Schema{"path": {Type: "string"}},
+			}},
+			wantLevel:    "medium",
+			wantCategory: riskFilesystemRead,
+			notCategory:  riskFilesystemWrite,
+		},
+		{
+			name: "url format",
+			tool: &mcp.Tool{Name: "preview", InputSchema: &jsonschema.Schema{
+				Type:       "object",
+				Properties: map[string]*jsonschema.Schema{"target": {Type: "string", Format: "uri"}},
+			}},
+			wantLevel:    "high",
+			wantCategory: riskNetworkEgress,
+		},
+		{
+			name:         "sql query",
+			tool:         &mcp.Tool{Name: "query", Description: "Run a SQL select against the database"},
+			wantLevel:    "low",
+			wantCategory: riskDataRead,
+		},
+		{
+			name: "read-only annotation overrides write heuristics",
+			tool: &mcp.Tool{
+				Name:        "delete_preview",
+				Description: "Show what delete would remove",
+				Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+			},
+			wantCategory: riskDataRead,
+			notCategory:  riskDataWrite,
+		},
+		{
+			name: "destructive annotation",
+			tool: &mcp.Tool{
+				Name:        "reset",
+				Annotations: &mcp.ToolAnnotations{DestructiveHint: boolPtr(true)},
+			},
+			wantLevel:    "high",
+			wantCategory: riskDataWrite,
+		},
+		{
+			name: "open world annotation",
+			tool: &mcp.Tool{
+				Name:        "lookup",
+				Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: boolPtr(true)},
+			},
+			wantLevel:    "high",
+			wantCategory: riskNetworkEgress,
+		},
+		{
+			name:      "harmless",
+			tool:      &mcp.Tool{Name: "ping", Description: "Check liveness"},
+			wantLevel: "info",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk := classifyToolRisk(tt.tool)
+
+			if tt.wantLevel != "" && risk.Level != tt.wantLevel {
+				t.Errorf("expected level %q, got %q (%v)", tt.wantLevel, risk.Level, risk.Reasons)
+			}
+			if tt.wantCategory != "" && !slices.Contains(risk.Categories, tt.wantCategory) {
+				t.Errorf("expected category %q, got %v", tt.wantCategory, risk.Categories)
+			}
+			if tt.notCategory != "" && slices.Contains(risk.Categories, tt.notCategory) {
+				t.Errorf("did not expect category %q, got %v", tt.notCategory, risk.Categories)
+			}
+		})
+	}
+}
+
+func TestRankTools(t *testing.T) {
+	tools := []*mcp.Tool{
+		{Name: "ping"},
+		{Name: "read_file", Description: "Read a file"},
+		{Name: "exec", Description: "Execute a command"},
+		{Name: "fetch_url", Description: "Fetch a URL"},
+	}
+
+	tests := []struct {
+		name       string
+		minLevel   string
+		categories []string
+		want       []string
+		wantErr    bool
+	}{
+		{"all sorted", "", nil, []string{"exec", "fetch_url", "read_file", "ping"}, false},
+		{"min high", "high", nil, []string{"exec", "fetch_url"}, false},
+		{"category filter", "", []string{riskNetworkEgress}, []string{"fetch_url"}, false},
+		{"bad level", "extreme", nil, nil, true},
+		{"bad category", "", []string{"teleport"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked, err := rankTools(tools, tt.minLevel, tt.categories)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, rt := range ranked {
+				got = append(got, rt.Tool.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOutputToolsRisk(t *testing.T) {
+	h := newTestHelper(t)
+
+	oldJSON, oldRisk := jsonOutput, riskOutput
+	defer func() { jsonOutput, riskOutput = oldJSON, oldRisk }()
+	riskOutput = true
+
+	tools := []*mcp.Tool{{Name: "exec", Description: "Execute a command"}}
+
+	jsonOutput = false
+	output := h.captureOutput(func() { outputTools(tools) })
+	h.assertStringContains(output, []string{"tool:exec", "critical", riskCodeExec})
+
+	jsonOutput = true
+	output = h.captureOutput(func() { outputTools(tools) })
+
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &decoded); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", output, err)
+	}
+	if decoded["name"] != "exec" {
+		t.Errorf("expected tool fields in JSON output, got %v", decoded)
+	}
+	if _, ok := decoded["risk"].(map[string]any); !ok {
+		t.Errorf("expected risk object in JSON output, got %v", decoded)
+	}
+}
@@ -0,0 +1,92 @@
+// safety.go - Confirmation gate for destructive and open-world tool calls
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// toolGate decides whether a tool may be called based on its annotations.
+// Tools explicitly marked destructive or open-world need confirmation, either
+// interactively or with --yes. In --read-only mode only tools annotated with
+// readOnlyHint may be called at all.
+type toolGate struct {
+	in          *bufio.Reader
+	out         io.Writer
+	interactive bool
+	approved    map[string]bool
+}
+
+// defaultToolGate prompts on the terminal, if there is one
+var defaultToolGate = newToolGate(os.Stdin, os.Stderr, isTerminal(os.Stdin))
+
+func newToolGate(in io.Reader, out io.Writer, interactive bool) *toolGate {
+	return &toolGate{
+		in:          bufio.NewReader(in),
+		out:         out,
+		interactive: interactive,
+		approved:    make(map[string]bool),
+	}
+}
+
+// isTerminal reports whether f is connected to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// riskyHints lists the annotations of tool that call for confirmation
+func riskyHints(tool *mcp.Tool) []string {
+	if tool == nil || tool.Annotations == nil || tool.Annotations.ReadOnlyHint {
+		return nil
+	}
+
+	var hints []string
+	if h := tool.Annotations.DestructiveHint; h != nil && *h {
+		hints = append(hints, "destructive")
+	}
+	if h := tool.Annotations.OpenWorldHint; h != nil && *h {
+		hints = append(hints, "open-world")
+	}
+	return hints
+}
+
+// Check returns nil if the tool named name may be called. tool may be nil if
+// its definition could not be fetched.
+func (g *toolGate) Check(tool *mcp.Tool, name string) error {
+	if readOnlyMode && (tool == nil || tool.Annotations == nil || !tool.Annotations.ReadOnlyHint) {
+		return g.refuse(name, "read-only mode and the tool is not annotated readOnlyHint")
+	}
+
+	hints := riskyHints(tool)
+	if len(hints) == 0 || assumeYes || g.approved[name] {
+		return nil
+	}
+
+	kind := strings.Join(hints, " and ")
+	if !g.interactive {
+		return g.refuse(name, fmt.Sprintf("tool is marked %s, pass --yes to call it", kind))
+	}
+
+	fmt.Fprintf(g.out, "Tool %q is marked %s. Call it? [y/N] ", name, kind)
+	answer, _ := g.in.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		g.approved[name] = true
+		return nil
+	default:
+		return g.refuse(name, fmt.Sprintf("tool is marked %s and the call was not confirmed", kind))
+	}
+}
+
+// refuse logs the refused call and returns the error reported to the user
+func (g *toolGate) refuse(name, reason string) error {
+	log.Printf("Refused call to tool %q: %s", name, reason)
+	return fmt.Errorf("refused to call tool %q: %s", name, reason)
+}
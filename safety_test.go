@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestToolGateCheck(t *testing.T) {
+	readOnlyTool := &mcp.Tool{Name: "list", Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true}}
+	destructiveTool := &mcp.Tool{Name: "drop", Annotations: &mcp.ToolAnnotations{DestructiveHint: boolPtr(true)}}
+	openWorldTool := &mcp.Tool{Name: "fetch", Annotations: &mcp.ToolAnnotations{OpenWorldHint: boolPtr(true)}}
+	plainTool := &mcp.Tool{Name: "echo"}
+
+	tests := []struct {
+		name        string
+		tool        *mcp.Tool
+		readOnly    bool
+		yes         bool
+		interactive bool
+		input       string
+		wantErr     string
+		wantPrompt  bool
+	}{
+		{name: "plain tool", tool: plainTool},
+		{name: "read-only tool", tool: readOnlyTool},
+		{name: "unknown tool", tool: nil},
+		{name: "destructive without tty", tool: destructiveTool, wantErr: "pass --yes"},
+		{name: "destructive with yes", tool: destructiveTool, yes: true},
+		{name: "open world without tty", tool: openWorldTool, wantErr: "open-world"},
+		{name: "destructive confirmed", tool: destructiveTool, interactive: true, input: "y\n", wantPrompt: true},
+		{name: "destructive declined", tool: destructiveTool, interactive: true, input: "n\n", wantErr: "not confirmed", wantPrompt: true},
+		{name: "destructive empty answer", tool: destructiveTool, interactive: true, input: "\n", wantErr: "not confirmed", wantPrompt: true},
+		{name: "read-only mode allows read-only", tool: readOnlyTool, readOnly: true},
+		{name: "read-only mode refuses plain", tool: plainTool, readOnly: true, wantErr: "read-only mode"},
+		{name: "read-only mode refuses unknown", tool: nil, readOnly: true, wantErr: "read-only mode"},
+		{name: "read-only mode ignores yes", tool: destructiveTool, readOnly: true, yes: true, wantErr: "read-only mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldReadOnly, oldYes := readOnlyMode, assumeYes
+			readOnlyMode, assumeYes = tt.readOnly, tt.yes
+			defer func() { readOnlyMode, assumeYes = oldReadOnly, oldYes }()
+
+			var out bytes.Buffer
+			gate := newToolGate(strings.NewReader(tt.input), &out, tt.interactive)
+
+			name := "unknown"
+			if tt.tool != nil {
+				name = tt.tool.Name
+			}
+			err := gate.Check(tt.tool, name)
+
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if prompted := out.Len() > 0; prompted != tt.wantPrompt {
+				t.Errorf("expected prompt=%v, got output %q", tt.wantPrompt, out.String())
+			}
+		})
+	}
+}
+
+func TestToolGateRemembersApproval(t *testing.T) {
+	destructiveTool := &mcp.Tool{Name: "drop", Annotations: &mcp.ToolAnnotations{DestructiveHint: boolPtr(true)}}
+
+	var out bytes.Buffer
+	gate := newToolGate(strings.NewReader("yes\n"), &out, true)
+
+	for i := 0; i < 3; i++ {
+		if err := gate.Check(destructiveTool, "drop"); err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+	if prompts := strings.Count(out.String(), "Call it?"); prompts != 1 {
+		t.Errorf("expected a single prompt, got %d", prompts)
+	}
+}
+
+func TestRiskyHints(t *testing.T) {
+	tests := []struct {
+		name string
+		ann  *mcp.ToolAnnotations
+		want string
+	}{
+		{"no annotations", nil, ""},
+		{"explicitly not destructive", &mcp.ToolAnnotations{DestructiveHint: boolPtr(false)}, ""},
+		{"destructive", &mcp.ToolAnnotations{DestructiveHint: boolPtr(true)}, "destructive"},
+		{"both", &mcp.ToolAnnotations{DestructiveHint: boolPtr(true), OpenWorldHint: boolPtr(true)}, "destructive,open-world"},
+		{"read-only wins", &mcp.ToolAnnotations{ReadOnlyHint: true, DestructiveHint: boolPtr(true)}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(riskyHints(&mcp.Tool{Name: "t", Annotations: tt.ann}), ",")
+			if got != tt.want {
+				t.Errorf("riskyHints() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -107,3 +111,62 @@ func (m *mockSession) GetPrompt(ctx context.Context, params *mcp.GetPromptParams
 func (m *mockSession) SetLevel(ctx context.Context, params *mcp.SetLevelParams) error {
 	return errors.New("not implemented")
 }
+
+// echoParams is the input of the echo tool exposed by newTestMCPServer
+type echoParams struct {
+	Message string `json:"message"`
+}
+
+// newTestMCPServer starts a streamable HTTP MCP server exposing an "echo" tool.
+// If wrap is non-nil it is applied to the MCP handler, e.g. to enforce auth.
+func newTestMCPServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v0.0.1"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "echo", Description: "Echo a message"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[echoParams]) (*mcp.CallToolResultFor[any], error) {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: params.Arguments.Message}},
+			}, nil
+		})
+
+	var handler http.Handler = mcp.NewStreamableHTTPHandler(
+		func(*http.Request) *mcp.Server { return server }, nil)
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// useTestServer points the global connection flags at url for the duration of the test
+func useTestServer(t *testing.T, url string) {
+	t.Helper()
+
+	oldURL, oldType, oldToken := serverURL, transportType, authToken
+	serverURL, transportType, authToken = url, "http", ""
+	t.Cleanup(func() {
+		serverURL, transportType, authToken = oldURL, oldType, oldToken
+	})
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent writers
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Bytes returns a copy of the buffered data
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
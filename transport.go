@@ -5,18 +5,57 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-// createHTTPClient creates an HTTP client with optional proxy and authentication
+// createHTTPClient creates an HTTP client with optional proxy and authentication.
+// Headers and TLS settings of the selected profile are applied as well.
 func createHTTPClient(proxyURL, authToken string) (*http.Client, error) {
-	if proxyURL == "" && authToken == "" {
+	return newHTTPClient(proxyURL, authToken, nil)
+}
+
+// newHTTPClient is createHTTPClient with the token optionally supplied by a
+// helper command instead
+func newHTTPClient(proxyURL, authToken string, command *commandToken) (*http.Client, error) {
+	if proxyURL == "" && authToken == "" && command == nil && len(requestHeaders) == 0 && clientTLS == nil {
 		return &http.Client{}, nil
 	}
 
+	transport, err := newBaseTransport(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Transport: transport}
+
+	if len(requestHeaders) > 0 {
+		httpClient.Transport = &headerTransport{
+			base:    httpClient.Transport,
+			headers: requestHeaders,
+		}
+	}
+
+	// Add authentication if token is provided
+	if authToken != "" || command != nil {
+		httpClient.Transport = &authTransport{
+			base:    httpClient.Transport,
+			token:   authToken,
+			command: command,
+		}
+	}
+
+	return httpClient, nil
+}
+
+// newBaseTransport returns a transport with the proxy and TLS settings but
+// none of the headers or credentials
+func newBaseTransport(proxyURL string) (*http.Transport, error) {
 	transport := &http.Transport{}
 
 	if proxyURL != "" {
@@ -27,27 +66,46 @@ func createHTTPClient(proxyURL, authToken string) (*http.Client, error) {
 		transport.Proxy = http.ProxyURL(proxyURLParsed)
 	}
 
-	httpClient := &http.Client{Transport: transport}
-
-	// Add authentication if token is provided
-	if authToken != "" {
-		httpClient.Transport = &authTransport{
-			base:  transport,
-			token: authToken,
+	if clientTLS != nil {
+		tlsConfig, err := tlsClientConfig(clientTLS)
+		if err != nil {
+			return nil, err
 		}
+		transport.TLSClientConfig = tlsConfig
 	}
-
-	return httpClient, nil
+	return transport, nil
 }
 
 func createTransport(
 	transportType, serverURL, proxyURL, authToken, clientName string,
 ) (mcp.Transport, error) {
-	httpClient, err := createHTTPClient(proxyURL, authToken)
-	if err != nil {
-		return nil, err
+	var transport mcp.Transport
+	if transportType == "stdio" {
+		// Only profiles select stdio; serverURL is then just the command line
+		transport = newCommandTransport(stdioCommand, stdioEnv)
+	} else {
+		// A token command only stands in for a missing token
+		var command *commandToken
+		if authToken == "" {
+			command = tokenCommand
+		}
+		httpClient, err := newHTTPClient(proxyURL, authToken, command)
+		if err != nil {
+			return nil, err
+		}
+		if transport, err = newClientTransport(transportType, serverURL, httpClient); err != nil {
+			return nil, err
+		}
 	}
+	if recordFile != "" {
+		return newRecordingTransport(transport, recordFile, outputRedactor), nil
+	}
+	return transport, nil
+}
 
+// newClientTransport builds the MCP client transport for transportType on top of
+// an already configured HTTP client
+func newClientTransport(transportType, serverURL string, httpClient *http.Client) (mcp.Transport, error) {
 	switch strings.ToLower(transportType) {
 	case "streamable", "streamable-http", "http":
 		return mcp.NewStreamableClientTransport(serverURL, &mcp.StreamableClientTransportOptions{
@@ -65,16 +123,66 @@ func createTransport(
 	}
 }
 
-// authTransport wraps an http.RoundTripper to add authentication headers
+// newCommandTransport runs command as a stdio MCP server with env added to
+// the inherited environment
+func newCommandTransport(command []string, env map[string]string) mcp.Transport {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stderr = os.Stderr
+	return mcp.NewCommandTransport(cmd)
+}
+
+// authTransport wraps an http.RoundTripper to add authentication headers.
+// With a token command, a 401 response makes it fetch a new token and retry
+// the request once.
 type authTransport struct {
-	base  http.RoundTripper
-	token string
+	base    http.RoundTripper
+	token   string
+	command *commandToken
 }
 
 func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.command == nil {
+		return t.send(req, t.token)
+	}
+
+	token, err := t.command.Token()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.send(req, token)
+	if err != nil || !retryWithNewToken(req, resp) {
+		return resp, err
+	}
+
+	// The token may have expired, ask the command for a new one
+	t.command.Invalidate(token)
+	fresh, err := t.command.Token()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not refresh token: %v\n", err)
+		return resp, nil
+	}
+	if fresh == token {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.send(retry, fresh)
+}
+
+func (t *authTransport) send(req *http.Request, token string) (*http.Response, error) {
 	// Clone the request to avoid modifying the original
 	reqClone := req.Clone(req.Context())
-	reqClone.Header.Set("Authorization", "Bearer "+t.token)
+	reqClone.Header.Set("Authorization", "Bearer "+token)
 	return t.base.RoundTrip(reqClone)
 }
 
@@ -83,6 +191,7 @@ func createSession(
 	transportType, serverURL, proxyURL, authToken, clientName string,
 ) (*mcp.ClientSession, error) {
 	client := mcp.NewClient(&mcp.Implementation{Name: "mcpmap", Version: "v1.0.0"}, nil)
+	client.AddSendingMiddleware(recordServerInfo)
 	transport, err := createTransport(transportType, serverURL, proxyURL, authToken, clientName)
 	if err != nil {
 		return nil, err
@@ -96,6 +205,29 @@ func createSession(
 	return session, nil
 }
 
+// serverInfos holds the implementation each session's server reported when
+// it was initialized
+var serverInfos sync.Map // *mcp.ClientSession -> *mcp.Implementation
+
+// recordServerInfo remembers the server info of the initialize result
+func recordServerInfo(next mcp.MethodHandler[*mcp.ClientSession]) mcp.MethodHandler[*mcp.ClientSession] {
+	return func(ctx context.Context, cs *mcp.ClientSession, method string, params mcp.Params) (mcp.Result, error) {
+		res, err := next(ctx, cs, method, params)
+		if init, ok := res.(*mcp.InitializeResult); ok && err == nil && init.ServerInfo != nil {
+			serverInfos.Store(cs, init.ServerInfo)
+		}
+		return res, err
+	}
+}
+
+// sessionServerInfo returns the server info of a session created by
+// createSession, or nil
+func sessionServerInfo(session *mcp.ClientSession) *mcp.Implementation {
+	info, _ := serverInfos.Load(session)
+	impl, _ := info.(*mcp.Implementation)
+	return impl
+}
+
 func getTools(ctx context.Context, session *mcp.ClientSession) ([]*mcp.Tool, error) {
 	toolsRes, err := session.ListTools(ctx, &mcp.ListToolsParams{})
 	if err != nil {
@@ -124,38 +256,50 @@ func getToolParameters(
 	return nil, fmt.Errorf("tool %q not found", toolName)
 }
 
-// getToolSchema fetches the schema for a specific tool with timeout
-func getToolSchema(ctx context.Context, session *mcp.ClientSession, toolName string) (*ToolSchema, error) {
-	// Create a context with timeout for schema fetching
-	schemaCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+// getTool fetches the definition of a specific tool with timeout
+func getTool(ctx context.Context, session *mcp.ClientSession, toolName string) (*mcp.Tool, error) {
+	// Create a context with timeout for tool fetching
+	toolCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	// Get all tools to find the specific tool
-	toolsRes, err := session.ListTools(schemaCtx, &mcp.ListToolsParams{})
+	toolsRes, err := session.ListTools(toolCtx, &mcp.ListToolsParams{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tools: %w", err)
 	}
 
-	// Find the specific tool
 	for _, tool := range toolsRes.Tools {
 		if tool.Name == toolName {
-			if tool.InputSchema == nil {
-				// Tool has no schema, return empty schema
-				return &ToolSchema{
-					Parameters: make(map[string]*ParameterSchema),
-					Required:   []string{},
-				}, nil
-			}
-
-			// Extract schema from the tool
-			schema, err := extractFullSchema(tool.InputSchema)
-			if err != nil {
-				return nil, fmt.Errorf("failed to extract schema for tool %q: %w", toolName, err)
-			}
-
-			return schema, nil
+			return tool, nil
 		}
 	}
 
 	return nil, fmt.Errorf("tool %q not found", toolName)
 }
+
+// getToolSchema fetches the schema for a specific tool with timeout
+func getToolSchema(ctx context.Context, session *mcp.ClientSession, toolName string) (*ToolSchema, error) {
+	tool, err := getTool(ctx, session, toolName)
+	if err != nil {
+		return nil, err
+	}
+
+	return toolSchema(tool)
+}
+
+// toolSchema extracts the parameter schema of a tool
+func toolSchema(tool *mcp.Tool) (*ToolSchema, error) {
+	if tool.InputSchema == nil {
+		// Tool has no schema, return empty schema
+		return &ToolSchema{
+			Parameters: make(map[string]*ParameterSchema),
+			Required:   []string{},
+		}, nil
+	}
+
+	schema, err := extractFullSchema(tool.InputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract schema for tool %q: %w", tool.Name, err)
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,530 @@
+// workflow.go - Multi-step workflows chaining tool, resource and prompt results
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	workflowVars    []string
+	workflowTimeout time.Duration
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <workflow.yaml>",
+	Short: "Run a multi-step workflow that chains results into later calls",
+	Long: `Run a multi-step workflow that chains results into later calls.
+
+A workflow is a YAML or JSON file with a list of steps. Each step calls a
+tool, reads a resource or gets a prompt, and later steps refer to earlier
+results with {{ expressions }}:
+
+  vars:
+    query: login
+  steps:
+    - id: search
+      tool: search
+      arguments: {query: "{{ vars.query }}", limit: 10}
+      timeout: 30s
+    - id: details
+      if: "{{ steps.search.structured.items.length > 0 }}"
+      foreach: "{{ steps.search.structured.items }}"
+      tool: get_item
+      arguments: {id: "{{ item.id }}"}
+    - id: readme
+      resource: "repo://{{ steps.details[0].structured.repo }}/README.md"
+  output: "{{ steps.details }}"
+
+The result of a step is available as steps.<id> with these fields:
+
+  text        text content of the result, joined by newlines
+  structured  structured content, or the text parsed as JSON if it is JSON
+  result      the complete result as returned by the server
+  isError     whether a tool reported an error
+
+A step with foreach runs once per element of an array with item and index
+set, and its result is the array of the iteration results. if skips a step
+(or, with foreach, an item) unless the expression is true; null, false, 0
+and empty strings, arrays and objects are false.
+
+Expressions are paths with .key, [index] (negative from the end) and
+.length, optionally compared to another path or a literal with ==, !=, <,
+<=, > or >= and negated with !. A string that is a single expression keeps
+the value's type, otherwise values are inserted as text.
+
+Tool arguments are converted to the types in the tool's schema like exec
+--param values. Tools are checked up front and every step references only
+earlier steps. The output expression (by default all step results) is
+printed as JSON, or as text if it is a string.
+
+Examples:
+  mcpmap --http=https://mcp.example.com/mcp run workflow.yaml
+  mcpmap -s prod run workflow.yaml --var query=logout --timeout 2m`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWorkflow,
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	addRecordFlag(runCmd)
+	runCmd.Flags().StringArrayVar(&workflowVars, "var", nil, "Set a workflow variable (key=value, can be repeated)")
+	runCmd.Flags().DurationVar(&workflowTimeout, "timeout", time.Minute, "Timeout for each call of steps without a timeout")
+}
+
+// workflow is a workflow file
+type workflow struct {
+	Vars   map[string]any  `json:"vars,omitempty"`
+	Steps  []*workflowStep `json:"steps"`
+	Output any             `json:"output,omitempty"`
+}
+
+// workflowStep calls exactly one of a tool, a resource or a prompt
+type workflowStep struct {
+	ID        string         `json:"id"`
+	Tool      string         `json:"tool,omitempty"`
+	Resource  string         `json:"resource,omitempty"`
+	Prompt    string         `json:"prompt,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	If        string         `json:"if,omitempty"`
+	Foreach   string         `json:"foreach,omitempty"`
+	Timeout   string         `json:"timeout,omitempty"`
+
+	// ContinueOnError records a failure as the step's error instead of
+	// stopping the workflow
+	ContinueOnError bool `json:"continue_on_error,omitempty"`
+
+	timeout time.Duration
+}
+
+// stepIDPattern keeps step ids usable in paths
+var stepIDPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// kind returns what the step calls
+func (s *workflowStep) kind() string {
+	switch {
+	case s.Tool != "":
+		return "tool"
+	case s.Resource != "":
+		return "resource"
+	default:
+		return "prompt"
+	}
+}
+
+func (wf *workflow) usesTools() bool {
+	for _, step := range wf.Steps {
+		if step.Tool != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// loadWorkflow reads and validates a workflow file
+func loadWorkflow(path string) (*workflow, error) {
+	var wf workflow
+	if err := decodeStructuredFile(path, &wf); err != nil {
+		return nil, err
+	}
+	if err := wf.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &wf, nil
+}
+
+// validate checks the steps and that every expression parses and refers only
+// to variables and earlier steps. Steps without an id are named step<N>.
+func (wf *workflow) validate() error {
+	if len(wf.Steps) == 0 {
+		return fmt.Errorf("workflow has no steps")
+	}
+
+	seen := map[string]bool{}
+	for i, step := range wf.Steps {
+		if step == nil {
+			return fmt.Errorf("step %d is empty", i+1)
+		}
+		if step.ID == "" {
+			step.ID = fmt.Sprintf("step%d", i+1)
+		}
+		if !stepIDPattern.MatchString(step.ID) {
+			return fmt.Errorf("step %d: invalid id %q (use letters, digits, _ and -)", i+1, step.ID)
+		}
+		if seen[step.ID] {
+			return fmt.Errorf("step %d: duplicate id %q", i+1, step.ID)
+		}
+
+		set := 0
+		for _, field := range []string{step.Tool, step.Resource, step.Prompt} {
+			if field != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("step %s: needs exactly one of tool, resource or prompt", step.ID)
+		}
+		if step.Resource != "" && step.Arguments != nil {
+			return fmt.Errorf("step %s: resources take no arguments", step.ID)
+		}
+		if step.Timeout != "" {
+			d, err := time.ParseDuration(step.Timeout)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("step %s: invalid timeout %q", step.ID, step.Timeout)
+			}
+			step.timeout = d
+		}
+
+		refs := []any{step.Resource, map[string]any(step.Arguments), asTemplate(step.If)}
+		if err := checkReferences(refs, seen, step.Foreach != ""); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		if err := checkReferences(asTemplate(step.Foreach), seen, false); err != nil {
+			return fmt.Errorf("step %s: foreach: %w", step.ID, err)
+		}
+		seen[step.ID] = true
+	}
+
+	if err := checkReferences(wf.Output, seen, false); err != nil {
+		return fmt.Errorf("output: %w", err)
+	}
+	return nil
+}
+
+// asTemplate lets if and foreach be written with or without braces
+func asTemplate(expr string) string {
+	if expr == "" || templatePattern.MatchString(expr) {
+		return expr
+	}
+	return "{{ " + expr + " }}"
+}
+
+// checkReferences parses the expressions in v and checks their roots: vars,
+// earlier steps and, inside foreach, item and index
+func checkReferences(v any, steps map[string]bool, loop bool) error {
+	exprs, err := templateExpressions(v)
+	if err != nil {
+		return err
+	}
+	for _, e := range exprs {
+		for _, path := range e.paths() {
+			switch root := path[0].(string); root {
+			case "vars":
+			case "steps":
+				if len(path) < 2 {
+					continue
+				}
+				if id, ok := path[1].(string); ok && !steps[id] {
+					return fmt.Errorf("expression %q refers to step %q, which does not run before it", e.source, id)
+				}
+			case "item", "index":
+				if !loop {
+					return fmt.Errorf("expression %q uses %s outside of foreach", e.source, root)
+				}
+			default:
+				return fmt.Errorf("expression %q: unknown name %q (use vars, steps, item or index)", e.source, root)
+			}
+		}
+	}
+	return nil
+}
+
+// parseWorkflowVars parses --var key=value flags
+func parseWorkflowVars(vars []string) (map[string]any, error) {
+	result := make(map[string]any, len(vars))
+	for _, v := range vars {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --var %q, expected key=value", v)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// workflowRunner executes the steps of a workflow over one session
+type workflowRunner struct {
+	session *mcp.ClientSession
+	tools   map[string]*mcp.Tool
+	timeout time.Duration
+	log     io.Writer
+
+	// scope holds vars and the results of the steps run so far
+	scope map[string]any
+}
+
+func newWorkflowRunner(session *mcp.ClientSession, tools []*mcp.Tool, vars map[string]any, log io.Writer) *workflowRunner {
+	r := &workflowRunner{
+		session: session,
+		tools:   make(map[string]*mcp.Tool, len(tools)),
+		timeout: workflowTimeout,
+		log:     log,
+		scope:   map[string]any{"vars": jsonValue(vars), "steps": map[string]any{}},
+	}
+	if r.scope["vars"] == nil {
+		r.scope["vars"] = map[string]any{}
+	}
+	for _, tool := range tools {
+		r.tools[tool.Name] = tool
+	}
+	return r
+}
+
+// checkTools verifies that every tool step's tool exists and may be called,
+// before anything is run
+func (r *workflowRunner) checkTools(wf *workflow) error {
+	for _, step := range wf.Steps {
+		if step.Tool == "" {
+			continue
+		}
+		tool, ok := r.tools[step.Tool]
+		if !ok {
+			return fmt.Errorf("step %s: tool %q not found", step.ID, step.Tool)
+		}
+		if err := defaultToolGate.Check(tool, step.Tool); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+	}
+	return nil
+}
+
+// run executes every step and returns the rendered output
+func (r *workflowRunner) run(ctx context.Context, wf *workflow) (any, error) {
+	steps := r.scope["steps"].(map[string]any)
+	for i, step := range wf.Steps {
+		start := time.Now()
+		result, err := r.runStep(ctx, step)
+		if err != nil {
+			fmt.Fprintf(r.log, "[%d/%d] %s: failed after %s\n", i+1, len(wf.Steps), step.ID, time.Since(start).Round(time.Millisecond))
+			return nil, fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		steps[step.ID] = result
+
+		status := "ok"
+		switch {
+		case result == nil:
+			status = "skipped"
+		case step.Foreach != "":
+			status = fmt.Sprintf("ok (%d items)", len(result.([]any)))
+		}
+		fmt.Fprintf(r.log, "[%d/%d] %s: %s in %s\n", i+1, len(wf.Steps), step.ID, status, time.Since(start).Round(time.Millisecond))
+	}
+
+	if wf.Output == nil {
+		return steps, nil
+	}
+	output, err := renderValue(wf.Output, r.scope)
+	if err != nil {
+		return nil, fmt.Errorf("output: %w", err)
+	}
+	return output, nil
+}
+
+// runStep runs a step once, or once per item with foreach. A skipped step
+// yields nil, skipped items are left out of the foreach result.
+func (r *workflowRunner) runStep(ctx context.Context, step *workflowStep) (any, error) {
+	if step.Foreach == "" {
+		if ok, err := r.condition(step, r.scope); err != nil || !ok {
+			return nil, err
+		}
+		return r.call(ctx, step, r.scope)
+	}
+
+	value, err := renderTemplate(asTemplate(step.Foreach), r.scope)
+	if err != nil {
+		return nil, fmt.Errorf("foreach: %w", err)
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("foreach: %s is not an array", compactJSON(value))
+	}
+
+	results := []any{}
+	for i, item := range items {
+		scope := maps.Clone(r.scope)
+		scope["item"] = item
+		scope["index"] = i
+
+		ok, err := r.condition(step, scope)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		if !ok {
+			continue
+		}
+		result, err := r.call(ctx, step, scope)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// condition evaluates the step's if expression
+func (r *workflowRunner) condition(step *workflowStep, scope map[string]any) (bool, error) {
+	if step.If == "" {
+		return true, nil
+	}
+	value, err := renderTemplate(asTemplate(step.If), scope)
+	if err != nil {
+		return false, fmt.Errorf("if: %w", err)
+	}
+	return truthy(value), nil
+}
+
+// call renders the step's templates in scope and performs the request. With
+// continue_on_error a failure becomes a result with an error field.
+func (r *workflowRunner) call(ctx context.Context, step *workflowStep, scope map[string]any) (any, error) {
+	timeout := step.timeout
+	if timeout == 0 {
+		timeout = r.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := r.request(ctx, step, scope)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	if err != nil && step.ContinueOnError {
+		fmt.Fprintf(r.log, "Warning: step %s: %v\n", step.ID, err)
+		return map[string]any{"error": err.Error()}, nil
+	}
+	return result, err
+}
+
+func (r *workflowRunner) request(ctx context.Context, step *workflowStep, scope map[string]any) (map[string]any, error) {
+	rendered, err := renderValue(map[string]any(step.Arguments), scope)
+	if err != nil {
+		return nil, fmt.Errorf("arguments: %w", err)
+	}
+	args, _ := rendered.(map[string]any)
+
+	switch step.kind() {
+	case "tool":
+		schema, err := toolSchema(r.tools[step.Tool])
+		if err != nil {
+			return nil, err
+		}
+		if args, err = convertArguments(args, schema); err != nil {
+			return nil, err
+		}
+		res, err := r.session.CallTool(ctx, &mcp.CallToolParams{Name: step.Tool, Arguments: args})
+		if err != nil {
+			return nil, err
+		}
+		result := stepResult(res, contentText(res.Content), res.StructuredContent)
+		result["isError"] = res.IsError
+		if res.IsError {
+			return result, fmt.Errorf("tool %s returned an error: %s", step.Tool, result["text"])
+		}
+		return result, nil
+
+	case "resource":
+		uri, err := renderTemplate(step.Resource, scope)
+		if err != nil {
+			return nil, fmt.Errorf("resource: %w", err)
+		}
+		res, err := r.session.ReadResource(ctx, &mcp.ReadResourceParams{URI: templateText(uri)})
+		if err != nil {
+			return nil, err
+		}
+		var texts []string
+		for _, c := range res.Contents {
+			if c.Text != "" {
+				texts = append(texts, c.Text)
+			}
+		}
+		return stepResult(res, strings.Join(texts, "\n"), nil), nil
+
+	default:
+		promptArgs := make(map[string]string, len(args))
+		for k, v := range args {
+			promptArgs[k] = templateText(v)
+		}
+		res, err := r.session.GetPrompt(ctx, &mcp.GetPromptParams{Name: step.Prompt, Arguments: promptArgs})
+		if err != nil {
+			return nil, err
+		}
+		var content []mcp.Content
+		for _, m := range res.Messages {
+			content = append(content, m.Content)
+		}
+		return stepResult(res, contentText(content), nil), nil
+	}
+}
+
+// stepResult builds the value steps.<id> refers to. Without structured
+// content, text that is valid JSON is used instead.
+func stepResult(res any, text string, structured any) map[string]any {
+	result := map[string]any{"result": jsonValue(res), "text": text, "structured": nil}
+	if structured != nil {
+		result["structured"] = jsonValue(structured)
+	} else {
+		var parsed any
+		if err := json.Unmarshal([]byte(text), &parsed); err == nil {
+			result["structured"] = parsed
+		}
+	}
+	return result
+}
+
+func runWorkflow(cmd *cobra.Command, args []string) error {
+	wf, err := loadWorkflow(args[0])
+	if err != nil {
+		return err
+	}
+	vars, err := parseWorkflowVars(workflowVars)
+	if err != nil {
+		return err
+	}
+	if wf.Vars == nil {
+		wf.Vars = map[string]any{}
+	}
+	maps.Copy(wf.Vars, vars)
+
+	ctx := context.Background()
+	return withSession(ctx, func(session *mcp.ClientSession) error {
+		// Servers without tools may still be used for resources and prompts
+		var tools []*mcp.Tool
+		if wf.usesTools() {
+			if tools, err = getTools(ctx, session); err != nil {
+				return fmt.Errorf("list tools: %w", err)
+			}
+		}
+
+		runner := newWorkflowRunner(session, tools, wf.Vars, os.Stderr)
+		if err := runner.checkTools(wf); err != nil {
+			return err
+		}
+		output, err := runner.run(ctx, wf)
+		if err != nil {
+			return err
+		}
+
+		if text, ok := output.(string); ok {
+			fmt.Fprintln(os.Stdout, outputRedactor.String(text))
+			return nil
+		}
+		js, err := json.Marshal(output)
+		if err != nil {
+			return fmt.Errorf("json marshal output: %w", err)
+		}
+		fmt.Fprintln(os.Stdout, string(outputRedactor.JSON(js)))
+		return nil
+	})
+}
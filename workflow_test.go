@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type searchParams struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+type lookupParams struct {
+	ID string `json:"id"`
+}
+
+// newWorkflowTestSession connects to an in-memory server with a search tool
+// returning JSON text, a lookup tool, a slow tool, a resource and a prompt
+func newWorkflowTestSession(t *testing.T) (*mcp.ClientSession, []*mcp.Tool) {
+	t.Helper()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "workflow-test"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "search"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[searchParams]) (*mcp.CallToolResultFor[any], error) {
+			var items []map[string]any
+			for i := 0; i < params.Arguments.Limit; i++ {
+				items = append(items, map[string]any{"id": fmt.Sprintf("%s-%d", params.Arguments.Query, i), "active": i%2 == 0})
+			}
+			text, _ := json.Marshal(map[string]any{"items": items})
+			return &mcp.CallToolResultFor[any]{Content: []mcp.Content{&mcp.TextContent{Text: string(text)}}}, nil
+		})
+	mcp.AddTool(server, &mcp.Tool{Name: "lookup"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[lookupParams]) (*mcp.CallToolResultFor[any], error) {
+			return &mcp.CallToolResultFor[any]{
+				Content:           []mcp.Content{&mcp.TextContent{Text: "details of " + params.Arguments.ID}},
+				StructuredContent: map[string]any{"id": params.Arguments.ID, "owner": "alice"},
+			}, nil
+		})
+	mcp.AddTool(server, &mcp.Tool{Name: "slow"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[struct{}]) (*mcp.CallToolResultFor[any], error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return &mcp.CallToolResultFor[any]{}, nil
+			}
+		})
+	server.AddResource(&mcp.Resource{URI: "notes://alice", Name: "alice"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{URI: params.URI, Text: "notes about alice"}}}, nil
+		})
+	server.AddPrompt(&mcp.Prompt{Name: "summarize"},
+		func(ctx context.Context, ss *mcp.ServerSession, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+			return &mcp.GetPromptResult{Messages: []*mcp.PromptMessage{
+				{Role: "user", Content: &mcp.TextContent{Text: "Summarize: " + params.Arguments["text"]}},
+			}}, nil
+		})
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(context.Background(), serverTransport); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "workflow-test-client"}, nil)
+	session, err := client.Connect(context.Background(), clientTransport)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+
+	tools, err := getTools(context.Background(), session)
+	if err != nil {
+		t.Fatalf("getTools: %v", err)
+	}
+	return session, tools
+}
+
+func TestLoadWorkflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"missing steps prefix", "steps:\n  - tool: search\n    arguments: {query: x}\n  - foreach: '{{ step1.structured.items }}'\n    tool: lookup\n", "unknown name \"step1\""},
+		{"default ids", "steps:\n  - tool: search\n  - tool: lookup\n    arguments: {id: '{{ steps.step1.text }}'}\n", ""},
+		{"no steps", "steps: []\n", "has no steps"},
+		{"two actions", "steps:\n  - tool: a\n    prompt: b\n", "exactly one of"},
+		{"duplicate id", "steps:\n  - {id: a, tool: x}\n  - {id: a, tool: y}\n", "duplicate id"},
+		{"bad id", "steps:\n  - {id: a.b, tool: x}\n", "invalid id"},
+		{"bad timeout", "steps:\n  - {tool: x, timeout: soon}\n", "invalid timeout"},
+		{"later step", "steps:\n  - {id: a, tool: x, arguments: {v: '{{ steps.b.text }}'}}\n  - {id: b, tool: y}\n", "does not run before it"},
+		{"item outside loop", "steps:\n  - {tool: x, arguments: {v: '{{ item }}'}}\n", "outside of foreach"},
+		{"item in loop", "steps:\n  - {tool: x, foreach: vars.list, if: item.ok, arguments: {v: '{{ item }}'}}\n", ""},
+		{"bad output", "steps:\n  - {id: a, tool: x}\noutput: '{{ steps.z }}'\n", "output"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadWorkflow(writeTestFile(t, "workflow.yaml", tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunWorkflow(t *testing.T) {
+	session, tools := newWorkflowTestSession(t)
+
+	wf, err := loadWorkflow(writeTestFile(t, "workflow.yaml", `
+vars:
+  query: login
+steps:
+  - id: search
+    tool: search
+    arguments: {query: "{{ vars.query }}", limit: "{{ vars.limit }}"}
+  - id: details
+    foreach: steps.search.structured.items
+    if: item.active
+    tool: lookup
+    arguments: {id: "{{ item.id }}"}
+  - id: never
+    if: "{{ steps.details.length > 5 }}"
+    tool: lookup
+  - id: notes
+    resource: "notes://{{ steps.details[0].structured.owner }}"
+  - id: summary
+    prompt: summarize
+    arguments: {text: "{{ steps.notes.text }}"}
+output:
+  last: "{{ steps.details[-1].text }}"
+  first: "{{ steps.details[0].structured.id }}"
+  skipped: "{{ !steps.never }}"
+  prompt: "{{ steps.summary.text }}"
+`))
+	if err != nil {
+		t.Fatalf("loadWorkflow: %v", err)
+	}
+
+	var log bytes.Buffer
+	runner := newWorkflowRunner(session, tools, map[string]any{"query": "login", "limit": "3"}, &log)
+	if err := runner.checkTools(wf); err != nil {
+		t.Fatalf("checkTools: %v", err)
+	}
+	output, err := runner.run(context.Background(), wf)
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, log.String())
+	}
+
+	want := map[string]any{
+		"last":    "details of login-2",
+		"first":   "login-0",
+		"skipped": true,
+		"prompt":  "Summarize: notes about alice",
+	}
+	if compactJSON(output) != compactJSON(want) {
+		t.Errorf("got output %s, want %s", compactJSON(output), compactJSON(want))
+	}
+	if !strings.Contains(log.String(), "details: ok (2 items)") || !strings.Contains(log.String(), "never: skipped") {
+		t.Errorf("unexpected progress log:\n%s", log.String())
+	}
+}
+
+func TestRunWorkflowFailures(t *testing.T) {
+	session, tools := newWorkflowTestSession(t)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+		want    string
+	}{
+		{"unknown tool", "steps:\n  - tool: missing\n", "tool \"missing\" not found", ""},
+		{"bad argument", "steps:\n  - {tool: search, arguments: {limit: many}}\n", "limit", ""},
+		{"timeout", "steps:\n  - {id: wait, tool: slow, timeout: 50ms}\n", "step wait: timed out after 50ms", ""},
+		{"foreach not array", "steps:\n  - {id: a, tool: lookup, arguments: {id: x}}\n  - {tool: lookup, foreach: steps.a.text}\n", "is not an array", ""},
+		{"continue on error", "steps:\n  - {id: wait, tool: slow, timeout: 50ms, continue_on_error: true}\noutput: '{{ steps.wait.error != null }}'\n", "", "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf, err := loadWorkflow(writeTestFile(t, "workflow.yaml", tt.content))
+			if err != nil {
+				t.Fatalf("loadWorkflow: %v", err)
+			}
+			runner := newWorkflowRunner(session, tools, nil, &bytes.Buffer{})
+			err = runner.checkTools(wf)
+			var output any
+			if err == nil {
+				output, err = runner.run(context.Background(), wf)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if compactJSON(output) != tt.want {
+				t.Errorf("got output %s, want %s", compactJSON(output), tt.want)
+			}
+		})
+	}
+}